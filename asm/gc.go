@@ -0,0 +1,315 @@
+package asm
+
+// 内存管理: 保守式的标记-清除垃圾回收
+//
+// 堆是一块预留的虚拟内存(最大1GiB, 用到时才由内核分配物理页), 由连续的块组成, 每个块为 [头部][数据]:
+// 头部的高位是包括头部在内的块大小(8的倍数), 低3位是标记: 1已标记, 2数据中没有引用(字符串), 4空闲块;
+// 对象的数据为 [类描述符指针][属性...], 字符串的数据为 [长度][字节]; 引用都指向数据的开始处。
+// 位图中每一位对应堆中的8字节, 为1表示该处是一个块的头部, 用于判断一个值是否指向某个块。
+// 每个块至少16字节, 因此标记栈最多需要 堆大小/16 项。
+//
+// 分配时先在空闲链表中首次适配, 再从堆的末尾分配; 末尾超过当前上限时进行回收, 回收后仍然不够则将上限翻倍,
+// 超过最大值时报告内存不足并以退出码1结束。
+//
+// 生成的代码没有全局变量, 也不会在调用之间把引用保存在寄存器中, 因此根只有栈:
+// 栈上每个指向块数据开始处的值都被视为引用(保守式), 对象中的属性同样如此。
+// 清除时合并相邻的空闲块, 位于堆末尾的空闲块归还给堆的末尾。
+//
+// mizar_init:         在_start中调用, 预留堆、位图和标记栈, 并记录栈底
+// mizar_alloc:        分配%rdi字节的对象, 返回值在%rax, 内容已清零
+// mizar_alloc_bytes:  同mizar_alloc, 但数据中没有引用, 用于字符串
+// mizar_gc:           进行一次回收
+//
+// 以上函数可以使用除%rbp和%rsp以外的全部寄存器
+const gcCode = `
+    .set mizar_heap_max, 1073741824
+    .set mizar_heap_initial, 1048576
+    .set mizar_gc_bitmap_size, 16777216
+    .set mizar_gc_stack_size, 536870912
+    .set mizar_gc_mark, 1
+    .set mizar_gc_noscan, 2
+    .set mizar_gc_free, 4
+
+    .text
+mizar_init:
+    leaq 8(%rsp), %rax
+    movq %rax, mizar_stack_base(%rip)
+    movq $mizar_heap_max, %rsi
+    call mizar_mmap
+    movq %rax, mizar_heap_start(%rip)
+    movq %rax, mizar_heap_ptr(%rip)
+    leaq mizar_heap_initial(%rax), %rcx
+    movq %rcx, mizar_heap_limit(%rip)
+    movq $mizar_gc_bitmap_size, %rsi
+    call mizar_mmap
+    movq %rax, mizar_gc_bitmap(%rip)
+    movq $mizar_gc_stack_size, %rsi
+    call mizar_mmap
+    movq %rax, mizar_gc_stack(%rip)
+    ret
+
+# 预留%rsi字节的匿名内存: PROT_READ|PROT_WRITE, MAP_PRIVATE|MAP_ANONYMOUS|MAP_NORESERVE
+mizar_mmap:
+    movq $9, %rax
+    xorq %rdi, %rdi
+    movq $3, %rdx
+    movq $0x4022, %r10
+    movq $-1, %r8
+    xorq %r9, %r9
+    syscall
+    cmpq $-4096, %rax
+    ja mizar_oom
+    ret
+
+mizar_alloc:
+    xorq %rsi, %rsi
+    jmp 1f
+mizar_alloc_bytes:
+    movq $mizar_gc_noscan, %rsi
+1:
+    addq $15, %rdi
+    andq $-8, %rdi
+    pushq %rdi
+    pushq %rsi
+    call mizar_take
+    testq %rax, %rax
+    jnz 2f
+    call mizar_gc
+    movq 8(%rsp), %rdi
+    call mizar_take
+    testq %rax, %rax
+    jnz 2f
+    movq 8(%rsp), %rdi
+    call mizar_grow
+    movq 8(%rsp), %rdi
+    call mizar_take
+    testq %rax, %rax
+    jz mizar_oom
+2:
+    popq %rsi
+    popq %rdi
+    orq %rsi, (%rax)
+    movq %rax, %r8
+    leaq 8(%r8), %rdi
+    movq (%r8), %rcx
+    andq $-8, %rcx
+    subq $8, %rcx
+    xorl %eax, %eax
+    rep stosb
+    leaq 8(%r8), %rax
+    ret
+
+# 取出大小为%rdi的块, 返回块的头部, 头部中只有大小; 空闲链表和堆末尾都不够时返回0
+# 空闲块比需要的大时从其尾部切出, 剩余部分仍留在链表中
+mizar_take:
+    leaq mizar_gc_free_list(%rip), %rdx
+1:
+    movq (%rdx), %rax
+    testq %rax, %rax
+    jz 3f
+    movq (%rax), %rcx
+    andq $-8, %rcx
+    cmpq %rdi, %rcx
+    jae 2f
+    leaq 8(%rax), %rdx
+    jmp 1b
+2:
+    movq %rcx, %r8
+    subq %rdi, %r8
+    cmpq $16, %r8
+    jb 4f
+    movq %r8, %r9
+    orq $mizar_gc_free, %r9
+    movq %r9, (%rax)
+    addq %r8, %rax
+    movq %rdi, (%rax)
+    jmp 5f
+4:
+    movq 8(%rax), %r9
+    movq %r9, (%rdx)
+    movq %rcx, (%rax)
+    ret
+3:
+    movq mizar_heap_ptr(%rip), %rax
+    leaq (%rax,%rdi), %rcx
+    cmpq mizar_heap_limit(%rip), %rcx
+    ja 6f
+    movq %rcx, mizar_heap_ptr(%rip)
+    movq %rdi, (%rax)
+5:
+    movq %rax, %rcx
+    subq mizar_heap_start(%rip), %rcx
+    shrq $3, %rcx
+    movq mizar_gc_bitmap(%rip), %r9
+    btsq %rcx, (%r9)
+    ret
+6:
+    xorq %rax, %rax
+    ret
+
+# 堆的上限翻倍, 至少能从堆末尾分配%rdi字节, 不超过最大值
+mizar_grow:
+    movq mizar_heap_start(%rip), %rdx
+    movq mizar_heap_limit(%rip), %rax
+    subq %rdx, %rax
+    addq %rax, %rax
+    movq mizar_heap_ptr(%rip), %rcx
+    addq %rdi, %rcx
+    subq %rdx, %rcx
+    cmpq %rcx, %rax
+    jae 1f
+    movq %rcx, %rax
+1:
+    cmpq $mizar_heap_max, %rax
+    jbe 2f
+    movq $mizar_heap_max, %rax
+2:
+    addq %rdx, %rax
+    movq %rax, mizar_heap_limit(%rip)
+    ret
+
+# 标记栈从mizar_gc_stack开始向高地址增长, %r12为栈顶
+mizar_gc:
+    incq mizar_gc_count(%rip)
+    movq mizar_gc_stack(%rip), %r12
+    movq %rsp, %rsi
+1:
+    cmpq mizar_stack_base(%rip), %rsi
+    jae 2f
+    movq (%rsi), %rdi
+    call mizar_mark
+    addq $8, %rsi
+    jmp 1b
+2:
+    cmpq mizar_gc_stack(%rip), %r12
+    je 4f
+    subq $8, %r12
+    movq (%r12), %rbx
+    movq (%rbx), %r13
+    andq $-8, %r13
+    addq %rbx, %r13
+    leaq 8(%rbx), %rsi
+3:
+    cmpq %r13, %rsi
+    jae 2b
+    movq (%rsi), %rdi
+    call mizar_mark
+    addq $8, %rsi
+    jmp 3b
+4:
+    jmp mizar_sweep
+
+# %rdi指向某个块的数据开始处, 且该块未被标记时, 标记该块; 数据中可能有引用时压入标记栈
+# 只使用%rax、%rcx、%rdx、%r8
+mizar_mark:
+    movq mizar_heap_start(%rip), %rax
+    leaq 8(%rax), %rcx
+    cmpq %rcx, %rdi
+    jb 1f
+    cmpq mizar_heap_ptr(%rip), %rdi
+    jae 1f
+    testq $7, %rdi
+    jnz 1f
+    leaq -8(%rdi), %rdx
+    movq %rdx, %rcx
+    subq %rax, %rcx
+    shrq $3, %rcx
+    movq mizar_gc_bitmap(%rip), %r8
+    btq %rcx, (%r8)
+    jnc 1f
+    movq (%rdx), %rcx
+    testq $mizar_gc_mark | mizar_gc_free, %rcx
+    jnz 1f
+    orq $mizar_gc_mark, %rcx
+    movq %rcx, (%rdx)
+    testq $mizar_gc_noscan, %rcx
+    jnz 1f
+    movq %rdx, (%r12)
+    addq $8, %r12
+1:
+    ret
+
+# 依次检查每个块: 已标记的清除标记, 其余的合并为空闲块放入空闲链表,
+# 被合并的块在位图中的位被清除; %r9为当前空闲段的开始, 0表示没有
+mizar_sweep:
+    movq $0, mizar_gc_free_list(%rip)
+    movq mizar_heap_start(%rip), %rsi
+    movq mizar_gc_bitmap(%rip), %r10
+    xorq %r9, %r9
+1:
+    cmpq mizar_heap_ptr(%rip), %rsi
+    jae 5f
+    movq (%rsi), %rcx
+    movq %rcx, %rdx
+    andq $-8, %rdx
+    testq $mizar_gc_mark, %rcx
+    jz 3f
+    andq $-2, %rcx
+    movq %rcx, (%rsi)
+    testq %r9, %r9
+    jz 4f
+    movq %rsi, %rax
+    subq %r9, %rax
+    orq $mizar_gc_free, %rax
+    movq %rax, (%r9)
+    movq mizar_gc_free_list(%rip), %rax
+    movq %rax, 8(%r9)
+    movq %r9, mizar_gc_free_list(%rip)
+    xorq %r9, %r9
+    jmp 4f
+3:
+    testq %r9, %r9
+    jnz 2f
+    movq %rsi, %r9
+    jmp 4f
+2:
+    movq %rsi, %rax
+    subq mizar_heap_start(%rip), %rax
+    shrq $3, %rax
+    btrq %rax, (%r10)
+4:
+    addq %rdx, %rsi
+    jmp 1b
+5:
+    testq %r9, %r9
+    jz 6f
+    movq %r9, mizar_heap_ptr(%rip)
+    movq %r9, %rax
+    subq mizar_heap_start(%rip), %rax
+    shrq $3, %rax
+    btrq %rax, (%r10)
+6:
+    ret
+
+Runtime.gc:
+    call mizar_gc
+    ret
+
+Runtime.gcCount:
+    movq mizar_gc_count(%rip), %rax
+    ret
+
+Runtime.heapSize:
+    movq mizar_heap_ptr(%rip), %rax
+    subq mizar_heap_start(%rip), %rax
+    ret
+
+    .data
+    .p2align 3
+mizar_stack_base:
+    .quad 0
+mizar_heap_start:
+    .quad 0
+mizar_heap_ptr:
+    .quad 0
+mizar_heap_limit:
+    .quad 0
+mizar_gc_bitmap:
+    .quad 0
+mizar_gc_stack:
+    .quad 0
+mizar_gc_free_list:
+    .quad 0
+mizar_gc_count:
+    .quad 0
+`
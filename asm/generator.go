@@ -3,87 +3,752 @@ package asm
 import (
 	"errors"
 	"fmt"
+	"math"
 	"mizar/ast"
+	"mizar/diag"
+	"mizar/utils"
+	"strconv"
 )
 
 var (
 	ClassNotDefineErr  = errors.New("Class not define, class name:")
-	MethodNotDefineErr = errors.New("Method not define, method name: ")
+	MethodNotDefineErr = errors.New("Method not define, method name:")
+	UnresolvedErr      = errors.New("Unresolved reference, name:")
+	UnsupportedErr     = errors.New("Not supported yet:")
+	OutsideLoopErr     = errors.New("Break or continue outside loop")
 )
 
-func Generate(tu *ast.TranslationUnit) string {
-	visitor := &Generator{
-		o: newOutput(),
+var errCodes = map[error]diag.Code{
+	ClassNotDefineErr:  "E0401",
+	MethodNotDefineErr: "E0402",
+	UnresolvedErr:      "E0403",
+	UnsupportedErr:     "E0404",
+	OutsideLoopErr:     "E0405",
+}
+
+// 将经过check的编译单元翻译为x86-64 AT&T语法的汇编代码, 可以直接使用 as + ld 生成可执行文件
+//
+// 调用约定: 调用者从右到左压入参数, 最后压入this(通过类名调用时为0), 由调用者清理栈;
+// 返回值放在%rax中; 被调用者只需保存%rbp, 其余寄存器都可以随意使用
+// 值表示: 所有值都占8字节, Int为int64, Double为IEEE 754位模式, Bool为0/1, Char为Unicode码点,
+// String为指向 [8字节长度][字节] 的指针, 对象为指向 [类描述符指针][属性...] 的指针, null为0;
+// 堆上的对象和字符串之前还有8字节的头部, 由垃圾回收使用, 见gcCode
+func Generate(tu *ast.TranslationUnit) (code string, err error) {
+	g := &Generator{
+		tu:      tu,
+		o:       newOutput(),
+		data:    newOutput(),
+		strings: make(map[string]string),
+		layouts: make(map[*ast.Class]*classLayout),
+	}
+	if tu.Accept(g); g.err != nil {
+		err = g.err
+		return
 	}
-	tu.Accept(visitor)
-	return ""
+
+	code = g.o.String() + g.data.String() + runtimeCode + gcCode
+
+	return
 }
 
+// 各个回调自行决定子节点的生成顺序, 因此都返回false; 出错后通过Pre停止遍历
 type Generator struct {
-	o *Output
+	ast.BaseVisitor
+	tu       *ast.TranslationUnit
+	o        *Output // .text
+	data     *Output // .rodata
+	labelNum int
+	strings  map[string]string // 字符串字面量 -> label
+	layouts  map[*ast.Class]*classLayout
+	class    *ast.Class
+	frame    *frame
+	err      error
 }
 
-func (g *Generator) Visit(node ast.Node) {
+// 当前正在生成的方法的栈帧
+type frame struct {
+	slots       map[interface{}]int // 形参、局部变量声明 -> 相对%rbp的偏移
+	size        int
+	returnLabel string
+	loops       []loop
+}
+
+type loop struct {
+	continueLabel string
+	breakLabel    string
+}
 
+func (g *Generator) Pre(node ast.Node) bool {
+	return g.err == nil
 }
 
-func (g *Generator) visitTranslationUnit(tu *ast.TranslationUnit) (err error) {
-	g.o.Directive("file", `"hello.c"`)
+func (g *Generator) Post(node ast.Node) {}
 
-	mainClass, exists := tu.ClassMap["Main"]
+func (g *Generator) VisitTranslationUnit(tu *ast.TranslationUnit) bool {
+	// 入口类是入口包中的Main
+	mainName := ast.QualifiedName(tu.Package, "Main")
+	mainClass, exists := tu.ClassMap[mainName]
 	if !exists {
-		err = fmt.Errorf("%w Main", ClassNotDefineErr)
-		return
+		g.err = g.errorf(tu.Span, ClassNotDefineErr, mainName)
+		return false
 	}
 
-	mainClass.Accept(g)
+	mainMethod, exists := mainClass.MethodDefinitionMap["main"][""]
+	if !exists {
+		g.err = g.errorf(mainClass.Pos.Extend(mainClass.Name), MethodNotDefineErr, "main")
+		return false
+	}
 
-	return
+	mainConstructor, exists := mainClass.DefaultConstructor()
+	if !exists {
+		g.err = g.errorf(mainClass.Pos.Extend(mainClass.Name), MethodNotDefineErr, mainClass.Name+"()")
+		return false
+	}
+
+	g.data.Directive("section", ".rodata")
+
+	// 入口: 创建Main对象, 调用无参构造函数和main, Int main()的返回值作为进程退出码
+	g.o.Directive("text", "")
+	g.o.Directive("globl", "_start")
+	g.o.Label("_start")
+	g.o.Instruction("call", "mizar_init")
+	g.o.Instruction("movq", imm(int64(g.layout(mainClass).size)), "%rdi")
+	g.o.Instruction("call", "mizar_alloc")
+	g.o.Instruction("leaq", rip(classLabel(mainClass)), "%rcx")
+	g.o.Instruction("movq", "%rcx", "(%rax)")
+	g.o.Instruction("pushq", "%rax")
+	g.o.Instruction("call", constructorLabel(mainClass, mainConstructor))
+	g.o.Instruction("call", methodLabel(mainClass, mainMethod))
+	g.o.Instruction("addq", imm(wordSize), "%rsp")
+	if mainMethod.Type == "Int" {
+		g.o.Instruction("movq", "%rax", "%rdi")
+	} else {
+		g.o.Instruction("xorq", "%rdi", "%rdi")
+	}
+	g.o.Instruction("jmp", "mizar_exit")
+
+	for _, name := range utils.SortedKeys(tu.InterfaceMap) {
+		inter := tu.InterfaceMap[name]
+		g.data.Directive("p2align", "3")
+		g.data.Label(interfaceLabel(inter))
+		g.data.Directive("quad", g.stringLabel(inter.Name))
+	}
+
+	for _, class := range g.reachableClasses(mainClass) {
+		class.Accept(g)
+	}
+
+	return false
 }
 
-func (g *Generator) visitClass(class *ast.Class) (err error) {
-	if class.Name == "Main" {
-		if method, exists := class.MethodDefinitionMap["main"][""]; exists {
-			g.o.Label("main:")
-			method.Accept(g)
-		} else {
-			err = fmt.Errorf("%w main", MethodNotDefineErr)
-		}
+func (g *Generator) VisitClass(class *ast.Class) bool {
+	l := g.layout(class)
+
+	// 字符串字面量也写入.rodata, 需要在描述符之前生成
+	name := g.stringLabel(class.Name)
+	interfaces := g.interfaces(l)
+
+	g.data.Directive("p2align", "3")
+	g.data.Label(classLabel(class))
+	g.data.Directive("quad", name)
+	g.data.Directive("quad", strconv.Itoa(l.size))
+	if l.parent != nil {
+		g.data.Directive("quad", classLabel(l.parent.class))
+	} else {
+		g.data.Directive("quad", "0")
+	}
+	if len(interfaces) > 0 {
+		g.data.Directive("quad", itableLabel(class))
 	} else {
+		g.data.Directive("quad", "0")
+	}
+	for _, m := range l.vtable {
+		g.data.Directive("quad", m.label())
+	}
 
+	if len(interfaces) > 0 {
+		g.data.Label(itableLabel(class))
+		for _, inter := range interfaces {
+			g.data.Directive("quad", interfaceLabel(inter))
+			g.data.Directive("quad", interfaceTableLabel(class, inter))
+		}
+		g.data.Directive("quad", "0")
+		for _, inter := range interfaces {
+			g.data.Label(interfaceTableLabel(class, inter))
+			for _, im := range interfaceMethods(inter) {
+				if slot, exists := l.slots[im.Signature()]; exists {
+					g.data.Directive("quad", l.vtable[slot].label())
+				} else {
+					g.data.Directive("quad", "0")
+				}
+			}
+		}
 	}
 
-	return
+	g.class = class
+	if len(class.ConstructorMap) == 0 {
+		if g.constructor(nil); g.err != nil {
+			return false
+		}
+	}
+	for _, key := range utils.SortedKeys(class.ConstructorMap) {
+		if g.constructor(class.ConstructorMap[key]); g.err != nil {
+			return false
+		}
+	}
+	for _, name := range utils.SortedKeys(class.MethodDefinitionMap) {
+		for _, key := range utils.SortedKeys(class.MethodDefinitionMap[name]) {
+			class.MethodDefinitionMap[name][key].Accept(g)
+		}
+	}
+	g.class = nil
+
+	return false
 }
 
-func (g *Generator) visitMethod(method *ast.MethodDefinition) (err error) {
-	if method.Name == "main" {
+// native方法由运行时实现, 符号名与普通方法相同
+func (g *Generator) VisitMethodDefinition(method *ast.MethodDefinition) bool {
+	if method.IsNative {
+		if label := methodLabel(g.class, method); !natives[label] {
+			g.err = g.errorf(method.Pos.Extend(method.Name), UnsupportedErr, "native method "+label)
+		}
+		return false
+	}
+
+	g.function(methodLabel(g.class, method), method.ParameterList, method.Block, func() {
 		method.Block.Accept(g)
-	} else {
+	})
 
+	return false
+}
+
+// 构造函数依次: 调用父类的构造函数(第一条语句super(...), 或者隐式调用无参构造函数),
+// 按声明顺序执行本类的属性初始值, 执行其余语句; md为nil时生成默认构造函数
+//
+// 基本类型的native构造函数在new表达式处直接求值, 不需要生成
+func (g *Generator) constructor(md *ast.MethodDefinition) {
+	if md != nil && md.IsNative {
+		if !ast.IsPrimitiveType(g.class.Name) {
+			g.err = g.errorf(md.Pos.Extend(md.Name), UnsupportedErr, "native constructor "+constructorLabel(g.class, md))
+		}
+		return
 	}
 
-	return
+	var (
+		params []*ast.Parameter
+		block  *ast.Block
+		stmts  []*ast.Statement
+	)
+	if md != nil {
+		params, block, stmts = md.ParameterList, md.Block, md.Block.StatementList
+	}
+
+	g.function(constructorLabel(g.class, md), params, block, func() {
+		if len(stmts) > 0 && stmts[0].Type == ast.StatementTypeSuperCall {
+			if stmts[0].Accept(g); g.err != nil {
+				return
+			}
+			stmts = stmts[1:]
+		} else if parent := g.layout(g.class).parent; parent != nil {
+			constructor, _ := parent.class.DefaultConstructor()
+			g.o.Instruction("pushq", mem(2*wordSize, "%rbp"))
+			g.o.Instruction("call", constructorLabel(parent.class, constructor))
+			g.o.Instruction("addq", imm(wordSize), "%rsp")
+		}
+
+		l := g.layout(g.class)
+		for _, pd := range g.class.PropertyList() {
+			if pd.Expr == nil {
+				continue
+			}
+			if pd.Expr.Accept(g); g.err != nil {
+				return
+			}
+			g.o.Instruction("movq", mem(2*wordSize, "%rbp"), "%rcx")
+			g.o.Instruction("movq", "%rax", mem(l.offsets[pd], "%rcx"))
+		}
+
+		for _, stmt := range stmts {
+			if stmt.Accept(g); g.err != nil {
+				return
+			}
+		}
+	})
 }
 
-func (g *Generator) visitBlock(block *ast.Block) (err error) {
-	for _, stmt := range block.StatementList {
-		stmt.Accept(g)
+// 生成函数的序言和结尾, body生成函数体; block用于收集局部变量, 可以为nil
+func (g *Generator) function(label string, params []*ast.Parameter, block *ast.Block, body func()) {
+	g.frame = &frame{slots: make(map[interface{}]int), returnLabel: g.newLabel()}
+	defer func() { g.frame = nil }()
+
+	// 16(%rbp)是this, 之后依次是各个参数
+	for i, param := range params {
+		g.frame.slots[param] = 2*wordSize + (i+1)*wordSize
 	}
+	var locals []interface{}
+	if block != nil {
+		locals = collectLocals(block)
+	}
+	for i, local := range locals {
+		g.frame.slots[local] = -(i + 1) * wordSize
+	}
+	g.frame.size = (len(locals)*wordSize + 15) / 16 * 16
 
-	return
+	g.o.Label(label)
+	g.o.Instruction("pushq", "%rbp")
+	g.o.Instruction("movq", "%rsp", "%rbp")
+	if g.frame.size > 0 {
+		g.o.Instruction("subq", imm(int64(g.frame.size)), "%rsp")
+	}
+	for i := range locals {
+		g.o.Instruction("movq", imm(0), mem(-(i+1)*wordSize, "%rbp"))
+	}
+
+	if body(); g.err != nil {
+		return
+	}
+	// 执行到函数末尾而没有return时返回零值, 与解释器一致
+	g.o.Instruction("xorq", "%rax", "%rax")
+
+	g.o.Label(g.frame.returnLabel)
+	g.o.Instruction("movq", "%rbp", "%rsp")
+	g.o.Instruction("popq", "%rbp")
+	g.o.Instruction("ret")
+}
+
+func (g *Generator) VisitVarDeclarationStatement(stmt *ast.VarDeclarationStatement) bool {
+	g.o.Instruction("movq", imm(0), mem(g.frame.slots[stmt], "%rbp"))
+	return false
 }
 
-func (g *Generator) visitStatement(stmt *ast.Statement) (err error) {
+func (g *Generator) VisitVarAssignStatement(stmt *ast.VarAssignStatement) bool {
+	if stmt.Expression.Accept(g); g.err != nil {
+		return false
+	}
+
 	switch stmt.Type {
-	case ast.StatementTypeBreak:
-		stmt.BreakStatement.Accept(g)
+	case ast.VarAssignStatementTypeVar:
+		g.o.Instruction("movq", "%rax", mem(g.frame.slots[stmt], "%rbp"))
+	case ast.VarAssignStatementTypeVarCall:
+		g.store(stmt.VarCallExpression)
 	}
 
-	return
+	return false
 }
 
-func (g *Generator) visitBreakStatement(stmt *ast.Statement) (err error) {
+// 父类的构造函数以当前的this为接收者
+func (g *Generator) VisitSuperCallStatement(stmt *ast.SuperCallStatement) bool {
+	parent := g.layout(g.class).parent
+	if parent == nil {
+		g.err = g.errorf(stmt.Span, UnresolvedErr, "super")
+		return false
+	}
 
-	return
+	if g.pushArgs(stmt.ArgumentList); g.err != nil {
+		return false
+	}
+	g.o.Instruction("pushq", mem(2*wordSize, "%rbp"))
+	g.o.Instruction("call", constructorLabel(parent.class, stmt.Constructor))
+	g.o.Instruction("addq", imm(int64((len(stmt.ArgumentList)+1)*wordSize)), "%rsp")
+
+	return false
+}
+
+func (g *Generator) VisitWhileStatement(stmt *ast.WhileStatement) bool {
+	condLabel, endLabel := g.newLabel(), g.newLabel()
+
+	g.o.Label(condLabel)
+	if stmt.Expression.Accept(g); g.err != nil {
+		return false
+	}
+	g.o.Instruction("testq", "%rax", "%rax")
+	g.o.Instruction("jz", endLabel)
+
+	g.frame.loops = append(g.frame.loops, loop{continueLabel: condLabel, breakLabel: endLabel})
+	stmt.Block.Accept(g)
+	g.frame.loops = g.frame.loops[:len(g.frame.loops)-1]
+	if g.err != nil {
+		return false
+	}
+
+	g.o.Instruction("jmp", condLabel)
+	g.o.Label(endLabel)
+
+	return false
+}
+
+func (g *Generator) VisitForStatement(stmt *ast.ForStatement) bool {
+	condLabel, postLabel, endLabel := g.newLabel(), g.newLabel(), g.newLabel()
+
+	if stmt.InitExpression.Accept(g); g.err != nil {
+		return false
+	}
+
+	g.o.Label(condLabel)
+	if stmt.CondExpression != nil {
+		if stmt.CondExpression.Accept(g); g.err != nil {
+			return false
+		}
+		g.o.Instruction("testq", "%rax", "%rax")
+		g.o.Instruction("jz", endLabel)
+	}
+
+	g.frame.loops = append(g.frame.loops, loop{continueLabel: postLabel, breakLabel: endLabel})
+	stmt.Block.Accept(g)
+	g.frame.loops = g.frame.loops[:len(g.frame.loops)-1]
+	if g.err != nil {
+		return false
+	}
+
+	g.o.Label(postLabel)
+	if stmt.PostExpression.Accept(g); g.err != nil {
+		return false
+	}
+	g.o.Instruction("jmp", condLabel)
+	g.o.Label(endLabel)
+
+	return false
+}
+
+func (g *Generator) VisitIfStatement(stmt *ast.IfStatement) bool {
+	elseLabel, endLabel := g.newLabel(), g.newLabel()
+
+	if stmt.CondExpression.Accept(g); g.err != nil {
+		return false
+	}
+	g.o.Instruction("testq", "%rax", "%rax")
+	g.o.Instruction("jz", elseLabel)
+	if stmt.IfBlock.Accept(g); g.err != nil {
+		return false
+	}
+	g.o.Instruction("jmp", endLabel)
+	g.o.Label(elseLabel)
+	if stmt.ElseBlock.Accept(g); g.err != nil {
+		return false
+	}
+	g.o.Label(endLabel)
+
+	return false
+}
+
+// 类型检查已经报告了循环之外的break和continue, 这里的OutsideLoopErr只用于没有经过检查的语法树
+func (g *Generator) VisitBreakStatement(stmt *ast.BreakStatement) bool {
+	if len(g.frame.loops) == 0 {
+		g.err = g.errorf(stmt.Span, OutsideLoopErr, "")
+		return false
+	}
+	if stmt.Expression.Accept(g); g.err != nil {
+		return false
+	}
+	g.o.Instruction("jmp", g.frame.loops[len(g.frame.loops)-1].breakLabel)
+
+	return false
+}
+
+func (g *Generator) VisitContinueStatement(stmt *ast.ContinueStatement) bool {
+	if len(g.frame.loops) == 0 {
+		g.err = g.errorf(stmt.Span, OutsideLoopErr, "")
+		return false
+	}
+	if stmt.Expression.Accept(g); g.err != nil {
+		return false
+	}
+	g.o.Instruction("jmp", g.frame.loops[len(g.frame.loops)-1].continueLabel)
+
+	return false
+}
+
+func (g *Generator) VisitReturnStatement(stmt *ast.ReturnStatement) bool {
+	if stmt.Expression.Accept(g); g.err != nil {
+		return false
+	}
+	g.o.Instruction("jmp", g.frame.returnLabel)
+
+	return false
+}
+
+// 表达式的值放在%rax中, new和调用表达式交给子节点的回调生成
+func (g *Generator) VisitExpression(expr *ast.Expression) bool {
+	switch expr.Type {
+	case ast.ExpressionTypeString:
+		g.o.Instruction("leaq", rip(g.stringLabel(expr.StringLiteral)), "%rax")
+	case ast.ExpressionTypeInt:
+		g.loadImm(expr.IntLiteral)
+	case ast.ExpressionTypeChar:
+		g.loadImm(int64(expr.CharLiteral))
+	case ast.ExpressionTypeDouble:
+		g.loadImm(int64(math.Float64bits(expr.DoubleLiteral)))
+	case ast.ExpressionTypeBool:
+		if expr.BoolLiteral {
+			g.loadImm(1)
+		} else {
+			g.loadImm(0)
+		}
+	case ast.ExpressionTypeNull:
+		g.loadImm(0)
+	}
+
+	return true
+}
+
+// 先求值实参, 再分配对象并调用构造函数, 结果为新对象
+func (g *Generator) VisitNewObjectExpression(expr *ast.NewObjectExpression) bool {
+	if expr.Class == nil {
+		g.err = g.errorf(expr.Pos.Extend(expr.Name), UnresolvedErr, expr.Name)
+		return false
+	}
+
+	// 基本类型的值不在堆上分配: new Int(1) 就是1, new Int() 是零值
+	if ast.IsPrimitiveType(expr.Class.Name) {
+		switch {
+		case len(expr.ArgumentList) > 0:
+			expr.ArgumentList[0].Accept(g)
+		case expr.Class.Name == "String":
+			g.o.Instruction("leaq", rip(g.stringLabel("")), "%rax")
+		default:
+			g.loadImm(0)
+		}
+		return false
+	}
+
+	if g.pushArgs(expr.ArgumentList); g.err != nil {
+		return false
+	}
+
+	g.o.Instruction("movq", imm(int64(g.layout(expr.Class).size)), "%rdi")
+	g.o.Instruction("call", "mizar_alloc")
+	g.o.Instruction("leaq", rip(classLabel(expr.Class)), "%rcx")
+	g.o.Instruction("movq", "%rcx", "(%rax)")
+	g.o.Instruction("pushq", "%rax")
+	g.o.Instruction("call", constructorLabel(expr.Class, expr.Constructor))
+	g.o.Instruction("popq", "%rax")
+	if len(expr.ArgumentList) > 0 {
+		g.o.Instruction("addq", imm(int64(len(expr.ArgumentList)*wordSize)), "%rsp")
+	}
+
+	return false
+}
+
+func (g *Generator) VisitVarCallExpression(expr *ast.VarCallExpression) bool {
+	binding := expr.Binding
+	if binding == nil {
+		g.err = g.errorf(expr.Span, UnresolvedErr, expr.Var)
+		return false
+	}
+
+	switch binding.Type {
+	case ast.VarBindingTypeThis:
+		g.o.Instruction("movq", mem(2*wordSize, "%rbp"), "%rax")
+	case ast.VarBindingTypeClass:
+		g.loadImm(0)
+	case ast.VarBindingTypeParameter:
+		g.o.Instruction("movq", mem(g.frame.slots[binding.Parameter], "%rbp"), "%rax")
+	case ast.VarBindingTypeLocal:
+		g.o.Instruction("movq", mem(g.frame.slots[binding.Local()], "%rbp"), "%rax")
+	case ast.VarBindingTypeProperty:
+		if g.loadOwner(expr); g.err != nil {
+			return false
+		}
+		g.o.Instruction("movq", mem(g.layout(binding.Class).offsets[binding.PropertyDefinition], "%rax"), "%rax")
+	}
+
+	return false
+}
+
+// 将%rax的值存入变量
+func (g *Generator) store(expr *ast.VarCallExpression) {
+	binding := expr.Binding
+	if binding == nil {
+		g.err = g.errorf(expr.Span, UnresolvedErr, expr.Var)
+		return
+	}
+
+	switch binding.Type {
+	case ast.VarBindingTypeParameter:
+		g.o.Instruction("movq", "%rax", mem(g.frame.slots[binding.Parameter], "%rbp"))
+	case ast.VarBindingTypeLocal:
+		g.o.Instruction("movq", "%rax", mem(g.frame.slots[binding.Local()], "%rbp"))
+	case ast.VarBindingTypeProperty:
+		g.o.Instruction("pushq", "%rax")
+		if g.loadOwner(expr); g.err != nil {
+			return
+		}
+		g.o.Instruction("popq", "%rcx")
+		g.o.Instruction("movq", "%rcx", mem(g.layout(binding.Class).offsets[binding.PropertyDefinition], "%rax"))
+	default:
+		g.err = g.errorf(expr.Span, UnsupportedErr, "assign to "+expr.Var)
+	}
+}
+
+// 将属性所属的对象放入%rax, 没有接收者时为this
+func (g *Generator) loadOwner(expr *ast.VarCallExpression) {
+	if expr.Type == ast.VarCallExpressionTypeCall {
+		expr.CallExpression.Accept(g)
+		return
+	}
+
+	g.o.Instruction("movq", mem(2*wordSize, "%rbp"), "%rax")
+}
+
+// 通过类名调用、调用private方法以及基本类型的方法时直接调用, 否则按接收者的类描述符动态分派:
+// 类方法通过虚方法表, 接口方法先由mizar_itable_lookup找到接口方法表
+func (g *Generator) VisitMethodCallExpression(expr *ast.MethodCallExpression) bool {
+	binding := expr.Binding
+	if binding == nil {
+		g.err = g.errorf(expr.Pos.Extend(expr.Name), UnresolvedErr, expr.Name)
+		return false
+	}
+
+	if g.pushArgs(expr.ArgumentList); g.err != nil {
+		return false
+	}
+
+	if expr.CallExpression.Accept(g); g.err != nil {
+		return false
+	}
+	g.o.Instruction("pushq", "%rax")
+
+	switch {
+	case binding.Type == ast.MethodBindingTypeInterface:
+		index := -1
+		for i, im := range interfaceMethods(binding.Interface) {
+			if im == binding.InterfaceMethod {
+				index = i
+			}
+		}
+		if index < 0 {
+			g.err = g.errorf(expr.Pos.Extend(expr.Name), MethodNotDefineErr, binding.Interface.Name+"."+expr.Name)
+			return false
+		}
+		g.o.Instruction("movq", "%rax", "%rdi")
+		g.o.Instruction("leaq", rip(interfaceLabel(binding.Interface)), "%rsi")
+		g.o.Instruction("call", "mizar_itable_lookup")
+		g.o.Instruction("call", "*"+mem(index*wordSize, "%rax"))
+	case isClassReceiver(expr.CallExpression) || binding.MethodDefinition.ModifierType == ast.ModifierPrivate || ast.IsPrimitiveType(binding.Class.Name):
+		g.o.Instruction("call", methodLabel(binding.Class, binding.MethodDefinition))
+	default:
+		slot, exists := g.layout(binding.Class).slots[binding.MethodDefinition.Signature()]
+		if !exists {
+			g.err = g.errorf(expr.Pos.Extend(expr.Name), MethodNotDefineErr, binding.Class.Name+"."+expr.Name)
+			return false
+		}
+		g.o.Instruction("movq", "(%rax)", "%rcx")
+		g.o.Instruction("call", "*"+mem(vtableOffset+slot*wordSize, "%rcx"))
+	}
+	g.o.Instruction("addq", imm(int64((len(expr.ArgumentList)+1)*wordSize)), "%rsp")
+
+	return false
+}
+
+// 接收者是类名, 例如 Out.printString(""), 此时this为0, 不能动态分派
+func isClassReceiver(callExpr *ast.CallExpression) bool {
+	if callExpr.Type != ast.CallExpressionTypeValCall {
+		return false
+	}
+	binding := callExpr.VarCallExpression.Binding
+
+	return binding != nil && binding.Type == ast.VarBindingTypeClass
+}
+
+// 从右到左求值并压入实参
+func (g *Generator) pushArgs(args []*ast.Expression) {
+	for i := len(args) - 1; i >= 0; i-- {
+		if args[i].Accept(g); g.err != nil {
+			return
+		}
+		g.o.Instruction("pushq", "%rax")
+	}
+}
+
+func (g *Generator) errorf(span ast.Span, err error, detail string) *diag.Diagnostic {
+	return diag.Errorf(errCodes[err], span, err, "%s", detail)
+}
+
+func (g *Generator) loadImm(v int64) {
+	if v >= math.MinInt32 && v <= math.MaxInt32 {
+		g.o.Instruction("movq", imm(v), "%rax")
+	} else {
+		g.o.Instruction("movabsq", imm(v), "%rax")
+	}
+}
+
+// 字符串字面量放在.rodata中, 布局为 [8字节长度][字节]
+func (g *Generator) stringLabel(s string) string {
+	if label, exists := g.strings[s]; exists {
+		return label
+	}
+
+	label := fmt.Sprintf(".LS%d", len(g.strings))
+	g.strings[s] = label
+	g.data.Directive("p2align", "3")
+	g.data.Label(label)
+	g.data.Directive("quad", strconv.Itoa(len(s)))
+	g.data.Directive("ascii", asciiLiteral(s))
+
+	return label
+}
+
+func (g *Generator) newLabel() string {
+	g.labelNum++
+	return fmt.Sprintf(".L%d", g.labelNum)
+}
+
+// 按声明顺序收集块中的局部变量声明
+func collectLocals(block *ast.Block) []interface{} {
+	c := &localCollector{}
+	ast.Walk(c, block)
+	return c.locals
+}
+
+type localCollector struct {
+	ast.BaseVisitor
+	locals []interface{}
+}
+
+func (c *localCollector) VisitVarDeclarationStatement(stmt *ast.VarDeclarationStatement) bool {
+	c.locals = append(c.locals, stmt)
+	return false
+}
+
+func (c *localCollector) VisitVarAssignStatement(stmt *ast.VarAssignStatement) bool {
+	if stmt.Type == ast.VarAssignStatementTypeVar {
+		c.locals = append(c.locals, stmt)
+	}
+	return false
+}
+
+// 局部变量只能声明在语句中, 不需要进入表达式
+func (c *localCollector) VisitExpression(expr *ast.Expression) bool {
+	return false
+}
+
+func imm(v int64) string {
+	return fmt.Sprintf("$%d", v)
+}
+
+func mem(offset int, base string) string {
+	return fmt.Sprintf("%d(%s)", offset, base)
+}
+
+func rip(label string) string {
+	return label + "(%rip)"
+}
+
+// GAS .ascii 字面量, 非可打印字节用八进制转义
+func asciiLiteral(s string) string {
+	buf := []byte{'"'}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			buf = append(buf, '\\', c)
+		case c >= 0x20 && c < 0x7f:
+			buf = append(buf, c)
+		default:
+			buf = append(buf, []byte(fmt.Sprintf("\\%03o", c))...)
+		}
+	}
+	buf = append(buf, '"')
+
+	return string(buf)
 }
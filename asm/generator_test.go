@@ -0,0 +1,613 @@
+package asm
+
+import (
+	"errors"
+	"io/ioutil"
+	"mizar/ast"
+	"mizar/check"
+	"mizar/interp"
+	"mizar/lexer"
+	"mizar/log"
+	"mizar/parser"
+	"mizar/prelude"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 标准库附加在源码之后, 与loader加载的程序一样可以使用Out等类; ignore中的错误不影响编译
+func compile(t *testing.T, source string, ignore ...error) *ast.TranslationUnit {
+	log.Init(logrus.ErrorLevel)
+	tu, err := parser.NewParser().Parse(lexer.NewLexer(source + prelude.Source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	errs := check.Resolve(tu)
+	for _, err := range check.TypeCheck(tu) {
+		if !isAny(err, ignore) {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	return tu
+}
+
+// 运行生成的程序, 并以解释器的结果作为参照: 两者的退出码和标准输出需要相同
+func run(t *testing.T, source string, ignore ...error) (int, string) {
+	exitCode, out := runNative(t, source, ignore...)
+
+	var expect strings.Builder
+	expectCode, err := interp.Run(compile(t, source, ignore...), strings.NewReader(""), &expect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exitCode != expectCode || out != expect.String() {
+		t.Errorf("asm: %d %q, interp: %d %q", exitCode, out, expectCode, expect.String())
+	}
+
+	return exitCode, out
+}
+
+// 汇编、链接并运行, 返回进程的退出码和标准输出
+func runNative(t *testing.T, source string, ignore ...error) (int, string) {
+	for _, tool := range []string{"as", "ld"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not found", tool)
+		}
+	}
+
+	code, err := Generate(compile(t, source, ignore...))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "mizar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	asmFile := filepath.Join(dir, "main.s")
+	objFile := filepath.Join(dir, "main.o")
+	exeFile := filepath.Join(dir, "main")
+	if err = ioutil.WriteFile(asmFile, []byte(code), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command("as", "-o", objFile, asmFile).CombinedOutput(); err != nil {
+		t.Fatal(err, string(out))
+	}
+	if out, err := exec.Command("ld", "-o", exeFile, objFile).CombinedOutput(); err != nil {
+		t.Fatal(err, string(out))
+	}
+
+	cmd := exec.Command(exeFile)
+	out, err := cmd.Output()
+	if _, ok := err.(*exec.ExitError); err != nil && !ok {
+		t.Fatal(err)
+	}
+
+	return cmd.ProcessState.ExitCode(), string(out)
+}
+
+func isAny(err error, targets []error) bool {
+	for _, target := range targets {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestGenerateMissingMain(t *testing.T) {
+	if _, err := Generate(compile(t, "class C {}")); err == nil {
+		t.Error("expect error")
+	}
+}
+
+func TestGenerateRun(t *testing.T) {
+	exitCode, _ := run(t, `
+class Counter {
+    public Int count;
+    public Bool done;
+
+    public void set(Int count, Bool done) {
+        this.count = count;
+        this.done = done;
+    }
+
+    public Int get() {
+        return count;
+    }
+
+    public Bool running() {
+        if (done) {
+            return false;
+        }
+        return true;
+    }
+}
+
+class Main {
+    public Counter counter;
+
+    public Int main() {
+        Counter c = new Counter();
+        this.counter = c;
+        c.set(7, false);
+        Int result = 1;
+        while (this.counter.running()) {
+            result = this.counter.get();
+            c.set(42, true);
+        }
+        for (;;) {
+            if (c.done) {
+                break;
+            }
+        }
+        return result;
+    }
+}
+`)
+	if exitCode != 7 {
+		t.Error(exitCode)
+	}
+}
+
+// 类型检查会报告缺少返回语句, 忽略该错误时两个后端都返回零值
+func TestGenerateMissingReturn(t *testing.T) {
+	exitCode, out := run(t, `
+class A {
+    public Bool b(Int x) {
+        if (x > 1) {
+            return true;
+        }
+    }
+
+    public Int n(Int x) {
+        while (x > 0) {
+            return x;
+        }
+    }
+
+    public Double d() {
+        Int x = 1;
+    }
+
+    public String s() {
+    }
+}
+
+class Main {
+    public Int main() {
+        A a = new A();
+        if (a.b(0)) {
+            Out.printString("true");
+        } else {
+            Out.printString("false");
+        }
+        Out.printInt(a.n(0) + a.n(2));
+        Out.printInt(a.d().toInt());
+        if (a.s() == null) {
+            Out.printString("null");
+        }
+        return a.n(0);
+    }
+}
+`, check.MissingReturnErr)
+	if exitCode != 0 || out != "false20null" {
+		t.Errorf("%d %q", exitCode, out)
+	}
+}
+
+func TestGenerateOperator(t *testing.T) {
+	exitCode, _ := run(t, `
+class Main {
+    public Int main() {
+        Int result = 0;
+        if (1 + 2 * 3 == 7 && 17 / 5 == 3 && 17 % 5 == 2 && -(2 - 5) == 3) {
+            result = result + 1;
+        }
+        if (1.5 * 2 == 3 && 1 < 1.5 && !(2.5 <= 2) && 7 / 2.0 == 3.5) {
+            result = result + 2;
+        }
+        if ("ab" + "c" == "abc" && "x" != "xy" && "a" != "b") {
+            result = result + 4;
+        }
+        Bool never = false;
+        if (true || this.fail() && never) {
+            result = result + 8;
+        }
+        Int i = 0;
+        while (i < 10) {
+            i = i + 1;
+        }
+        return result + i * 16;
+    }
+
+    public Bool fail() {
+        return 1 / 0 == 0;
+    }
+}
+`)
+	if exitCode != 15+160 {
+		t.Error(exitCode)
+	}
+}
+
+func TestGenerateLiteral(t *testing.T) {
+	exitCode, _ := run(t, `
+class Main {
+    public Int main() {
+        Char c = '中';
+        Int result = 0;
+        if ('a' < 'b' && '\n' == '\u{a}' && c == '\u{4e2d}') {
+            result = result + 1;
+        }
+        if ("a\tb" == "a\u{9}b" && "" + "\"中\"" == "\"\u{4e2d}\"" && "" != "\0") {
+            result = result + 2;
+        }
+        Int min = -9223372036854775808;
+        if (min < 0 && min - 1 > 0 && min.toString() == "-9223372036854775808") {
+            result = result + 4;
+        }
+        return result;
+    }
+}
+`)
+	if exitCode != 7 {
+		t.Error(exitCode)
+	}
+}
+
+func TestGenerateConstructor(t *testing.T) {
+	exitCode, _ := run(t, `
+class Base {
+    public Int trace = 1;
+    public Int a;
+
+    public void Base(Int a) {
+        this.trace = this.trace * 10 + 2;
+        this.a = a;
+    }
+
+    public void Base() {
+        this.trace = 9;
+    }
+}
+
+class Derived extends Base {
+    public Int b = this.trace * 10 + 3;
+    public Int c = 5;
+
+    public void Derived(Int a, Int b) {
+        super(a);
+        this.b = this.b * 10 + b;
+    }
+}
+
+class Plain extends Base {
+    public Int d = 4;
+}
+
+class Main {
+    public Int main() {
+        Derived d = new Derived(6, 4);
+        Plain p = new Plain();
+        if (d.trace != 12 || d.b != 1234 || d.a != 6 || d.c != 5) {
+            return 1;
+        }
+        if (p.trace != 9 || p.d != 4 || p.a != 0) {
+            return 2;
+        }
+        return 0;
+    }
+}
+`)
+	if exitCode != 0 {
+		t.Error(exitCode)
+	}
+}
+
+func TestGenerateVirtualDispatch(t *testing.T) {
+	exitCode, _ := run(t, `
+interface Shape {
+    Int area();
+    Int sides();
+}
+
+abstract class Polygon implements Shape {
+    public Int scale = 1;
+
+    public Int sides() {
+        return 0;
+    }
+
+    abstract Int area();
+
+    public Int describe() {
+        return this.sides() * 100 + this.area();
+    }
+}
+
+class Square extends Polygon {
+    public Int a;
+
+    public void Square(Int a) {
+        this.a = a;
+    }
+
+    public Int sides() {
+        return 4;
+    }
+
+    public Int area() {
+        return this.a * this.a * this.scale;
+    }
+}
+
+class Triangle extends Polygon {
+    public Int area() {
+        return this.secret();
+    }
+
+    private Int secret() {
+        return 7;
+    }
+}
+
+class Main {
+    public Int main() {
+        Polygon p = new Square(3);
+        Shape s = new Triangle();
+        if (p.describe() != 409) {
+            return 1;
+        }
+        if (s.area() != 7 || s.sides() != 0) {
+            return 2;
+        }
+        s = p;
+        if (s.area() != 9 || s.sides() != 4) {
+            return 3;
+        }
+        return 0;
+    }
+}
+`)
+	if exitCode != 0 {
+		t.Error(exitCode)
+	}
+}
+
+// 方法名与描述符、方法表的名字相同时符号不能冲突
+func TestGenerateLabel(t *testing.T) {
+	exitCode, _ := run(t, `
+interface I {
+    Int itable();
+    Int itable(I i);
+    Int vtable();
+    Int desc();
+}
+
+class A implements I {
+    public Int itable() {
+        return 1;
+    }
+
+    public Int itable(I i) {
+        return 2;
+    }
+
+    public Int vtable() {
+        return 3;
+    }
+
+    public Int desc() {
+        return 4;
+    }
+}
+
+class Main {
+    public Int main() {
+        I i = new A();
+        return i.itable() * 1000 + i.itable(i) * 100 + i.vtable() * 10 + i.desc();
+    }
+}
+`)
+	if exitCode != 1234%256 {
+		t.Error(exitCode)
+	}
+}
+
+func TestGeneratePrelude(t *testing.T) {
+	exitCode, out := run(t, `
+class Main {
+    public Int main() {
+        Int i = new Int(41);
+        i = i.Increment();
+        Out.printInt(i);
+        Out.printString(" ");
+        Out.printInt(Int.Add(-7, 2) * 1000000000000);
+        Out.printString(" ");
+        String s = "456";
+        Out.printInt(Int.parse("-123") + s.length());
+        Out.printString(" ");
+        Bool b = true;
+        Out.printBool(Int.ge(2, 3) || b.not());
+        Out.println("");
+
+        s = "hello, mizar";
+        Out.println(s.substring(7, 100).concat("!"));
+        Out.println(s.substring(-1, 5) + new String());
+        if (s.charAt(0) != 'h' || s.charAt(99) != '\0' || !s.equals("hello, " + "mizar")) {
+            return 1;
+        }
+
+        i = 3;
+        Double d = Double.Mul(2.5, i.toDouble());
+        Out.printInt(d.toInt());
+        d = -2.9;
+        Out.printInt(d.toInt());
+        Out.println("");
+
+        Out.printDouble(d);
+        Out.printString(" ");
+        Out.printDouble(15000000000000.0);
+        Out.printString(" ");
+        Out.printDouble(9999999999999.9999999);
+        Out.printString(" ");
+        Out.printDouble(0.0000004);
+        d = 0.0;
+        Double nan = 0.0 / d;
+        Double inf = -1.0 / d;
+        Out.println(" " + nan.toString() + " " + inf.toString());
+
+        Char c = s.charAt(1);
+        Out.printInt(c.toInt());
+        Out.printChar(c);
+        Out.printChar('é');
+        Out.printChar('中');
+        Out.printChar('😀');
+        String e = "é";
+        c = e.charAt(0);
+        Out.printChar(new Char());
+        Out.printChar(c);
+        Out.println("");
+
+        // 标准输入为空
+        if (In.readLine() != null || In.readInt() != 0) {
+            return 2;
+        }
+        return 0;
+    }
+}
+`)
+	if exitCode != 0 {
+		t.Error(exitCode)
+	}
+	expect := "42 -5000000000000 -120 false\nmizar!\nhello\n7-2\n" +
+		"-2.900000 1.500000e13 1.000000e13 0.000000 NaN -Inf\n101eé中😀\x00\u00c3\n"
+	if out != expect {
+		t.Errorf("%q", out)
+	}
+}
+
+// 反复执行demo中的循环, 总分配量远大于堆的初始大小, 同时保留一个链表检查存活对象没有被回收;
+// 解释器中没有垃圾回收的状态, 因此不与其比较
+func TestGenerateGC(t *testing.T) {
+	exitCode, out := runNative(t, `
+class C1 {
+    public Int a;
+    public String b;
+
+    public void C1(Int a, String b) {
+        this.a = a;
+        this.b = b;
+    }
+
+    public Int getA() {
+        return this.a;
+    }
+
+    public void setA(Int a) {
+        this.a = a;
+    }
+}
+
+class C2 extends C1 {
+    public C2 next;
+
+    public void C2(Int a) {
+        super(a, "C2");
+    }
+}
+
+class Main {
+    public Int main() {
+        C2 list = null;
+        Int n = 0;
+        while (n < 200000) {
+            C2 c2 = new C2(n);
+            if (c2.getA() != n) {
+                return 1;
+            }
+            Int i = 0;
+            while (i < 3) {
+                C1 c1 = new C1(1, "2" + n.toString());
+                c1.setA(i);
+                if (c1.getA() != i) {
+                    return 2;
+                }
+                i = i.Increment();
+            }
+            if (n % 1000 == 0) {
+                c2.next = list;
+                list = c2;
+            }
+            n = n.Increment();
+        }
+
+        Runtime.gc();
+        Int sum = 0;
+        while (list != null) {
+            if (list.getA() % 1000 != 0 || !list.b.equals("C2")) {
+                return 3;
+            }
+            sum = sum + list.getA() / 1000;
+            list = list.next;
+        }
+        if (sum != 199 * 200 / 2) {
+            return 4;
+        }
+
+        if (Runtime.gcCount() == 0) {
+            return 5;
+        }
+        if (Runtime.heapSize() > 4 * 1024 * 1024) {
+            return 6;
+        }
+        Out.printString("ok");
+        return 0;
+    }
+}
+`)
+	if exitCode != 0 || out != "ok" {
+		t.Error(exitCode, out)
+	}
+}
+
+// 实参从右到左求值并且先于接收者求值, 二元运算先左后右
+func TestGenerateEvaluationOrder(t *testing.T) {
+	_, out := run(t, `
+class Main {
+    public Main trace(String s) {
+        Out.printString(s);
+        return this;
+    }
+
+    public Int value(String s, Int v) {
+        Out.printString(s);
+        return v;
+    }
+
+    public Int pair(Int a, Int b) {
+        return a * 10 + b;
+    }
+
+    public void main() {
+        Out.printInt(this.trace("r").pair(this.value("a", 1), this.value("b", 2)));
+        Out.printInt(this.value("x", 3) - this.value("y", 4));
+    }
+}
+`)
+	if out != "bar12xy-1" {
+		t.Errorf("%q", out)
+	}
+}
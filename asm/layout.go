@@ -0,0 +1,168 @@
+package asm
+
+import (
+	"mizar/ast"
+	"mizar/utils"
+	"strings"
+)
+
+const wordSize = 8
+
+// 类描述符: [类名][对象大小][父类描述符][itable][虚方法表...], 虚方法表从vtableOffset开始
+//
+// itable是以0结尾的 [接口描述符][方法表] 对的数组, 方法表中的方法按接口中的顺序排列;
+// 接口描述符只有 [接口名], 只用于比较地址
+const (
+	itableOffset = 3 * wordSize
+	vtableOffset = 4 * wordSize
+)
+
+// 对象布局: 首个8字节是类描述符指针, 之后每个属性占8字节, 父类的属性在前
+//
+// 虚方法表同样是父类的方法在前, 子类中同名同形参的方法占用父类方法的槽位, 其余方法依次追加;
+// private方法不能被覆盖, 直接调用, 不占用槽位
+type classLayout struct {
+	class   *ast.Class
+	parent  *classLayout
+	props   []*ast.PropertyDefinition
+	offsets map[*ast.PropertyDefinition]int
+	size    int
+	vtable  []vmethod
+	slots   map[string]int // 方法签名 -> 虚方法表下标
+}
+
+// 虚方法表的槽位, 抽象方法没有实现, 槽位为0
+type vmethod struct {
+	class *ast.Class
+	md    *ast.MethodDefinition
+}
+
+func (m vmethod) label() string {
+	if m.md.ModifierType == ast.ModifierAbstract {
+		return "0"
+	}
+
+	return methodLabel(m.class, m.md)
+}
+
+func (g *Generator) layout(class *ast.Class) *classLayout {
+	if l, exists := g.layouts[class]; exists {
+		return l
+	}
+
+	l := &classLayout{class: class, offsets: make(map[*ast.PropertyDefinition]int), slots: make(map[string]int)}
+	if len(class.Extends) > 0 {
+		if parent, exists := g.tu.ClassMap[class.Extends[0]]; exists {
+			l.parent = g.layout(parent)
+			l.props = append(l.props, l.parent.props...)
+			for pd, offset := range l.parent.offsets {
+				l.offsets[pd] = offset
+			}
+			l.vtable = append(l.vtable, l.parent.vtable...)
+			for sig, slot := range l.parent.slots {
+				l.slots[sig] = slot
+			}
+		}
+	}
+
+	for _, name := range utils.SortedKeys(class.PropertyDefinitionMap) {
+		pd := class.PropertyDefinitionMap[name]
+		l.offsets[pd] = wordSize * (len(l.props) + 1)
+		l.props = append(l.props, pd)
+	}
+	l.size = wordSize * (len(l.props) + 1)
+
+	for _, methodMap := range []map[string]map[string]*ast.MethodDefinition{class.MethodDefinitionMap, class.AbstractMethodDefinitionMap} {
+		for _, name := range utils.SortedKeys(methodMap) {
+			for _, key := range utils.SortedKeys(methodMap[name]) {
+				md := methodMap[name][key]
+				sig := md.Signature()
+				if slot, exists := l.slots[sig]; exists {
+					l.vtable[slot] = vmethod{class: class, md: md}
+				} else if md.ModifierType != ast.ModifierPrivate {
+					l.slots[sig] = len(l.vtable)
+					l.vtable = append(l.vtable, vmethod{class: class, md: md})
+				}
+			}
+		}
+	}
+
+	g.layouts[class] = l
+
+	return l
+}
+
+// 类实现的所有接口(包括继承自父类的), 按名字排序
+func (g *Generator) interfaces(l *classLayout) []*ast.Interface {
+	names := make(map[string]struct{})
+	for cur := l; cur != nil; cur = cur.parent {
+		for _, name := range cur.class.Implements {
+			names[name] = struct{}{}
+		}
+	}
+
+	var list []*ast.Interface
+	for _, name := range utils.SortedKeys(names) {
+		if inter, exists := g.tu.InterfaceMap[name]; exists {
+			list = append(list, inter)
+		}
+	}
+
+	return list
+}
+
+// 接口中的方法按名字和形参列表排序, 即接口方法表中的顺序
+func interfaceMethods(inter *ast.Interface) []*ast.InterfaceMethod {
+	var list []*ast.InterfaceMethod
+	for _, name := range utils.SortedKeys(inter.MethodMap) {
+		for _, key := range utils.SortedKeys(inter.MethodMap[name]) {
+			list = append(list, inter.MethodMap[name][key])
+		}
+	}
+
+	return list
+}
+
+// 描述符和方法表的符号名以 $ 分隔, 标识符中不能出现 $, 因此不会与 . 分隔的方法名冲突(例如名为itable的方法)
+
+// 类描述符的符号名
+func classLabel(class *ast.Class) string {
+	return class.Name + "$class"
+}
+
+// 接口描述符的符号名
+func interfaceLabel(inter *ast.Interface) string {
+	return inter.Name + "$interface"
+}
+
+// itable的符号名
+func itableLabel(class *ast.Class) string {
+	return class.Name + "$itable"
+}
+
+// 类实现接口inter的方法表的符号名, 例如 C$itable$I
+func interfaceTableLabel(class *ast.Class, inter *ast.Interface) string {
+	return itableLabel(class) + "$" + inter.Name
+}
+
+// 方法的符号名, 例如 C1.setA.Int, 重载的方法通过形参类型区分
+func methodLabel(class *ast.Class, md *ast.MethodDefinition) string {
+	parts := []string{class.Name, md.Name}
+	for _, param := range md.ParameterList {
+		parts = append(parts, param.Type)
+	}
+
+	return strings.Join(parts, ".")
+}
+
+// 构造函数的符号名, 例如 C1.new.Int.String, md为nil时是默认构造函数 C1.new
+func constructorLabel(class *ast.Class, md *ast.MethodDefinition) string {
+	parts := []string{class.Name, "new"}
+	if md != nil {
+		for _, param := range md.ParameterList {
+			parts = append(parts, param.Type)
+		}
+	}
+
+	return strings.Join(parts, ".")
+}
@@ -0,0 +1,170 @@
+package asm
+
+import (
+	"mizar/ast"
+)
+
+// Int比较运算对应的set指令
+var intSetInstructions = map[ast.Operator]string{
+	ast.OperatorEq: "sete",
+	ast.OperatorNe: "setne",
+	ast.OperatorLt: "setl",
+	ast.OperatorLe: "setle",
+	ast.OperatorGt: "setg",
+	ast.OperatorGe: "setge",
+}
+
+var doubleInstructions = map[ast.Operator]string{
+	ast.OperatorAdd: "addsd",
+	ast.OperatorSub: "subsd",
+	ast.OperatorMul: "mulsd",
+	ast.OperatorDiv: "divsd",
+}
+
+// 二元运算: 左值在%rax, 右值在%rcx, 结果放在%rax; 操作数的类型由类型检查记录在Expression.ValueType中
+func (g *Generator) VisitBinaryExpression(expr *ast.BinaryExpression) bool {
+	if expr.Operator == ast.OperatorAnd || expr.Operator == ast.OperatorOr {
+		g.logical(expr)
+		return false
+	}
+
+	if expr.Left.Accept(g); g.err != nil {
+		return false
+	}
+	g.o.Instruction("pushq", "%rax")
+	if expr.Right.Accept(g); g.err != nil {
+		return false
+	}
+	g.o.Instruction("movq", "%rax", "%rcx")
+	g.o.Instruction("popq", "%rax")
+
+	left, right := expr.Left.ValueType, expr.Right.ValueType
+	switch {
+	case left == "String" || right == "String":
+		g.stringOperation(expr.Operator)
+	case left == "Double" || right == "Double":
+		g.doubleOperation(expr.Operator, left, right)
+	default:
+		// Int、Bool以及引用类型的相等比较
+		g.intOperation(expr.Operator)
+	}
+
+	return false
+}
+
+func (g *Generator) VisitUnaryExpression(expr *ast.UnaryExpression) bool {
+	if expr.Operand.Accept(g); g.err != nil {
+		return false
+	}
+
+	switch {
+	case expr.Operator == ast.OperatorNot:
+		g.o.Instruction("xorq", imm(1), "%rax")
+	case expr.Operand.ValueType == "Double":
+		// 翻转符号位
+		g.o.Instruction("btcq", imm(63), "%rax")
+	default:
+		g.o.Instruction("negq", "%rax")
+	}
+
+	return false
+}
+
+// && 和 || 短路求值, Bool只有0和1, 因此跳过右值时%rax已经是结果
+func (g *Generator) logical(expr *ast.BinaryExpression) {
+	endLabel := g.newLabel()
+
+	if expr.Left.Accept(g); g.err != nil {
+		return
+	}
+	g.o.Instruction("testq", "%rax", "%rax")
+	if expr.Operator == ast.OperatorAnd {
+		g.o.Instruction("jz", endLabel)
+	} else {
+		g.o.Instruction("jnz", endLabel)
+	}
+	if expr.Right.Accept(g); g.err != nil {
+		return
+	}
+	g.o.Label(endLabel)
+}
+
+func (g *Generator) intOperation(operator ast.Operator) {
+	switch operator {
+	case ast.OperatorAdd:
+		g.o.Instruction("addq", "%rcx", "%rax")
+	case ast.OperatorSub:
+		g.o.Instruction("subq", "%rcx", "%rax")
+	case ast.OperatorMul:
+		g.o.Instruction("imulq", "%rcx", "%rax")
+	case ast.OperatorDiv, ast.OperatorMod:
+		g.o.Instruction("cqto")
+		g.o.Instruction("idivq", "%rcx")
+		if operator == ast.OperatorMod {
+			g.o.Instruction("movq", "%rdx", "%rax")
+		}
+	default:
+		g.o.Instruction("cmpq", "%rcx", "%rax")
+		g.o.Instruction(intSetInstructions[operator], "%al")
+		g.o.Instruction("movzbq", "%al", "%rax")
+	}
+}
+
+// Int操作数先转换为Double, 比较运算在操作数为NaN时结果为false(!=为true)
+func (g *Generator) doubleOperation(operator ast.Operator, left string, right string) {
+	g.toDouble("%rax", "%xmm0", left)
+	g.toDouble("%rcx", "%xmm1", right)
+
+	switch operator {
+	case ast.OperatorAdd, ast.OperatorSub, ast.OperatorMul, ast.OperatorDiv:
+		g.o.Instruction(doubleInstructions[operator], "%xmm1", "%xmm0")
+		g.o.Instruction("movq", "%xmm0", "%rax")
+		return
+	case ast.OperatorLt:
+		g.o.Instruction("ucomisd", "%xmm0", "%xmm1")
+		g.o.Instruction("seta", "%al")
+	case ast.OperatorLe:
+		g.o.Instruction("ucomisd", "%xmm0", "%xmm1")
+		g.o.Instruction("setae", "%al")
+	case ast.OperatorGt:
+		g.o.Instruction("ucomisd", "%xmm1", "%xmm0")
+		g.o.Instruction("seta", "%al")
+	case ast.OperatorGe:
+		g.o.Instruction("ucomisd", "%xmm1", "%xmm0")
+		g.o.Instruction("setae", "%al")
+	case ast.OperatorEq:
+		g.o.Instruction("ucomisd", "%xmm1", "%xmm0")
+		g.o.Instruction("sete", "%al")
+		g.o.Instruction("setnp", "%cl")
+		g.o.Instruction("andb", "%cl", "%al")
+	case ast.OperatorNe:
+		g.o.Instruction("ucomisd", "%xmm1", "%xmm0")
+		g.o.Instruction("setne", "%al")
+		g.o.Instruction("setp", "%cl")
+		g.o.Instruction("orb", "%cl", "%al")
+	}
+	g.o.Instruction("movzbq", "%al", "%rax")
+}
+
+func (g *Generator) toDouble(src string, dst string, t string) {
+	if t == "Int" {
+		g.o.Instruction("cvtsi2sdq", src, dst)
+	} else {
+		g.o.Instruction("movq", src, dst)
+	}
+}
+
+// 字符串只支持拼接和按内容比较, 由运行时完成
+func (g *Generator) stringOperation(operator ast.Operator) {
+	g.o.Instruction("movq", "%rax", "%rdi")
+	g.o.Instruction("movq", "%rcx", "%rsi")
+	if operator == ast.OperatorAdd {
+		g.o.Instruction("call", "mizar_string_concat")
+		return
+	}
+
+	g.o.Instruction("call", "mizar_string_eq")
+	if operator == ast.OperatorNe {
+		g.o.Instruction("xorq", imm(1), "%rax")
+	}
+}
@@ -1,6 +1,9 @@
 package asm
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 type Output struct {
 	lines []string
@@ -19,3 +22,22 @@ func (o *Output) Directive(name string, content string) {
 func (o *Output) Label(label string) {
 	o.lines = append(o.lines, fmt.Sprintf("%s:", label))
 }
+
+// AT&T语法的指令, 操作数按 源, 目的 的顺序给出
+func (o *Output) Instruction(op string, operands ...string) {
+	if len(operands) == 0 {
+		o.lines = append(o.lines, fmt.Sprintf("    %s", op))
+		return
+	}
+
+	o.lines = append(o.lines, fmt.Sprintf("    %s %s", op, strings.Join(operands, ", ")))
+}
+
+// 直接写入一段汇编代码, 用于运行时等手写的部分
+func (o *Output) Raw(code string) {
+	o.lines = append(o.lines, strings.TrimRight(code, "\n"))
+}
+
+func (o *Output) String() string {
+	return strings.Join(o.lines, "\n") + "\n"
+}
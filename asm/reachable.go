@@ -0,0 +1,69 @@
+package asm
+
+import (
+	"mizar/ast"
+	"sort"
+)
+
+// 从Main出发收集需要生成代码的类: 被实例化、被调用方法、被引用类名的类以及它们的父类
+func (g *Generator) reachableClasses(main *ast.Class) []*ast.Class {
+	r := &reachability{tu: g.tu, visited: make(map[*ast.Class]struct{})}
+	r.mark(main)
+	for len(r.worklist) > 0 {
+		class := r.worklist[len(r.worklist)-1]
+		r.worklist = r.worklist[:len(r.worklist)-1]
+		class.Accept(r)
+	}
+
+	classes := make([]*ast.Class, 0, len(r.visited))
+	for class := range r.visited {
+		classes = append(classes, class)
+	}
+	sort.Slice(classes, func(i, j int) bool {
+		return classes[i].Name < classes[j].Name
+	})
+
+	return classes
+}
+
+// 遍历类的属性初始值和方法体, 标记其中引用到的类
+type reachability struct {
+	ast.BaseVisitor
+	tu       *ast.TranslationUnit
+	visited  map[*ast.Class]struct{}
+	worklist []*ast.Class
+}
+
+func (r *reachability) mark(class *ast.Class) {
+	if class == nil {
+		return
+	}
+	if _, exists := r.visited[class]; exists {
+		return
+	}
+
+	r.visited[class] = struct{}{}
+	r.worklist = append(r.worklist, class)
+	if len(class.Extends) > 0 {
+		r.mark(r.tu.ClassMap[class.Extends[0]])
+	}
+}
+
+func (r *reachability) VisitNewObjectExpression(expr *ast.NewObjectExpression) bool {
+	r.mark(expr.Class)
+	return true
+}
+
+func (r *reachability) VisitVarCallExpression(expr *ast.VarCallExpression) bool {
+	if expr.Binding != nil && expr.Binding.Type == ast.VarBindingTypeClass {
+		r.mark(expr.Binding.Class)
+	}
+	return true
+}
+
+func (r *reachability) VisitMethodCallExpression(expr *ast.MethodCallExpression) bool {
+	if expr.Binding != nil {
+		r.mark(expr.Binding.Class)
+	}
+	return true
+}
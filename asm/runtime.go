@@ -0,0 +1,350 @@
+package asm
+
+// 运行时中实现的native方法
+var natives = map[string]bool{
+	"Int.toDouble":             true,
+	"Int.toString":             true,
+	"Int.parse.String":         true,
+	"Double.toInt":             true,
+	"Char.toInt":               true,
+	"Char.toString":            true,
+	"String.length":            true,
+	"String.charAt.Int":        true,
+	"String.substring.Int.Int": true,
+	"Out.printString.String":   true,
+	"In.readLine":              true,
+	"Runtime.gc":               true,
+	"Runtime.gcCount":          true,
+	"Runtime.heapSize":         true,
+}
+
+// 运行时, 不依赖libc, 直接使用Linux系统调用
+//
+// mizar_alloc、mizar_alloc_bytes等内存管理相关的部分见gcCode
+// mizar_exit:  以%rdi为退出码结束进程
+// mizar_string_concat: 拼接%rdi和%rsi两个字符串, 返回新字符串
+// mizar_string_eq: 按内容比较%rdi和%rsi两个字符串, 相等返回1, 否则返回0, null只和null相等
+// mizar_itable_lookup: 在对象%rdi的类的itable中查找接口描述符%rsi, 返回接口方法表
+//
+// 之后是标准库中native方法的实现, 遵循普通方法的调用约定: 8(%rsp)是this, 16(%rsp)开始依次是各个参数
+const runtimeCode = `
+    .text
+mizar_oom:
+    movq $1, %rax
+    movq $2, %rdi
+    leaq mizar_oom_msg(%rip), %rsi
+    movq $mizar_oom_msg_len, %rdx
+    syscall
+    movq $1, %rdi
+    jmp mizar_exit
+
+mizar_exit:
+    movq $60, %rax
+    syscall
+
+mizar_itable_lookup:
+    movq (%rdi), %rax
+    movq 24(%rax), %rax
+    testq %rax, %rax
+    jz mizar_no_method
+1:
+    movq (%rax), %rcx
+    testq %rcx, %rcx
+    jz mizar_no_method
+    cmpq %rcx, %rsi
+    je 2f
+    addq $16, %rax
+    jmp 1b
+2:
+    movq 8(%rax), %rax
+    ret
+
+mizar_no_method:
+    movq $1, %rax
+    movq $2, %rdi
+    leaq mizar_no_method_msg(%rip), %rsi
+    movq $mizar_no_method_msg_len, %rdx
+    syscall
+    movq $1, %rdi
+    jmp mizar_exit
+
+mizar_string_concat:
+    pushq %rdi
+    pushq %rsi
+    movq (%rdi), %rdi
+    addq (%rsi), %rdi
+    addq $8, %rdi
+    call mizar_alloc_bytes
+    popq %r9
+    popq %r8
+    movq (%r8), %rcx
+    addq (%r9), %rcx
+    movq %rcx, (%rax)
+    leaq 8(%rax), %rdi
+    leaq 8(%r8), %rsi
+    movq (%r8), %rcx
+    rep movsb
+    leaq 8(%r9), %rsi
+    movq (%r9), %rcx
+    rep movsb
+    ret
+
+mizar_string_eq:
+    cmpq %rdi, %rsi
+    je 1f
+    testq %rdi, %rdi
+    jz 2f
+    testq %rsi, %rsi
+    jz 2f
+    movq (%rdi), %rcx
+    cmpq (%rsi), %rcx
+    jne 2f
+    leaq 8(%rdi), %rdi
+    leaq 8(%rsi), %rsi
+    repe cmpsb
+    jne 2f
+1:
+    movq $1, %rax
+    ret
+2:
+    xorq %rax, %rax
+    ret
+
+Int.toDouble:
+    cvtsi2sdq 8(%rsp), %xmm0
+    movq %xmm0, %rax
+    ret
+
+Double.toInt:
+    movq 8(%rsp), %xmm0
+    cvttsd2siq %xmm0, %rax
+    ret
+
+# 从低位开始将数字写入栈上的缓冲区, 余数与被除数同号, 因此对负数逐位取反
+Int.toString:
+    movq 8(%rsp), %rax
+    movq %rax, %r8
+    subq $32, %rsp
+    leaq 32(%rsp), %rsi
+    movq $10, %rcx
+1:
+    cqto
+    idivq %rcx
+    testq %rdx, %rdx
+    jns 2f
+    negq %rdx
+2:
+    addq $48, %rdx
+    decq %rsi
+    movb %dl, (%rsi)
+    testq %rax, %rax
+    jnz 1b
+    testq %r8, %r8
+    jns 3f
+    decq %rsi
+    movb $45, (%rsi)
+3:
+    leaq 32(%rsp), %rcx
+    subq %rsi, %rcx
+    pushq %rsi
+    pushq %rcx
+    leaq 8(%rcx), %rdi
+    call mizar_alloc_bytes
+    popq %rcx
+    popq %rsi
+    movq %rcx, (%rax)
+    leaq 8(%rax), %rdi
+    rep movsb
+    addq $32, %rsp
+    ret
+
+Int.parse.String:
+    xorq %rax, %rax
+    movq 16(%rsp), %rsi
+    testq %rsi, %rsi
+    jz 3f
+    movq (%rsi), %rcx
+    leaq 8(%rsi), %rsi
+    xorq %r8, %r8
+    testq %rcx, %rcx
+    jz 3f
+    cmpb $45, (%rsi)
+    jne 1f
+    movq $1, %r8
+    incq %rsi
+    decq %rcx
+1:
+    testq %rcx, %rcx
+    jz 2f
+    movzbq (%rsi), %rdx
+    subq $48, %rdx
+    cmpq $9, %rdx
+    ja 2f
+    imulq $10, %rax
+    addq %rdx, %rax
+    incq %rsi
+    decq %rcx
+    jmp 1b
+2:
+    testq %r8, %r8
+    jz 3f
+    negq %rax
+3:
+    ret
+
+Char.toInt:
+    movq 8(%rsp), %rax
+    ret
+
+# 按码点的范围编码为1到4字节的UTF-8, 先在%rdx中从低位开始组装各个字节, %rcx为字节数
+Char.toString:
+    movq 8(%rsp), %rax
+    movq %rax, %rdx
+    movq $1, %rcx
+    cmpq $0x80, %rax
+    jb 2f
+    xorq %rdx, %rdx
+    movq $0xc0, %r8
+    movq $2, %rcx
+    cmpq $0x800, %rax
+    jb 1f
+    movq $0xe0, %r8
+    movq $3, %rcx
+    cmpq $0x10000, %rax
+    jb 1f
+    movq $0xf0, %r8
+    movq $4, %rcx
+1:
+    movq %rcx, %r9
+3:
+    decq %r9
+    jz 4f
+    movq %rax, %r10
+    andq $0x3f, %r10
+    orq $0x80, %r10
+    shlq $8, %rdx
+    orq %r10, %rdx
+    shrq $6, %rax
+    jmp 3b
+4:
+    orq %r8, %rax
+    shlq $8, %rdx
+    orq %rax, %rdx
+2:
+    pushq %rdx
+    pushq %rcx
+    leaq 8(%rcx), %rdi
+    call mizar_alloc_bytes
+    popq %rcx
+    popq %rdx
+    movq %rcx, (%rax)
+    movq %rdx, 8(%rax)
+    ret
+
+String.length:
+    movq 8(%rsp), %rax
+    movq (%rax), %rax
+    ret
+
+String.charAt.Int:
+    movq 8(%rsp), %rcx
+    movq 16(%rsp), %rdx
+    xorq %rax, %rax
+    cmpq (%rcx), %rdx
+    jae 1f
+    movzbq 8(%rcx,%rdx), %rax
+1:
+    ret
+
+String.substring.Int.Int:
+    movq 8(%rsp), %r8
+    movq (%r8), %r9
+    movq 16(%rsp), %rsi
+    movq 24(%rsp), %rdx
+    testq %rsi, %rsi
+    jns 1f
+    xorq %rsi, %rsi
+1:
+    cmpq %r9, %rsi
+    jle 2f
+    movq %r9, %rsi
+2:
+    cmpq %r9, %rdx
+    jle 3f
+    movq %r9, %rdx
+3:
+    cmpq %rsi, %rdx
+    jge 4f
+    movq %rsi, %rdx
+4:
+    subq %rsi, %rdx
+    leaq 8(%r8,%rsi), %rsi
+    pushq %rsi
+    pushq %rdx
+    leaq 8(%rdx), %rdi
+    call mizar_alloc_bytes
+    popq %rcx
+    popq %rsi
+    movq %rcx, (%rax)
+    leaq 8(%rax), %rdi
+    rep movsb
+    ret
+
+Out.printString.String:
+    movq 16(%rsp), %rsi
+    testq %rsi, %rsi
+    jz 1f
+    movq (%rsi), %rdx
+    leaq 8(%rsi), %rsi
+    movq $1, %rax
+    movq $1, %rdi
+    syscall
+1:
+    ret
+
+# 逐字节读入预先分配的缓冲区, 一行最多4096字节, 去掉行尾的\r\n
+In.readLine:
+    movq $4104, %rdi
+    call mizar_alloc_bytes
+    movq %rax, %r12
+    xorq %r13, %r13
+    xorq %r14, %r14
+1:
+    cmpq $4096, %r13
+    je 3f
+    xorq %rax, %rax
+    xorq %rdi, %rdi
+    leaq 8(%r12,%r13), %rsi
+    movq $1, %rdx
+    syscall
+    cmpq $1, %rax
+    jne 2f
+    movq $1, %r14
+    cmpb $10, 8(%r12,%r13)
+    je 3f
+    incq %r13
+    jmp 1b
+2:
+    testq %r14, %r14
+    jnz 4f
+    xorq %rax, %rax
+    ret
+3:
+    testq %r13, %r13
+    jz 4f
+    cmpb $13, 7(%r12,%r13)
+    jne 4f
+    decq %r13
+4:
+    movq %r13, (%r12)
+    movq %r12, %rax
+    ret
+
+    .section .rodata
+mizar_oom_msg:
+    .ascii "mizar: out of memory\n"
+    .set mizar_oom_msg_len, . - mizar_oom_msg
+mizar_no_method_msg:
+    .ascii "mizar: interface not implemented\n"
+    .set mizar_no_method_msg_len, . - mizar_no_method_msg
+`
@@ -1,9 +1,6 @@
 package ast
 
+// 语法分析的中间结果, 不出现在最终的语法树中
 type ArgumentList struct {
 	List []*Expression
 }
-
-func (argsList *ArgumentList) Accept(visitor Visitor) {
-
-}
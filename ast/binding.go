@@ -0,0 +1,99 @@
+package ast
+
+type VarBindingType int8
+
+const (
+	VarBindingTypeThis      VarBindingType = iota + 1 // this
+	VarBindingTypeClass                               // 类名, 例如 Out.printString("")
+	VarBindingTypeProperty                            // 类属性
+	VarBindingTypeParameter                           // 方法形参
+	VarBindingTypeLocal                               // 块内局部变量
+)
+
+// 变量引用绑定到的声明, 由check.Resolver填充
+type VarBinding struct {
+	Class                   *Class // this、类名引用所指的类, 或属性所在的类
+	PropertyDefinition      *PropertyDefinition
+	Parameter               *Parameter
+	VarDeclarationStatement *VarDeclarationStatement // Int a;
+	VarAssignStatement      *VarAssignStatement      // Int a = 1;
+	Type                    VarBindingType
+}
+
+// 声明的类型名
+func (b *VarBinding) VarType() string {
+	switch b.Type {
+	case VarBindingTypeThis, VarBindingTypeClass:
+		return b.Class.Name
+	case VarBindingTypeProperty:
+		return b.PropertyDefinition.Type
+	case VarBindingTypeParameter:
+		return b.Parameter.Type
+	case VarBindingTypeLocal:
+		if b.VarDeclarationStatement != nil {
+			return b.VarDeclarationStatement.Type
+		}
+		return b.VarAssignStatement.VarType
+	}
+
+	return ""
+}
+
+// 局部变量的声明节点, *VarDeclarationStatement或*VarAssignStatement
+func (b *VarBinding) Local() interface{} {
+	if b.VarDeclarationStatement != nil {
+		return b.VarDeclarationStatement
+	}
+
+	return b.VarAssignStatement
+}
+
+type MethodBindingType int8
+
+const (
+	MethodBindingTypeClass     MethodBindingType = iota + 1 // 类中定义的方法
+	MethodBindingTypeInterface                              // 接口中声明的方法
+)
+
+// 方法调用绑定到的声明, 由check.Resolver填充
+type MethodBinding struct {
+	Class            *Class
+	MethodDefinition *MethodDefinition
+	Interface        *Interface
+	InterfaceMethod  *InterfaceMethod
+	Type             MethodBindingType
+}
+
+// 返回值类型名
+func (b *MethodBinding) ReturnType() string {
+	if b.Type == MethodBindingTypeInterface {
+		return b.InterfaceMethod.Type
+	}
+
+	return b.MethodDefinition.Type
+}
+
+func (b *MethodBinding) ParameterList() []*Parameter {
+	if b.Type == MethodBindingTypeInterface {
+		return b.InterfaceMethod.ParameterList
+	}
+
+	return b.MethodDefinition.ParameterList
+}
+
+// 方法所在的类或接口的名字
+func (b *MethodBinding) Owner() string {
+	if b.Type == MethodBindingTypeInterface {
+		return b.Interface.Name
+	}
+
+	return b.Class.Name
+}
+
+func (b *MethodBinding) Signature() string {
+	if b.Type == MethodBindingTypeInterface {
+		return b.InterfaceMethod.Signature()
+	}
+
+	return b.MethodDefinition.Signature()
+}
@@ -1,33 +1,97 @@
 package ast
 
+import (
+	"mizar/utils"
+	"sort"
+)
+
 type Class struct {
 	Name                        string
 	IsAbstract                  bool
 	MethodDefinitionMap         map[string]map[string]*MethodDefinition
 	AbstractMethodDefinitionMap map[string]map[string]*MethodDefinition // 抽象方法列表
 	PropertyDefinitionMap       map[string]*PropertyDefinition
+	ConstructorMap              map[string]*MethodDefinition // 构造函数, 以形参列表为key
 	Extends                     []string
 	Implements                  []string
+	Pos                         Pos
+	Doc                         string           // 文档注释
+	Unit                        *TranslationUnit `json:"-"` // 所在的文件
+	Span
 }
 
+// 依次遍历属性、构造函数、方法、抽象方法, 属性和方法按名字排序, 构造函数按形参列表排序
 func (c *Class) Accept(visitor Visitor) {
+	if c == nil || !pre(visitor, c) {
+		return
+	}
+
+	if visitor.VisitClass(c) {
+		for _, name := range utils.SortedKeys(c.PropertyDefinitionMap) {
+			c.PropertyDefinitionMap[name].Accept(visitor)
+		}
+		for _, key := range utils.SortedKeys(c.ConstructorMap) {
+			c.ConstructorMap[key].Accept(visitor)
+		}
+		for _, methodMap := range []map[string]map[string]*MethodDefinition{c.MethodDefinitionMap, c.AbstractMethodDefinitionMap} {
+			for _, name := range utils.SortedKeys(methodMap) {
+				for _, key := range utils.SortedKeys(methodMap[name]) {
+					methodMap[name][key].Accept(visitor)
+				}
+			}
+		}
+	}
+
+	post(visitor, c)
+}
 
+// 与类同名的方法是构造函数, 将其从MethodDefinitionMap移入ConstructorMap; 类名需要是未加包名的简单名
+func (c *Class) ExtractConstructors() {
+	c.ConstructorMap = make(map[string]*MethodDefinition)
+	for key, md := range c.MethodDefinitionMap[c.Name] {
+		c.ConstructorMap[key] = md
+	}
+	delete(c.MethodDefinitionMap, c.Name)
 }
 
-type Extends struct {
-	ClassNameList []string
+func (c *Class) IsConstructor(md *MethodDefinition) bool {
+	return c.ConstructorMap[ParameterListKey(md.ParameterList)] == md
 }
 
-func (ex *Extends) accept(visitor Visitor) {
+// 无参构造函数; 类没有声明构造函数时使用默认构造函数, 此时md为nil, exists为true
+func (c *Class) DefaultConstructor() (md *MethodDefinition, exists bool) {
+	if len(c.ConstructorMap) == 0 {
+		return nil, true
+	}
+
+	md, exists = c.ConstructorMap[""]
 
+	return
 }
 
-type Implements struct {
-	InterfaceNameList []string
+// 按声明顺序排列的属性, 属性初始值按该顺序执行
+func (c *Class) PropertyList() []*PropertyDefinition {
+	list := make([]*PropertyDefinition, 0, len(c.PropertyDefinitionMap))
+	for _, pd := range c.PropertyDefinitionMap {
+		list = append(list, pd)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Pos.Line != list[j].Pos.Line {
+			return list[i].Pos.Line < list[j].Pos.Line
+		}
+		return list[i].Pos.Column < list[j].Pos.Column
+	})
+
+	return list
 }
 
-func (id *Implements) accept(visitor Visitor) {
+// 以下为语法分析的中间结果, 不出现在最终的语法树中
+type Extends struct {
+	ClassNameList []string
+}
 
+type Implements struct {
+	InterfaceNameList []string
 }
 
 type ClassStatementList struct {
@@ -36,8 +100,43 @@ type ClassStatementList struct {
 	PropertyDefinitionMap       map[string]*PropertyDefinition          // 类属性声明
 }
 
-func (csl *ClassStatementList) accept(visitor Visitor) {
+func NewClassStatementList() *ClassStatementList {
+	return &ClassStatementList{
+		MethodDefinitionMap:         make(map[string]map[string]*MethodDefinition),
+		AbstractMethodDefinitionMap: make(map[string]map[string]*MethodDefinition),
+		PropertyDefinitionMap:       make(map[string]*PropertyDefinition),
+	}
+}
+
+// 按类型将类成员加入对应的map; 方法与已有方法(包括抽象方法)签名相同时不加入, 返回已有的方法
+func (csl *ClassStatementList) Add(cs *ClassStatement) (prev *MethodDefinition) {
+	if cs.Type == ClassStatementTypeMethod || cs.Type == ClassStatementTypeAbstractMethod {
+		key := ParameterListKey(cs.MethodDefinition.ParameterList)
+		if prev, exists := csl.MethodDefinitionMap[cs.MethodDefinition.Name][key]; exists {
+			return prev
+		}
+		if prev, exists := csl.AbstractMethodDefinitionMap[cs.MethodDefinition.Name][key]; exists {
+			return prev
+		}
+	}
+
+	switch cs.Type {
+	case ClassStatementTypeMethod:
+		addMethodDefinition(csl.MethodDefinitionMap, cs.MethodDefinition)
+	case ClassStatementTypeAbstractMethod:
+		addMethodDefinition(csl.AbstractMethodDefinitionMap, cs.MethodDefinition)
+	case ClassStatementTypeProperty:
+		csl.PropertyDefinitionMap[cs.PropertyDefinition.Name] = cs.PropertyDefinition
+	}
+
+	return nil
+}
 
+func addMethodDefinition(m map[string]map[string]*MethodDefinition, md *MethodDefinition) {
+	if _, exists := m[md.Name]; !exists {
+		m[md.Name] = make(map[string]*MethodDefinition)
+	}
+	m[md.Name][ParameterListKey(md.ParameterList)] = md
 }
 
 type ClassStatementType int8
@@ -54,20 +153,26 @@ type ClassStatement struct {
 	Type               ClassStatementType
 }
 
-func (cs *ClassStatement) accept(visitor Visitor) {
-
-}
-
 // 类属性
 type PropertyDefinition struct {
 	ModifierType MemberModifierType // 修饰符
 	Type         string
 	Name         string
 	Expr         *Expression
+	Pos          Pos
+	Span
 }
 
-func (pd *PropertyDefinition) accept(visitor Visitor) {
+func (pd *PropertyDefinition) Accept(visitor Visitor) {
+	if pd == nil || !pre(visitor, pd) {
+		return
+	}
+
+	if visitor.VisitPropertyDefinition(pd) {
+		pd.Expr.Accept(visitor)
+	}
 
+	post(visitor, pd)
 }
 
 // 方法定义
@@ -76,9 +181,28 @@ type MethodDefinition struct {
 	Type          string
 	Name          string // 方法名
 	ParameterList []*Parameter
-	Block         *Block
+	Block         *Block // 抽象方法和native方法没有方法体
+	IsNative      bool   // 由运行时实现的方法, 例如 Out.printString
+	Pos           Pos
+	Doc           string // 文档注释
+	Span
 }
 
-func (md *MethodDefinition) Accept(visitor Visitor) {
+func (md *MethodDefinition) Signature() string {
+	return Signature(md.Name, md.ParameterList)
+}
 
+func (md *MethodDefinition) Accept(visitor Visitor) {
+	if md == nil || !pre(visitor, md) {
+		return
+	}
+
+	if visitor.VisitMethodDefinition(md) {
+		for _, param := range md.ParameterList {
+			param.Accept(visitor)
+		}
+		md.Block.Accept(visitor)
+	}
+
+	post(visitor, md)
 }
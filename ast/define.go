@@ -1,12 +1,80 @@
 package ast
 
+import "mizar/utils"
+
+// 编译单元, 对应一个源文件; 由loader合并多个文件得到的整个程序也使用该结构,
+// 此时InterfaceMap、ClassMap以全限定名为键, Units为各个文件
 type TranslationUnit struct {
+	FileName     string
+	Package      string // 包名, 例如 a.b, 为空时表示根包
+	Imports      []*Import
 	InterfaceMap map[string]*Interface
 	ClassMap     map[string]*Class
+	Units        []*TranslationUnit `json:"-"`
+	Names        map[string]string  `json:"-"` // 文件中可见的类型: 简单名 -> 全限定名, 由loader填写
+	Span
+}
+
+func NewTranslationUnit() *TranslationUnit {
+	return &TranslationUnit{
+		InterfaceMap: make(map[string]*Interface),
+		ClassMap:     make(map[string]*Class),
+	}
+}
+
+func (tu *TranslationUnit) Add(ci *ClassInterface) {
+	switch ci.Type {
+	case ClassInterfaceTypeClass:
+		tu.ClassMap[ci.Class.Name] = ci.Class
+	case ClassInterfaceTypeInterface:
+		tu.InterfaceMap[ci.Interface.Name] = ci.Interface
+	}
 }
 
 func (tu *TranslationUnit) Accept(visitor Visitor) {
-	visitor.Visit(tu)
+	if tu == nil || !pre(visitor, tu) {
+		return
+	}
+
+	if visitor.VisitTranslationUnit(tu) {
+		for _, imp := range tu.Imports {
+			imp.Accept(visitor)
+		}
+		for _, name := range utils.SortedKeys(tu.InterfaceMap) {
+			tu.InterfaceMap[name].Accept(visitor)
+		}
+		for _, name := range utils.SortedKeys(tu.ClassMap) {
+			tu.ClassMap[name].Accept(visitor)
+		}
+	}
+
+	post(visitor, tu)
+}
+
+// import a.b; 导入包中的全部类和接口
+type Import struct {
+	Path string
+	Pos  Pos
+	Span
+}
+
+func (imp *Import) Accept(visitor Visitor) {
+	if imp == nil || !pre(visitor, imp) {
+		return
+	}
+
+	visitor.VisitImport(imp)
+
+	post(visitor, imp)
+}
+
+// 包中类型的全限定名
+func QualifiedName(pkg string, name string) string {
+	if pkg == "" {
+		return name
+	}
+
+	return pkg + "." + name
 }
 
 type ClassInterfaceType int8
@@ -16,12 +84,10 @@ const (
 	ClassInterfaceTypeInterface
 )
 
+// 语法分析的中间结果, 不出现在最终的语法树中
 type ClassInterface struct {
 	Class     *Class
 	Interface *Interface
 	Type      ClassInterfaceType
-}
-
-func (ci *ClassInterface) Accept(visitor Visitor) {
-
+	Span
 }
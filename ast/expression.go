@@ -10,21 +10,47 @@ const (
 	ExpressionTypeBool
 	ExpressionTypeNewObject
 	ExpressionTypeCall
+	ExpressionTypeBinary
+	ExpressionTypeUnary
+	ExpressionTypeChar
 )
 
 type Expression struct {
 	StringLiteral       string
+	CharLiteral         rune
 	IntLiteral          int64
 	DoubleLiteral       float64
 	NullLiteral         *struct{}
 	BoolLiteral         bool
 	NewObjectExpression *NewObjectExpression
 	CallExpression      *CallExpression
+	BinaryExpression    *BinaryExpression
+	UnaryExpression     *UnaryExpression
 	Type                ExpressionType
+	Pos                 Pos
+	ValueType           string `json:"-"` // 静态类型, 由类型检查填写
+	Span
 }
 
 func (expr *Expression) Accept(visitor Visitor) {
-
+	if expr == nil || !pre(visitor, expr) {
+		return
+	}
+
+	if visitor.VisitExpression(expr) {
+		switch expr.Type {
+		case ExpressionTypeNewObject:
+			expr.NewObjectExpression.Accept(visitor)
+		case ExpressionTypeCall:
+			expr.CallExpression.Accept(visitor)
+		case ExpressionTypeBinary:
+			expr.BinaryExpression.Accept(visitor)
+		case ExpressionTypeUnary:
+			expr.UnaryExpression.Accept(visitor)
+		}
+	}
+
+	post(visitor, expr)
 }
 
 type StringLiteral struct {
@@ -49,10 +75,24 @@ type BoolLiteral struct {
 type NewObjectExpression struct {
 	Name         string
 	ArgumentList []*Expression
+	Pos          Pos
+	Class        *Class            `json:"-"` // 由check.Resolver填充
+	Constructor  *MethodDefinition `json:"-"` // 由check.TypeChecker按实参类型选出, nil表示默认构造函数
+	Span
 }
 
-func (newObjExpr *NewObjectExpression) accept(visitor Visitor) {
+func (newObjExpr *NewObjectExpression) Accept(visitor Visitor) {
+	if newObjExpr == nil || !pre(visitor, newObjExpr) {
+		return
+	}
+
+	if visitor.VisitNewObjectExpression(newObjExpr) {
+		for _, arg := range newObjExpr.ArgumentList {
+			arg.Accept(visitor)
+		}
+	}
 
+	post(visitor, newObjExpr)
 }
 
 type CallExpressionType int8
@@ -66,10 +106,24 @@ type CallExpression struct {
 	VarCallExpression    *VarCallExpression
 	MethodCallExpression *MethodCallExpression
 	Type                 CallExpressionType
+	Span
 }
 
 func (callExpr *CallExpression) Accept(visitor Visitor) {
+	if callExpr == nil || !pre(visitor, callExpr) {
+		return
+	}
 
+	if visitor.VisitCallExpression(callExpr) {
+		switch callExpr.Type {
+		case CallExpressionTypeValCall:
+			callExpr.VarCallExpression.Accept(visitor)
+		case CallExpressionTypeMethodCall:
+			callExpr.MethodCallExpression.Accept(visitor)
+		}
+	}
+
+	post(visitor, callExpr)
 }
 
 type VarCallExpressionType int8
@@ -85,27 +139,53 @@ type VarCallExpression struct {
 	This           string
 	Var            string
 	Type           VarCallExpressionType
+	Pos            Pos
+	Binding        *VarBinding `json:"-"` // 由check.Resolver填充
+	Span
 }
 
 func (varCallExpr *VarCallExpression) Accept(visitor Visitor) {
+	if varCallExpr == nil || !pre(visitor, varCallExpr) {
+		return
+	}
+
+	if visitor.VisitVarCallExpression(varCallExpr) {
+		varCallExpr.CallExpression.Accept(visitor)
+	}
 
+	post(visitor, varCallExpr)
 }
 
 type MethodCallExpression struct {
 	CallExpression *CallExpression
 	Name           string
 	ArgumentList   []*Expression
+	Pos            Pos
+	Binding        *MethodBinding   `json:"-"` // 由check.Resolver填充, 有多个重载候选时由类型检查按实参类型选择
+	Candidates     []*MethodBinding `json:"-"` // 参数个数匹配的重载候选, 多于一个时才填充
+	Span
 }
 
+// 先遍历接收者, 再依次遍历参数
 func (methodCallExpr *MethodCallExpression) Accept(visitor Visitor) {
+	if methodCallExpr == nil || !pre(visitor, methodCallExpr) {
+		return
+	}
 
+	if visitor.VisitMethodCallExpression(methodCallExpr) {
+		methodCallExpr.CallExpression.Accept(visitor)
+		for _, arg := range methodCallExpr.ArgumentList {
+			arg.Accept(visitor)
+		}
+	}
+
+	post(visitor, methodCallExpr)
 }
 
+// 语法分析的中间结果, 不出现在最终的语法树中
 type MethodCall struct {
 	Name         string
 	ArgumentList []*Expression
-}
-
-func (mc *MethodCall) Accept(visitor Visitor) {
-
+	Pos          Pos
+	Span
 }
@@ -1,29 +1,61 @@
 package ast
 
+import "mizar/utils"
+
 type Interface struct {
 	Name      string
 	MethodMap map[string]map[string]*InterfaceMethod
+	Pos       Pos
+	Doc       string           // 文档注释
+	Unit      *TranslationUnit `json:"-"` // 所在的文件
+	Span
 }
 
 func (i *Interface) Accept(visitor Visitor) {
-
+	if i == nil || !pre(visitor, i) {
+		return
+	}
+
+	if visitor.VisitInterface(i) {
+		for _, name := range utils.SortedKeys(i.MethodMap) {
+			for _, key := range utils.SortedKeys(i.MethodMap[name]) {
+				i.MethodMap[name][key].Accept(visitor)
+			}
+		}
+	}
+
+	post(visitor, i)
 }
 
+// 语法分析的中间结果, 不出现在最终的语法树中
 type InterfaceMethodList struct {
 	List []*InterfaceMethod
 }
 
-func (iml *InterfaceMethodList) Accept(visitor Visitor) {
-
-}
-
 // 接口中的方法
 type InterfaceMethod struct {
 	Type          string
 	Name          string
 	ParameterList []*Parameter
+	Pos           Pos
+	Doc           string // 文档注释
+	Span
+}
+
+func (im *InterfaceMethod) Signature() string {
+	return Signature(im.Name, im.ParameterList)
 }
 
 func (im *InterfaceMethod) Accept(visitor Visitor) {
+	if im == nil || !pre(visitor, im) {
+		return
+	}
+
+	if visitor.VisitInterfaceMethod(im) {
+		for _, param := range im.ParameterList {
+			param.Accept(visitor)
+		}
+	}
 
+	post(visitor, im)
 }
@@ -9,10 +9,23 @@ const (
 	ModifierAbstract
 )
 
+// 语法分析的中间结果, 不出现在最终的语法树中
 type MemberModifier struct {
 	Type MemberModifierType
+	Span
 }
 
-func (memberMod *MemberModifier) Accept(visitor Visitor) {
+func (t MemberModifierType) String() string {
+	switch t {
+	case ModifierPublic:
+		return "public"
+	case ModifierProtected:
+		return "protected"
+	case ModifierPrivate:
+		return "private"
+	case ModifierAbstract:
+		return "abstract"
+	}
 
+	return ""
 }
@@ -1,9 +1,49 @@
 package ast
 
-type Visitor interface {
-	Visit(Node)
-}
+import (
+	"fmt"
+	"unicode/utf8"
+)
 
 type Node interface {
 	Accept(Visitor)
+	SourceSpan() Span
+}
+
+// 源码位置
+type Pos struct {
+	FileName string `json:",omitempty"`
+	Line     int
+	Column   int
+}
+
+// 有文件名时为 file:line:col, 否则为 line:col
+func (pos Pos) String() string {
+	if pos.FileName == "" {
+		return fmt.Sprintf("%d:%d", pos.Line, pos.Column)
+	}
+
+	return fmt.Sprintf("%s:%d:%d", pos.FileName, pos.Line, pos.Column)
+}
+
+// 从pos开始覆盖name的单行范围, 用于只记录了起点的名字
+func (pos Pos) Extend(name string) Span {
+	end := pos
+	end.Column += utf8.RuneCountInString(name)
+	return Span{Start: pos, End: end}
+}
+
+// 节点在源码中的范围, End为最后一个字符之后的位置; 各节点通过嵌入该结构体携带范围
+type Span struct {
+	Start Pos
+	End   Pos
+}
+
+func (span Span) SourceSpan() Span {
+	return span
+}
+
+// 从span的起点到other的终点, other需要位于span之后
+func (span Span) Merge(other Span) Span {
+	return Span{Start: span.Start, End: other.End}
 }
@@ -0,0 +1,68 @@
+package ast_test
+
+import (
+	"mizar/ast"
+	"mizar/lexer"
+	"mizar/log"
+	"mizar/parser"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 检查每个节点都带有源码范围
+type spanChecker struct {
+	ast.BaseVisitor
+	t *testing.T
+}
+
+func (c *spanChecker) Pre(node ast.Node) bool {
+	span := node.SourceSpan()
+	if span.Start.FileName != "a.mi" || span.Start.Line == 0 || span.End.Line < span.Start.Line ||
+		(span.End.Line == span.Start.Line && span.End.Column <= span.Start.Column) {
+		c.t.Errorf("%T: invalid span %v-%v", node, span.Start, span.End)
+	}
+	return true
+}
+
+func (c *spanChecker) Post(node ast.Node) {}
+
+func TestSpan(t *testing.T) {
+	log.Init(logrus.ErrorLevel)
+	tu, err := parser.NewParser().Parse(lexer.NewFileLexer("a.mi", `interface I {
+    void run(Int a);
+}
+class A implements I {
+    public Int a = 1;
+    public void run(Int a) {
+        for (a; this.check(a); a) {
+            if (true) {
+                break;
+            } else {
+                continue;
+            }
+        }
+        while (false) {
+            A b = new A();
+            b.run(a);
+        }
+        this.a = a;
+        return;
+    }
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ast.Walk(&spanChecker{t: t}, tu)
+
+	class := tu.ClassMap["A"]
+	if class.Start.String() != "a.mi:4:1" || class.End.String() != "a.mi:21:2" {
+		t.Errorf("class span %v-%v", class.Start, class.End)
+	}
+	method := class.MethodDefinitionMap["run"]["Int"]
+	stmt := method.Block.StatementList[2].VarAssignStatement
+	if stmt.Start.String() != "a.mi:18:9" || stmt.End.String() != "a.mi:18:20" {
+		t.Errorf("assign span %v-%v", stmt.Start, stmt.End)
+	}
+}
@@ -0,0 +1,61 @@
+package ast
+
+type Operator string
+
+const (
+	OperatorAdd Operator = "+"
+	OperatorSub Operator = "-"
+	OperatorMul Operator = "*"
+	OperatorDiv Operator = "/"
+	OperatorMod Operator = "%"
+	OperatorEq  Operator = "=="
+	OperatorNe  Operator = "!="
+	OperatorLt  Operator = "<"
+	OperatorLe  Operator = "<="
+	OperatorGt  Operator = ">"
+	OperatorGe  Operator = ">="
+	OperatorAnd Operator = "&&"
+	OperatorOr  Operator = "||"
+	OperatorNot Operator = "!"
+	OperatorNeg Operator = "-" // 一元负号
+)
+
+// 二元运算 Left Operator Right
+type BinaryExpression struct {
+	Operator Operator
+	Left     *Expression
+	Right    *Expression
+	Span
+}
+
+func (binaryExpr *BinaryExpression) Accept(visitor Visitor) {
+	if binaryExpr == nil || !pre(visitor, binaryExpr) {
+		return
+	}
+
+	if visitor.VisitBinaryExpression(binaryExpr) {
+		binaryExpr.Left.Accept(visitor)
+		binaryExpr.Right.Accept(visitor)
+	}
+
+	post(visitor, binaryExpr)
+}
+
+// 一元运算 Operator Operand, 只有 - 和 !
+type UnaryExpression struct {
+	Operator Operator
+	Operand  *Expression
+	Span
+}
+
+func (unaryExpr *UnaryExpression) Accept(visitor Visitor) {
+	if unaryExpr == nil || !pre(visitor, unaryExpr) {
+		return
+	}
+
+	if visitor.VisitUnaryExpression(unaryExpr) {
+		unaryExpr.Operand.Accept(visitor)
+	}
+
+	post(visitor, unaryExpr)
+}
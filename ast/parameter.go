@@ -1,15 +1,41 @@
 package ast
 
+import "strings"
+
 // 形参
 type Parameter struct {
 	Type string
 	Name string
+	Pos  Pos
+	Span
 }
 
+func (param *Parameter) Accept(visitor Visitor) {
+	if param == nil || !pre(visitor, param) {
+		return
+	}
+
+	visitor.VisitParameter(param)
+
+	post(visitor, param)
+}
+
+// 语法分析的中间结果, 不出现在最终的语法树中
 type ParameterList struct {
 	List []*Parameter
 }
 
-func (paramList *ParameterList) Accept(visitor Visitor) {
+// 形参列表的key, 用作方法map的第二级key, 例如 "Int,String"
+func ParameterListKey(paramList []*Parameter) string {
+	types := make([]string, 0, len(paramList))
+	for _, param := range paramList {
+		types = append(types, param.Type)
+	}
+
+	return strings.Join(types, ",")
+}
 
+// 方法签名, 由方法名和形参类型组成, 例如 "setA(Int,String)"; 同一个类或接口中签名不能重复, 子类中签名相同的方法覆盖父类的方法
+func Signature(name string, paramList []*Parameter) string {
+	return name + "(" + ParameterListKey(paramList) + ")"
 }
@@ -0,0 +1,15 @@
+package ast
+
+// 基本类型, 值直接保存在变量中; 除Char外在标准库中都有同名的类, 提供这些类型上的方法
+var primitiveTypes = map[string]struct{}{
+	"Bool":   {},
+	"Int":    {},
+	"Double": {},
+	"String": {},
+	"Char":   {},
+}
+
+func IsPrimitiveType(typeName string) bool {
+	_, exists := primitiveTypes[typeName]
+	return exists
+}
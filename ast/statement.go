@@ -2,20 +2,28 @@ package ast
 
 type Block struct {
 	StatementList []*Statement
+	Span
 }
 
 func (block *Block) Accept(visitor Visitor) {
+	if block == nil || !pre(visitor, block) {
+		return
+	}
 
+	if visitor.VisitBlock(block) {
+		for _, stmt := range block.StatementList {
+			stmt.Accept(visitor)
+		}
+	}
+
+	post(visitor, block)
 }
 
+// 语法分析的中间结果, 不出现在最终的语法树中
 type StatementList struct {
 	List []*Statement
 }
 
-func (stmtList *StatementList) Accept(visitor Visitor) {
-
-}
-
 type StatementType int8
 
 const (
@@ -28,6 +36,7 @@ const (
 	StatementTypeBreak
 	StatementTypeContinue
 	StatementTypeReturn
+	StatementTypeSuperCall
 )
 
 type Statement struct {
@@ -40,28 +49,76 @@ type Statement struct {
 	BreakStatement          *BreakStatement
 	ContinueStatement       *ContinueStatement
 	ReturnStatement         *ReturnStatement
+	SuperCallStatement      *SuperCallStatement
 	Type                    StatementType
+	Span
 }
 
 func (stmt *Statement) Accept(visitor Visitor) {
-
+	if stmt == nil || !pre(visitor, stmt) {
+		return
+	}
+
+	if visitor.VisitStatement(stmt) {
+		switch stmt.Type {
+		case StatementTypeExpression:
+			stmt.ExpressionStatement.Accept(visitor)
+		case StatementTypeVarDeclaration:
+			stmt.VarDeclarationStatement.Accept(visitor)
+		case StatementTypeVarAssign:
+			stmt.VarAssignStatement.Accept(visitor)
+		case StatementTypeWhile:
+			stmt.WhileStatement.Accept(visitor)
+		case StatementTypeIf:
+			stmt.IfStatement.Accept(visitor)
+		case StatementTypeFor:
+			stmt.ForStatement.Accept(visitor)
+		case StatementTypeBreak:
+			stmt.BreakStatement.Accept(visitor)
+		case StatementTypeContinue:
+			stmt.ContinueStatement.Accept(visitor)
+		case StatementTypeReturn:
+			stmt.ReturnStatement.Accept(visitor)
+		case StatementTypeSuperCall:
+			stmt.SuperCallStatement.Accept(visitor)
+		}
+	}
+
+	post(visitor, stmt)
 }
 
 type ExpressionStatement struct {
 	Expression *Expression
+	Span
 }
 
 func (exprStmt *ExpressionStatement) Accept(visitor Visitor) {
+	if exprStmt == nil || !pre(visitor, exprStmt) {
+		return
+	}
 
+	if visitor.VisitExpressionStatement(exprStmt) {
+		exprStmt.Expression.Accept(visitor)
+	}
+
+	post(visitor, exprStmt)
 }
 
 type VarDeclarationStatement struct {
 	Type string
 	Name string
+	Pos  Pos
+	Span
 }
 
 func (varDeclStmt *VarDeclarationStatement) Accept(visitor Visitor) {
+	if varDeclStmt == nil || !pre(visitor, varDeclStmt) {
+		return
+	}
+
+	visitor.VisitVarDeclarationStatement(varDeclStmt)
 
+	post(visitor, varDeclStmt)
 }
 
 type VarAssignStatementType int8
@@ -77,29 +134,62 @@ type VarAssignStatement struct {
 	VarCallExpression *VarCallExpression
 	Expression        *Expression
 	Type              VarAssignStatementType
+	Pos               Pos
+	Span
 }
 
+// 先遍历右值, 再遍历被赋值的变量
 func (varAssignStmt *VarAssignStatement) Accept(visitor Visitor) {
+	if varAssignStmt == nil || !pre(visitor, varAssignStmt) {
+		return
+	}
+
+	if visitor.VisitVarAssignStatement(varAssignStmt) {
+		varAssignStmt.Expression.Accept(visitor)
+		varAssignStmt.VarCallExpression.Accept(visitor)
+	}
 
+	post(visitor, varAssignStmt)
 }
 
 type WhileStatement struct {
 	Expression *Expression
 	Block      *Block
+	Span
 }
 
 func (whileStmt *WhileStatement) Accept(visitor Visitor) {
+	if whileStmt == nil || !pre(visitor, whileStmt) {
+		return
+	}
 
+	if visitor.VisitWhileStatement(whileStmt) {
+		whileStmt.Expression.Accept(visitor)
+		whileStmt.Block.Accept(visitor)
+	}
+
+	post(visitor, whileStmt)
 }
 
 type IfStatement struct {
 	CondExpression *Expression
 	IfBlock        *Block
 	ElseBlock      *Block
+	Span
 }
 
 func (ifStmt *IfStatement) Accept(visitor Visitor) {
+	if ifStmt == nil || !pre(visitor, ifStmt) {
+		return
+	}
+
+	if visitor.VisitIfStatement(ifStmt) {
+		ifStmt.CondExpression.Accept(visitor)
+		ifStmt.IfBlock.Accept(visitor)
+		ifStmt.ElseBlock.Accept(visitor)
+	}
 
+	post(visitor, ifStmt)
 }
 
 type ForStatement struct {
@@ -107,32 +197,94 @@ type ForStatement struct {
 	CondExpression *Expression
 	PostExpression *Expression
 	Block          *Block
+	Span
 }
 
 func (forStmt *ForStatement) Accept(visitor Visitor) {
-
+	if forStmt == nil || !pre(visitor, forStmt) {
+		return
+	}
+
+	if visitor.VisitForStatement(forStmt) {
+		forStmt.InitExpression.Accept(visitor)
+		forStmt.CondExpression.Accept(visitor)
+		forStmt.PostExpression.Accept(visitor)
+		forStmt.Block.Accept(visitor)
+	}
+
+	post(visitor, forStmt)
 }
 
 type BreakStatement struct {
 	Expression *Expression
+	Span
 }
 
 func (breakStmt *BreakStatement) Accept(visitor Visitor) {
+	if breakStmt == nil || !pre(visitor, breakStmt) {
+		return
+	}
+
+	if visitor.VisitBreakStatement(breakStmt) {
+		breakStmt.Expression.Accept(visitor)
+	}
 
+	post(visitor, breakStmt)
 }
 
 type ContinueStatement struct {
 	Expression *Expression
+	Span
 }
 
 func (continueStmt *ContinueStatement) Accept(visitor Visitor) {
+	if continueStmt == nil || !pre(visitor, continueStmt) {
+		return
+	}
+
+	if visitor.VisitContinueStatement(continueStmt) {
+		continueStmt.Expression.Accept(visitor)
+	}
 
+	post(visitor, continueStmt)
 }
 
 type ReturnStatement struct {
 	Expression *Expression
+	Pos        Pos
+	Span
 }
 
 func (returnStmt *ReturnStatement) Accept(visitor Visitor) {
+	if returnStmt == nil || !pre(visitor, returnStmt) {
+		return
+	}
+
+	if visitor.VisitReturnStatement(returnStmt) {
+		returnStmt.Expression.Accept(visitor)
+	}
+
+	post(visitor, returnStmt)
+}
+
+// super(...); 调用父类的构造函数, 只能作为构造函数的第一条语句
+type SuperCallStatement struct {
+	ArgumentList []*Expression
+	Pos          Pos
+	Constructor  *MethodDefinition `json:"-"` // 由check.TypeChecker按实参类型选出, nil表示默认构造函数
+	Span
+}
+
+func (superCallStmt *SuperCallStatement) Accept(visitor Visitor) {
+	if superCallStmt == nil || !pre(visitor, superCallStmt) {
+		return
+	}
+
+	if visitor.VisitSuperCallStatement(superCallStmt) {
+		for _, arg := range superCallStmt.ArgumentList {
+			arg.Accept(visitor)
+		}
+	}
 
+	post(visitor, superCallStmt)
 }
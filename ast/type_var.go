@@ -1,10 +1,9 @@
 package ast
 
+// 语法分析的中间结果, 不出现在最终的语法树中
 type TypeVar struct {
 	Type string
 	Name string
-}
-
-func (typeVar *TypeVar) accept(visitor Visitor) {
-
+	Pos  Pos // 变量名的位置
+	Span
 }
@@ -0,0 +1,94 @@
+package ast
+
+// 每种节点对应一个回调, 回调返回true时由节点的Accept继续遍历其子节点,
+// 返回false时跳过子节点, 此时回调可以按自己需要的顺序调用子节点的Accept
+type Visitor interface {
+	VisitTranslationUnit(tu *TranslationUnit) bool
+	VisitImport(imp *Import) bool
+	VisitInterface(inter *Interface) bool
+	VisitInterfaceMethod(im *InterfaceMethod) bool
+	VisitClass(class *Class) bool
+	VisitPropertyDefinition(pd *PropertyDefinition) bool
+	VisitMethodDefinition(md *MethodDefinition) bool
+	VisitParameter(param *Parameter) bool
+	VisitBlock(block *Block) bool
+	VisitStatement(stmt *Statement) bool
+	VisitExpressionStatement(exprStmt *ExpressionStatement) bool
+	VisitVarDeclarationStatement(varDeclStmt *VarDeclarationStatement) bool
+	VisitVarAssignStatement(varAssignStmt *VarAssignStatement) bool
+	VisitWhileStatement(whileStmt *WhileStatement) bool
+	VisitIfStatement(ifStmt *IfStatement) bool
+	VisitForStatement(forStmt *ForStatement) bool
+	VisitBreakStatement(breakStmt *BreakStatement) bool
+	VisitContinueStatement(continueStmt *ContinueStatement) bool
+	VisitReturnStatement(returnStmt *ReturnStatement) bool
+	VisitSuperCallStatement(superCallStmt *SuperCallStatement) bool
+	VisitExpression(expr *Expression) bool
+	VisitNewObjectExpression(newObjExpr *NewObjectExpression) bool
+	VisitCallExpression(callExpr *CallExpression) bool
+	VisitVarCallExpression(varCallExpr *VarCallExpression) bool
+	VisitMethodCallExpression(methodCallExpr *MethodCallExpression) bool
+	VisitBinaryExpression(binaryExpr *BinaryExpression) bool
+	VisitUnaryExpression(unaryExpr *UnaryExpression) bool
+}
+
+// 前置/后置钩子, Visitor同时实现了该接口时生效:
+// 访问每个节点之前调用Pre, 返回false时跳过整个节点; 节点及其子节点访问结束后调用Post
+type HookVisitor interface {
+	Pre(node Node) bool
+	Post(node Node)
+}
+
+// 所有回调都返回true, 即遍历整棵树; 嵌入该结构体后只需实现关心的回调
+type BaseVisitor struct{}
+
+func (BaseVisitor) VisitTranslationUnit(*TranslationUnit) bool                 { return true }
+func (BaseVisitor) VisitImport(*Import) bool                                   { return true }
+func (BaseVisitor) VisitInterface(*Interface) bool                             { return true }
+func (BaseVisitor) VisitInterfaceMethod(*InterfaceMethod) bool                 { return true }
+func (BaseVisitor) VisitClass(*Class) bool                                     { return true }
+func (BaseVisitor) VisitPropertyDefinition(*PropertyDefinition) bool           { return true }
+func (BaseVisitor) VisitMethodDefinition(*MethodDefinition) bool               { return true }
+func (BaseVisitor) VisitParameter(*Parameter) bool                             { return true }
+func (BaseVisitor) VisitBlock(*Block) bool                                     { return true }
+func (BaseVisitor) VisitStatement(*Statement) bool                             { return true }
+func (BaseVisitor) VisitExpressionStatement(*ExpressionStatement) bool         { return true }
+func (BaseVisitor) VisitVarDeclarationStatement(*VarDeclarationStatement) bool { return true }
+func (BaseVisitor) VisitVarAssignStatement(*VarAssignStatement) bool           { return true }
+func (BaseVisitor) VisitWhileStatement(*WhileStatement) bool                   { return true }
+func (BaseVisitor) VisitIfStatement(*IfStatement) bool                         { return true }
+func (BaseVisitor) VisitForStatement(*ForStatement) bool                       { return true }
+func (BaseVisitor) VisitBreakStatement(*BreakStatement) bool                   { return true }
+func (BaseVisitor) VisitContinueStatement(*ContinueStatement) bool             { return true }
+func (BaseVisitor) VisitReturnStatement(*ReturnStatement) bool                 { return true }
+func (BaseVisitor) VisitSuperCallStatement(*SuperCallStatement) bool           { return true }
+func (BaseVisitor) VisitExpression(*Expression) bool                           { return true }
+func (BaseVisitor) VisitNewObjectExpression(*NewObjectExpression) bool         { return true }
+func (BaseVisitor) VisitCallExpression(*CallExpression) bool                   { return true }
+func (BaseVisitor) VisitVarCallExpression(*VarCallExpression) bool             { return true }
+func (BaseVisitor) VisitMethodCallExpression(*MethodCallExpression) bool       { return true }
+func (BaseVisitor) VisitBinaryExpression(*BinaryExpression) bool               { return true }
+func (BaseVisitor) VisitUnaryExpression(*UnaryExpression) bool                 { return true }
+
+// 以node为根遍历语法树, nil节点会被忽略
+func Walk(visitor Visitor, node Node) {
+	if node == nil {
+		return
+	}
+
+	node.Accept(visitor)
+}
+
+func pre(visitor Visitor, node Node) bool {
+	if hook, ok := visitor.(HookVisitor); ok {
+		return hook.Pre(node)
+	}
+
+	return true
+}
+
+func post(visitor Visitor, node Node) {
+	if hook, ok := visitor.(HookVisitor); ok {
+		hook.Post(node)
+	}
+}
@@ -0,0 +1,80 @@
+package ast_test
+
+import (
+	"mizar/ast"
+	"mizar/lexer"
+	"mizar/log"
+	"mizar/parser"
+	"reflect"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 记录访问到的方法名和变量名, 并在Pre中跳过名为skip的方法
+type recorder struct {
+	ast.BaseVisitor
+	names []string
+	pre   int
+	post  int
+}
+
+func (r *recorder) VisitMethodDefinition(md *ast.MethodDefinition) bool {
+	r.names = append(r.names, md.Name)
+	return true
+}
+
+func (r *recorder) VisitVarCallExpression(varCallExpr *ast.VarCallExpression) bool {
+	r.names = append(r.names, varCallExpr.Var)
+	return true
+}
+
+func (r *recorder) Pre(node ast.Node) bool {
+	r.pre++
+	if md, ok := node.(*ast.MethodDefinition); ok && md.Name == "skip" {
+		return false
+	}
+	return true
+}
+
+func (r *recorder) Post(node ast.Node) {
+	r.post++
+}
+
+func TestWalk(t *testing.T) {
+	log.Init(logrus.ErrorLevel)
+	tu, err := parser.NewParser().Parse(lexer.NewLexer(`
+class A {
+    public Int a;
+
+    public void skip() {
+        a = 1;
+    }
+
+    public void run(Int b) {
+        if (true) {
+            a = b;
+        }
+        while (false) {
+            this.a = a;
+        }
+    }
+}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &recorder{}
+	ast.Walk(r, tu)
+
+	// this.a = a 中先访问右值, 再访问左值a及其接收者this(Var为空)
+	expected := []string{"run", "b", "a", "a", "a", ""}
+	if !reflect.DeepEqual(r.names, expected) {
+		t.Errorf("expect %v, got %v", expected, r.names)
+	}
+	// 被跳过的节点只调用Pre, 不调用Post
+	if r.pre != r.post+1 {
+		t.Errorf("pre %d, post %d", r.pre, r.post)
+	}
+}
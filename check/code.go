@@ -0,0 +1,41 @@
+package check
+
+import "mizar/diag"
+
+// 各错误对应的错误码
+var errCodes = map[error]diag.Code{
+	VarNotDefineErr:      "E0201",
+	VarRedefineErr:       "E0202",
+	PropertyNotDefineErr: "E0203",
+	MethodNotDefineErr:   "E0204",
+	ClassNotDefineErr:    "E0205",
+	TypeNotDefineErr:     "E0206",
+	ThisOutsideMethodErr: "E0207",
+
+	InterfaceNotDefineErr:  "E0208",
+	MultipleInheritanceErr: "E0209",
+	InheritanceCycleErr:    "E0210",
+	InheritanceKindErr:     "E0211",
+	MethodNotImplementErr:  "E0212",
+	OverrideErr:            "E0213",
+	OverrideAccessErr:      "E0214",
+
+	TypeMismatchErr:     "E0301",
+	CondNotBoolErr:      "E0302",
+	ReturnValueErr:      "E0303",
+	ArgumentNumErr:      "E0304",
+	MemberNotDefineErr:  "E0305",
+	VoidValueErr:        "E0306",
+	ClassNotValueErr:    "E0307",
+	AbstractInstanceErr: "E0308",
+	OperatorTypeErr:     "E0309",
+	ConstructorErr:      "E0310",
+	AmbiguousCallErr:    "E0311",
+	SuperCallErr:        "E0312",
+	AccessErr:           "E0313",
+	MethodMismatchErr:   "E0314",
+	MissingReturnErr:    "E0315",
+	InstanceMethodErr:   "E0316",
+	OutsideLoopErr:      "E0317",
+	AssignTargetErr:     "E0318",
+}
@@ -0,0 +1,280 @@
+package check
+
+import (
+	"errors"
+	"fmt"
+	"mizar/ast"
+	"mizar/diag"
+	"mizar/utils"
+	"strings"
+)
+
+var (
+	InterfaceNotDefineErr  = errors.New("未定义的接口")
+	MultipleInheritanceErr = errors.New("只能继承一个类")
+	InheritanceCycleErr    = errors.New("循环继承")
+	InheritanceKindErr     = errors.New("继承的类型错误")
+	MethodNotImplementErr  = errors.New("未实现的方法")
+	OverrideErr            = errors.New("覆盖方法的返回值类型不一致")
+	OverrideAccessErr      = errors.New("覆盖方法的访问权限更严格")
+)
+
+// 类层次检查, 需要在Resolve之前进行:
+//
+//   - extends的必须是已定义的类, 且只能有一个; 继承关系不能成环
+//   - implements的必须是已定义的接口
+//   - 非抽象类必须实现其所有祖先(包括自身)所实现接口的方法, 以及祖先中的抽象方法
+//   - 子类中与祖先方法(或接口方法)同名同形参的方法视为覆盖, 返回值类型必须相同, 或者是其子类型;
+//     访问权限不能比被覆盖的方法更严格, 接口方法都是public的
+type hierarchy struct {
+	tu    *ast.TranslationUnit
+	diags diag.List
+}
+
+// 检查整个编译单元的类层次
+func CheckHierarchy(tu *ast.TranslationUnit) diag.List {
+	h := &hierarchy{tu: tu}
+	for _, name := range utils.SortedKeys(tu.ClassMap) {
+		h.checkDeclaration(tu.ClassMap[name])
+	}
+	h.checkCycles()
+	for _, name := range utils.SortedKeys(tu.ClassMap) {
+		class := tu.ClassMap[name]
+		h.checkOverrides(class)
+		if !class.IsAbstract {
+			h.checkImplemented(class)
+		}
+	}
+
+	return h.diags
+}
+
+func (h *hierarchy) checkDeclaration(class *ast.Class) {
+	span := class.Pos.Extend(class.Name)
+
+	if len(class.Extends) > 1 {
+		h.errorf(span, MultipleInheritanceErr, "%s 继承了 %s", class.Name, strings.Join(class.Extends, ", "))
+	}
+	for _, name := range class.Extends {
+		if ast.IsPrimitiveType(name) {
+			h.errorf(span, InheritanceKindErr, "%s 是基本类型, 不能被继承", name)
+			continue
+		}
+		if _, exists := h.tu.ClassMap[name]; exists {
+			continue
+		}
+		if _, exists := h.tu.InterfaceMap[name]; exists {
+			h.errorf(span, InheritanceKindErr, "%s 是接口, 需要使用implements", name)
+			continue
+		}
+		h.diags.Add(diag.Errorf(errCodes[ClassNotDefineErr], span, ClassNotDefineErr, "%s", name))
+	}
+
+	for _, name := range class.Implements {
+		if _, exists := h.tu.InterfaceMap[name]; exists {
+			continue
+		}
+		if _, exists := h.tu.ClassMap[name]; exists {
+			h.errorf(span, InheritanceKindErr, "%s 是类, 需要使用extends", name)
+			continue
+		}
+		h.diags.Add(diag.Errorf(errCodes[InterfaceNotDefineErr], span, InterfaceNotDefineErr, "%s", name))
+	}
+}
+
+// 每个环只报告一次, 报告在环中名字最小的类上
+func (h *hierarchy) checkCycles() {
+	for _, name := range utils.SortedKeys(h.tu.ClassMap) {
+		start := h.tu.ClassMap[name]
+		path := []string{start.Name}
+		inCycle := false
+		for class := h.parent(start); class != nil; class = h.parent(class) {
+			path = append(path, class.Name)
+			if class == start {
+				inCycle = true
+				break
+			}
+			if class.Name < start.Name || len(path) > len(h.tu.ClassMap) {
+				// 环中有名字更小的类, 或者start不在环上
+				break
+			}
+		}
+		if inCycle {
+			h.errorf(start.Pos.Extend(start.Name), InheritanceCycleErr, "%s", strings.Join(path, " -> "))
+		}
+	}
+}
+
+func (h *hierarchy) parent(class *ast.Class) *ast.Class {
+	if len(class.Extends) == 0 {
+		return nil
+	}
+
+	return h.tu.ClassMap[class.Extends[0]]
+}
+
+// 类中的方法与祖先类中的方法、所实现接口中的方法同名同形参时, 返回值类型需要兼容, 访问权限不能更严格;
+// 类所实现接口的方法由继承的方法实现时, 该方法也不能比接口方法(public)更严格
+func (h *hierarchy) checkOverrides(class *ast.Class) {
+	chain := classChain(h.tu, class)
+	for _, methodMap := range []map[string]map[string]*ast.MethodDefinition{class.MethodDefinitionMap, class.AbstractMethodDefinitionMap} {
+		for _, name := range utils.SortedKeys(methodMap) {
+			for _, key := range utils.SortedKeys(methodMap[name]) {
+				md := methodMap[name][key]
+				span := md.Pos.Extend(md.Name)
+				if owner, overridden := h.inheritedMethod(chain[1:], name, key); overridden != nil {
+					if !h.compatible(overridden.Type, md.Type) {
+						h.errorf(span, OverrideErr, "%s.%s 返回 %s, 而 %s.%s 返回 %s", class.Name, md.Name, md.Type, owner.Name, md.Name, overridden.Type)
+					}
+					if narrower(md.ModifierType, overridden.ModifierType) {
+						h.errorf(span, OverrideAccessErr, "%s.%s 是 %s 的, 而 %s.%s 是 %s 的", class.Name, md.Name, md.ModifierType, owner.Name, md.Name, overridden.ModifierType)
+					}
+				}
+				if inter, im := h.interfaceMethod(chain, name, key); im != nil {
+					if !h.compatible(im.Type, md.Type) {
+						h.errorf(span, OverrideErr, "%s.%s 返回 %s, 而 %s.%s 返回 %s", class.Name, md.Name, md.Type, inter.Name, md.Name, im.Type)
+					}
+					if narrower(md.ModifierType, ast.ModifierPublic) {
+						h.errorf(span, OverrideAccessErr, "%s.%s 是 %s 的, 而接口方法 %s.%s 是 %s 的", class.Name, md.Name, md.ModifierType, inter.Name, md.Name, ast.ModifierPublic)
+					}
+				}
+			}
+		}
+	}
+
+	// 本类中的方法已经在上面检查过, 这里只检查由祖先类中的方法实现的接口方法
+	for _, interName := range class.Implements {
+		inter, exists := h.tu.InterfaceMap[interName]
+		if !exists {
+			continue
+		}
+		for _, name := range utils.SortedKeys(inter.MethodMap) {
+			for _, key := range utils.SortedKeys(inter.MethodMap[name]) {
+				if _, exists := class.MethodDefinitionMap[name][key]; exists {
+					continue
+				}
+				if _, exists := class.AbstractMethodDefinitionMap[name][key]; exists {
+					continue
+				}
+				if owner, md := h.inheritedMethod(chain[1:], name, key); md != nil && narrower(md.ModifierType, ast.ModifierPublic) {
+					h.errorf(class.Pos.Extend(class.Name), OverrideAccessErr, "%s 实现 %s.%s 的方法 %s.%s 是 %s 的", class.Name, inter.Name, name, owner.Name, name, md.ModifierType)
+				}
+			}
+		}
+	}
+}
+
+// 在祖先类中查找同名同形参的方法, 返回所在的类和方法; private方法不会被继承, 子类中的同名方法与其无关
+func (h *hierarchy) inheritedMethod(ancestors []*ast.Class, name string, key string) (*ast.Class, *ast.MethodDefinition) {
+	for _, c := range ancestors {
+		for _, methodMap := range []map[string]map[string]*ast.MethodDefinition{c.MethodDefinitionMap, c.AbstractMethodDefinitionMap} {
+			if md, ok := methodMap[name][key]; ok && md.ModifierType != ast.ModifierPrivate {
+				return c, md
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+// 在类及其祖先所实现的接口中查找同名同形参的方法
+func (h *hierarchy) interfaceMethod(chain []*ast.Class, name string, key string) (*ast.Interface, *ast.InterfaceMethod) {
+	for _, c := range chain {
+		for _, interName := range c.Implements {
+			if inter, ok := h.tu.InterfaceMap[interName]; ok {
+				if im, ok := inter.MethodMap[name][key]; ok {
+					return inter, im
+				}
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+// a的访问权限是否比b更严格: public和abstract < protected < private
+func narrower(a ast.MemberModifierType, b ast.MemberModifierType) bool {
+	return accessLevel(a) > accessLevel(b)
+}
+
+func accessLevel(modifier ast.MemberModifierType) int {
+	switch modifier {
+	case ast.ModifierProtected:
+		return 1
+	case ast.ModifierPrivate:
+		return 2
+	}
+
+	return 0
+}
+
+// 覆盖方法的返回值类型可以是被覆盖方法返回值类型的子类型
+func (h *hierarchy) compatible(overridden string, override string) bool {
+	if overridden == override {
+		return true
+	}
+	if _, exists := h.tu.ClassMap[override]; !exists {
+		return false
+	}
+
+	return assignable(h.tu, overridden, override)
+}
+
+// 非抽象类需要有 接口方法 和 祖先中抽象方法 的实现, 实现可以继承自祖先类
+func (h *hierarchy) checkImplemented(class *ast.Class) {
+	span := class.Pos.Extend(class.Name)
+	for _, name := range utils.SortedKeys(class.AbstractMethodDefinitionMap) {
+		h.errorf(span, MethodNotImplementErr, "%s 不是抽象类, 不能有抽象方法 %s", class.Name, name)
+	}
+
+	chain := classChain(h.tu, class)
+	// 本类中的private方法由checkOverrides报告, 祖先类中的private方法不会被继承
+	implemented := func(name string, key string) bool {
+		for i, c := range chain {
+			if md, exists := c.MethodDefinitionMap[name][key]; exists && (i == 0 || md.ModifierType != ast.ModifierPrivate) {
+				return true
+			}
+		}
+		return false
+	}
+
+	reported := make(map[string]struct{})
+	report := func(owner string, name string, key string) {
+		signature := fmt.Sprintf("%s.%s(%s)", owner, name, key)
+		if _, exists := reported[signature]; exists {
+			return
+		}
+		reported[signature] = struct{}{}
+		h.errorf(span, MethodNotImplementErr, "%s 没有实现 %s", class.Name, signature)
+	}
+
+	for _, c := range chain {
+		for _, interName := range c.Implements {
+			inter, exists := h.tu.InterfaceMap[interName]
+			if !exists {
+				continue
+			}
+			for _, name := range utils.SortedKeys(inter.MethodMap) {
+				for _, key := range utils.SortedKeys(inter.MethodMap[name]) {
+					if !implemented(name, key) {
+						report(inter.Name, name, key)
+					}
+				}
+			}
+		}
+		if c == class {
+			continue
+		}
+		for _, name := range utils.SortedKeys(c.AbstractMethodDefinitionMap) {
+			for _, key := range utils.SortedKeys(c.AbstractMethodDefinitionMap[name]) {
+				if !implemented(name, key) {
+					report(c.Name, name, key)
+				}
+			}
+		}
+	}
+}
+
+func (h *hierarchy) errorf(span ast.Span, err error, format string, args ...interface{}) {
+	h.diags.Add(diag.New(diag.SeverityError, errCodes[err], span, err, fmt.Sprintf("%s, %s", err, fmt.Sprintf(format, args...))))
+}
@@ -0,0 +1,139 @@
+package check
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckHierarchy(t *testing.T) {
+	tu := parse(t, `
+interface I {
+    void f(Int a);
+    A g();
+}
+interface J {
+    void h();
+}
+abstract class A implements I {
+    public void f(Int a) {
+    }
+    abstract void k();
+}
+class B extends A implements J {
+    public B g() {
+        return null;
+    }
+    public void k() {
+    }
+}
+class C extends A {
+    public Int k() {
+        return 1;
+    }
+}
+class D extends B, A implements A {
+}
+class E extends F {
+}
+class F extends E implements K {
+}
+class G extends I {
+    abstract void m();
+}
+`)
+
+	expects := []struct {
+		err error
+		msg string
+	}{
+		{MultipleInheritanceErr, `26:7: 只能继承一个类, D 继承了 B, A`},
+		{InheritanceKindErr, `26:7: 继承的类型错误, A 是类, 需要使用extends`},
+		{InterfaceNotDefineErr, `30:7: 未定义的接口 K`},
+		{InheritanceKindErr, `32:7: 继承的类型错误, I 是接口, 需要使用implements`},
+		{InheritanceCycleErr, `28:7: 循环继承, E -> F -> E`},
+		{MethodNotImplementErr, `14:7: 未实现的方法, B 没有实现 J.h()`},
+		{OverrideErr, `22:16: 覆盖方法的返回值类型不一致, C.k 返回 Int, 而 A.k 返回 void`},
+		{MethodNotImplementErr, `21:7: 未实现的方法, C 没有实现 I.g()`},
+		{MethodNotImplementErr, `26:7: 未实现的方法, D 没有实现 J.h()`},
+		{MethodNotImplementErr, `32:7: 未实现的方法, G 不是抽象类, 不能有抽象方法 m`},
+	}
+	errs := CheckHierarchy(tu)
+	if len(errs) != len(expects) {
+		t.Fatal(errs)
+	}
+	for i, expect := range expects {
+		if !errors.Is(errs[i], expect.err) || errs[i].Error() != expect.msg {
+			t.Error(errs[i])
+		}
+	}
+}
+
+func TestCheckHierarchyAccess(t *testing.T) {
+	tu := parse(t, `
+interface I {
+    void f();
+    void g();
+}
+class A {
+    public void f() {
+    }
+    protected void h() {
+    }
+    private void k() {
+    }
+}
+class B extends A implements I {
+    private void f() {
+    }
+    protected void g() {
+    }
+    public void h() {
+    }
+    public void k() {
+    }
+}
+class C {
+    protected void g() {
+    }
+}
+class D extends C implements I {
+    public void f() {
+    }
+}
+class E extends A {
+    private void h() {
+    }
+}
+interface J {
+    void k();
+}
+class F extends A {
+    public Int k() {
+        return 0;
+    }
+}
+class G extends A implements J {
+}
+`)
+
+	expects := []struct {
+		err error
+		msg string
+	}{
+		{OverrideAccessErr, `15:18: 覆盖方法的访问权限更严格, B.f 是 private 的, 而 A.f 是 public 的`},
+		{OverrideAccessErr, `15:18: 覆盖方法的访问权限更严格, B.f 是 private 的, 而接口方法 I.f 是 public 的`},
+		{OverrideAccessErr, `17:20: 覆盖方法的访问权限更严格, B.g 是 protected 的, 而接口方法 I.g 是 public 的`},
+		{OverrideAccessErr, `28:7: 覆盖方法的访问权限更严格, D 实现 I.g 的方法 C.g 是 protected 的`},
+		{OverrideAccessErr, `33:18: 覆盖方法的访问权限更严格, E.h 是 private 的, 而 A.h 是 protected 的`},
+		{MethodNotImplementErr, `44:7: 未实现的方法, G 没有实现 J.k()`},
+	}
+	errs := CheckHierarchy(tu)
+	if len(errs) != len(expects) {
+		t.Fatal(errs)
+	}
+	for i, expect := range expects {
+		if !errors.Is(errs[i], expect.err) || errs[i].Error() != expect.msg {
+			t.Error(errs[i])
+		}
+	}
+}
@@ -1,9 +1,391 @@
 package check
 
+import (
+	"errors"
+	"fmt"
+	"mizar/ast"
+	"mizar/diag"
+	"mizar/utils"
+)
+
+var (
+	VarNotDefineErr      = errors.New("未定义的变量")
+	VarRedefineErr       = errors.New("重复定义的变量")
+	PropertyNotDefineErr = errors.New("未定义的属性")
+	MethodNotDefineErr   = errors.New("未定义的方法")
+	ClassNotDefineErr    = errors.New("未定义的类")
+	TypeNotDefineErr     = errors.New("未定义的类型")
+	ThisOutsideMethodErr = errors.New("this只能在方法中使用")
+)
+
+// 作用域, 由内向外依次为: 块 -> 方法形参 -> 类成员 -> 文件中可见的类名 -> 全局(类名)
+type scope struct {
+	parent *scope
+	vars   map[string]*ast.VarBinding
+}
+
+func newScope(parent *scope) *scope {
+	return &scope{parent: parent, vars: make(map[string]*ast.VarBinding)}
+}
+
+func (s *scope) lookup(name string) (binding *ast.VarBinding, exists bool) {
+	for cur := s; cur != nil; cur = cur.parent {
+		if binding, exists = cur.vars[name]; exists {
+			return
+		}
+	}
+
+	return
+}
+
 // 引用消解
 type Resolver struct {
+	ast.BaseVisitor
+	tu     *ast.TranslationUnit
+	class  *ast.Class              // 当前所在的类
+	method *ast.MethodDefinition   // 当前所在的方法
+	prop   *ast.PropertyDefinition // 当前所在的属性初始值, 初始值在构造函数中执行, 可以使用this
+	scope  *scope
+	params *scope // 当前方法的形参作用域, 方法中的声明不能与其内的任何变量同名
+	diags  diag.List
 }
 
-func (r *Resolver) resolve() {
+// 对整个编译单元做引用消解, 将每个VarCallExpression、MethodCallExpression绑定到其声明
+func Resolve(tu *ast.TranslationUnit) diag.List {
+	r := &Resolver{tu: tu}
+	tu.Accept(r)
+	return r.diags
+}
+
+func (r *Resolver) VisitTranslationUnit(tu *ast.TranslationUnit) bool {
+	// 由loader加载的程序中每个文件可见的类不同, 在VisitClass中按文件加入; 单个文件编译时全部类都可见
+	r.scope = newScope(nil)
+	if len(tu.Units) > 0 {
+		return true
+	}
+	for _, name := range utils.SortedKeys(tu.ClassMap) {
+		class := tu.ClassMap[name]
+		r.scope.vars[name] = &ast.VarBinding{Class: class, Type: ast.VarBindingTypeClass}
+	}
+
+	return true
+}
+
+func (r *Resolver) VisitInterfaceMethod(im *ast.InterfaceMethod) bool {
+	r.checkType(im.Type, im.Span, true)
+	return true
+}
+
+func (r *Resolver) VisitClass(class *ast.Class) bool {
+	r.class = class
+
+	// 类所在文件中以简单名可见的类, 由loader计算; 单个文件编译时Names为空, 全局作用域中已经是简单名
+	r.pushScope()
+	if class.Unit != nil {
+		for name, qualified := range class.Unit.Names {
+			if c, exists := r.tu.ClassMap[qualified]; exists {
+				r.scope.vars[name] = &ast.VarBinding{Class: c, Type: ast.VarBindingTypeClass}
+			}
+		}
+	}
+
+	r.pushScope()
+
+	// 父类的属性先入作用域, 子类同名属性覆盖之
+	chain := classChain(r.tu, class)
+	for i := len(chain) - 1; i >= 0; i-- {
+		for name, pd := range chain[i].PropertyDefinitionMap {
+			r.scope.vars[name] = &ast.VarBinding{Class: chain[i], PropertyDefinition: pd, Type: ast.VarBindingTypeProperty}
+		}
+	}
+
+	return true
+}
+
+func (r *Resolver) VisitPropertyDefinition(pd *ast.PropertyDefinition) bool {
+	r.prop = pd
+	r.checkType(pd.Type, pd.Span, false)
+	return true
+}
+
+func (r *Resolver) VisitMethodDefinition(method *ast.MethodDefinition) bool {
+	r.method = method
+	r.checkType(method.Type, method.Span, true)
+	r.pushScope()
+	r.params = r.scope
+	return true
+}
+
+// 方法的形参在方法作用域中声明, 接口方法的形参只检查类型
+func (r *Resolver) VisitParameter(param *ast.Parameter) bool {
+	r.checkType(param.Type, param.Span, false)
+	if r.method != nil {
+		r.declare(param.Name, param.Pos, &ast.VarBinding{Parameter: param, Type: ast.VarBindingTypeParameter})
+	}
+	return true
+}
+
+func (r *Resolver) VisitBlock(block *ast.Block) bool {
+	r.pushScope()
+	return true
+}
+
+func (r *Resolver) VisitVarDeclarationStatement(varDeclStmt *ast.VarDeclarationStatement) bool {
+	r.checkType(varDeclStmt.Type, varDeclStmt.Span, false)
+	r.declare(varDeclStmt.Name, varDeclStmt.Pos, &ast.VarBinding{VarDeclarationStatement: varDeclStmt, Type: ast.VarBindingTypeLocal})
+	return true
+}
+
+func (r *Resolver) Pre(node ast.Node) bool {
+	return true
+}
+
+// 作用域的退出以及依赖子节点的消解都在后置钩子中完成
+func (r *Resolver) Post(node ast.Node) {
+	switch n := node.(type) {
+	case *ast.Class:
+		r.popScope()
+		r.popScope()
+		r.class = nil
+	case *ast.PropertyDefinition:
+		r.prop = nil
+	case *ast.MethodDefinition:
+		r.popScope()
+		r.method, r.params = nil, nil
+	case *ast.Block:
+		r.popScope()
+	case *ast.VarAssignStatement:
+		// 右值已经消解, 使 Int a = a; 中的右值a指向外层的声明
+		if n.Type == ast.VarAssignStatementTypeVar {
+			r.checkType(n.VarType, n.Span, false)
+			r.declare(n.VarName, n.Pos, &ast.VarBinding{VarAssignStatement: n, Type: ast.VarBindingTypeLocal})
+		}
+	case *ast.NewObjectExpression:
+		if class, exists := r.tu.ClassMap[n.Name]; exists {
+			n.Class = class
+		} else {
+			r.errorf(n.Pos.Extend(n.Name), ClassNotDefineErr, n.Name)
+		}
+	case *ast.VarCallExpression:
+		r.resolveVarCallExpression(n)
+	case *ast.MethodCallExpression:
+		r.resolveMethodCallExpression(n)
+	}
+}
+
+func (r *Resolver) resolveVarCallExpression(varCallExpr *ast.VarCallExpression) {
+	switch varCallExpr.Type {
+	case ast.VarCallExpressionTypeThis:
+		if r.method == nil && r.prop == nil {
+			r.errorf(varCallExpr.Span, ThisOutsideMethodErr, "")
+			return
+		}
+		varCallExpr.Binding = &ast.VarBinding{Class: r.class, Type: ast.VarBindingTypeThis}
+	case ast.VarCallExpressionTypeVar:
+		binding, exists := r.scope.lookup(varCallExpr.Var)
+		if !exists {
+			r.errorf(varCallExpr.Span, VarNotDefineErr, varCallExpr.Var)
+			return
+		}
+		varCallExpr.Binding = binding
+	case ast.VarCallExpressionTypeCall:
+		class, _, known := r.receiver(varCallExpr.CallExpression)
+		if !known || class == nil {
+			// 接收者不是类(基本类型或未知类型), 交给类型检查处理
+			return
+		}
+		owner, pd := r.lookupProperty(class, varCallExpr.Var)
+		if pd == nil {
+			r.errorf(varCallExpr.Pos.Extend(varCallExpr.Var), PropertyNotDefineErr, fmt.Sprintf("%s.%s", class.Name, varCallExpr.Var))
+			return
+		}
+		varCallExpr.Binding = &ast.VarBinding{Class: owner, PropertyDefinition: pd, Type: ast.VarBindingTypeProperty}
+	}
+}
+
+// 接收者和参数已经在子节点遍历中消解; 参数个数匹配的候选多于一个时需要实参类型, 留给类型检查选择
+func (r *Resolver) resolveMethodCallExpression(methodCallExpr *ast.MethodCallExpression) {
+	class, inter, known := r.receiver(methodCallExpr.CallExpression)
+	if !known {
+		return
+	}
+
+	candidates := methodCandidates(r.tu, class, inter, methodCallExpr.Name, len(methodCallExpr.ArgumentList))
+	switch len(candidates) {
+	case 0:
+		var owner string
+		if class != nil {
+			owner = class.Name
+		} else {
+			owner = inter.Name
+		}
+		r.errorf(methodCallExpr.Pos.Extend(methodCallExpr.Name), MethodNotDefineErr, fmt.Sprintf("%s.%s", owner, methodCallExpr.Name))
+	case 1:
+		methodCallExpr.Binding = candidates[0]
+	default:
+		methodCallExpr.Candidates = candidates
+	}
+}
+
+// 计算调用表达式的静态类型所对应的类或接口, known为false表示无法确定(未消解或非类类型)
+func (r *Resolver) receiver(callExpr *ast.CallExpression) (class *ast.Class, inter *ast.Interface, known bool) {
+	var typeName string
+	switch callExpr.Type {
+	case ast.CallExpressionTypeValCall:
+		if callExpr.VarCallExpression.Binding == nil {
+			return
+		}
+		typeName = callExpr.VarCallExpression.Binding.VarType()
+	case ast.CallExpressionTypeMethodCall:
+		if callExpr.MethodCallExpression.Binding == nil {
+			return
+		}
+		typeName = callExpr.MethodCallExpression.Binding.ReturnType()
+	}
+
+	if class, known = r.tu.ClassMap[typeName]; known {
+		return
+	}
+	inter, known = r.tu.InterfaceMap[typeName]
+
+	return
+}
+
+// 类及其祖先类, 由近及远
+func classChain(tu *ast.TranslationUnit, class *ast.Class) (chain []*ast.Class) {
+	visited := make(map[string]struct{})
+	for class != nil {
+		if _, exists := visited[class.Name]; exists {
+			break
+		}
+		visited[class.Name] = struct{}{}
+		chain = append(chain, class)
+
+		var parent *ast.Class
+		if len(class.Extends) > 0 {
+			parent = tu.ClassMap[class.Extends[0]]
+		}
+		class = parent
+	}
+
+	return
+}
+
+func (r *Resolver) lookupProperty(class *ast.Class, name string) (*ast.Class, *ast.PropertyDefinition) {
+	for _, c := range classChain(r.tu, class) {
+		if pd, exists := c.PropertyDefinitionMap[name]; exists {
+			return c, pd
+		}
+	}
+
+	return nil, nil
+}
+
+// 查找参数个数为argNum的同名方法: 类沿继承链查找, 再查找类所实现的接口(抽象类可以不实现接口方法),
+// 与已找到的方法签名相同的方法已被覆盖, 不作为候选; class为nil时在接口inter中查找
+func methodCandidates(tu *ast.TranslationUnit, class *ast.Class, inter *ast.Interface, name string, argNum int) []*ast.MethodBinding {
+	var (
+		candidates []*ast.MethodBinding
+		seen       = make(map[string]struct{})
+	)
+	addInterface := func(inter *ast.Interface) {
+		for _, key := range utils.SortedKeys(inter.MethodMap[name]) {
+			im := inter.MethodMap[name][key]
+			if _, exists := seen[key]; exists || len(im.ParameterList) != argNum {
+				continue
+			}
+			seen[key] = struct{}{}
+			candidates = append(candidates, &ast.MethodBinding{Interface: inter, InterfaceMethod: im, Type: ast.MethodBindingTypeInterface})
+		}
+	}
+
+	if class == nil {
+		addInterface(inter)
+		return candidates
+	}
+
+	chain := classChain(tu, class)
+	for _, c := range chain {
+		for _, methodMap := range []map[string]map[string]*ast.MethodDefinition{c.MethodDefinitionMap, c.AbstractMethodDefinitionMap} {
+			for _, key := range utils.SortedKeys(methodMap[name]) {
+				md := methodMap[name][key]
+				if _, exists := seen[key]; exists || len(md.ParameterList) != argNum {
+					continue
+				}
+				seen[key] = struct{}{}
+				candidates = append(candidates, &ast.MethodBinding{Class: c, MethodDefinition: md, Type: ast.MethodBindingTypeClass})
+			}
+		}
+	}
+	for _, c := range chain {
+		for _, interName := range c.Implements {
+			if inter, exists := tu.InterfaceMap[interName]; exists {
+				addInterface(inter)
+			}
+		}
+	}
+
+	return candidates
+}
+
+// 检查类型名是否已定义, span为声明所在的范围
+func (r *Resolver) checkType(typeName string, span ast.Span, allowVoid bool) {
+	if allowVoid && typeName == "void" {
+		return
+	}
+	if ast.IsPrimitiveType(typeName) {
+		return
+	}
+	if _, exists := r.tu.ClassMap[typeName]; exists {
+		return
+	}
+	if _, exists := r.tu.InterfaceMap[typeName]; exists {
+		return
+	}
+
+	r.errorf(span, TypeNotDefineErr, typeName)
+}
+
+// 在当前作用域中声明变量; 与同一方法中外层块的局部变量或形参同名也是重复定义, 可以与属性同名
+func (r *Resolver) declare(name string, pos ast.Pos, binding *ast.VarBinding) {
+	for cur := r.scope; cur != nil; cur = cur.parent {
+		if prev, exists := cur.vars[name]; exists {
+			r.errorf(pos.Extend(name), VarRedefineErr, name)
+			if span, ok := declSpan(prev); ok {
+				r.diags[len(r.diags)-1].WithNote(span, "%s 已在此处定义", name)
+			}
+			return
+		}
+		if cur == r.params || r.params == nil {
+			break
+		}
+	}
+
+	r.scope.vars[name] = binding
+}
+
+func (r *Resolver) pushScope() {
+	r.scope = newScope(r.scope)
+}
+
+func (r *Resolver) popScope() {
+	r.scope = r.scope.parent
+}
+
+// 变量声明所在的范围
+func declSpan(binding *ast.VarBinding) (span ast.Span, ok bool) {
+	switch binding.Type {
+	case ast.VarBindingTypeProperty:
+		return binding.PropertyDefinition.Span, true
+	case ast.VarBindingTypeParameter:
+		return binding.Parameter.Span, true
+	case ast.VarBindingTypeLocal:
+		return binding.Local().(ast.Node).SourceSpan(), true
+	}
+
+	return
+}
 
+func (r *Resolver) errorf(span ast.Span, err error, name string) {
+	r.diags.Add(diag.Errorf(errCodes[err], span, err, "%s", name))
 }
@@ -0,0 +1,130 @@
+package check
+
+import (
+	"errors"
+	"mizar/ast"
+	"mizar/lexer"
+	"mizar/log"
+	"mizar/parser"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func parse(t *testing.T, source string) *ast.TranslationUnit {
+	log.Init(logrus.ErrorLevel)
+	tu, err := parser.NewParser().Parse(lexer.NewLexer(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tu
+}
+
+func TestResolveUndefinedVar(t *testing.T) {
+	tu := parse(t, `
+class Main {
+    public void main() {
+        for (;;) {
+            C1 c1 = new C1();
+        }
+        c1.f();
+    }
+}
+class C1 {
+    public void f() {
+    }
+}`)
+	errs := Resolve(tu)
+	if len(errs) != 1 {
+		t.Fatal(errs)
+	}
+	if !errors.Is(errs[0], VarNotDefineErr) || errs[0].Error() != "7:9: 未定义的变量 c1" {
+		t.Error(errs[0])
+	}
+}
+
+func TestResolveVarRedefine(t *testing.T) {
+	tu := parse(t, `
+class Main {
+    public Int a;
+    public void main(Int b) {
+        Int a = 1;
+        if (a > 0) {
+            Int a = 2;
+            Int c = 3;
+            while (c > 0) {
+                Int b = 4;
+            }
+        }
+        Int c = 5;
+    }
+}`)
+	errs := Resolve(tu)
+	if len(errs) != 2 {
+		t.Fatal(errs)
+	}
+	if !errors.Is(errs[0], VarRedefineErr) || errs[0].Error() != "7:17: 重复定义的变量 a" {
+		t.Error(errs[0])
+	}
+	if !errors.Is(errs[1], VarRedefineErr) || errs[1].Error() != "10:21: 重复定义的变量 b" {
+		t.Error(errs[1])
+	}
+}
+
+func TestResolveUndefinedMethod(t *testing.T) {
+	tu := parse(t, `
+interface I {
+    void f();
+}
+class A implements I {
+    public void f() {
+    }
+}
+class Main {
+    public void main() {
+        A a = new A();
+        a.nope();
+        I i = a;
+        i.nope();
+    }
+}`)
+	errs := Resolve(tu)
+	if len(errs) != 2 {
+		t.Fatal(errs)
+	}
+	if !errors.Is(errs[0], MethodNotDefineErr) || errs[0].Error() != "12:11: 未定义的方法 A.nope" {
+		t.Error(errs[0])
+	}
+	if !errors.Is(errs[1], MethodNotDefineErr) || errs[1].Error() != "14:11: 未定义的方法 I.nope" {
+		t.Error(errs[1])
+	}
+}
+
+func TestResolveBinding(t *testing.T) {
+	tu := parse(t, `
+class C1 {
+    public C1 next;
+    public Int a;
+    public Int getA(Int b) {
+        Int c = b;
+        return this.next.a;
+    }
+}`)
+	if errs := Resolve(tu); len(errs) != 0 {
+		t.Fatal(errs)
+	}
+
+	method := tu.ClassMap["C1"].MethodDefinitionMap["getA"]["Int"]
+	assign := method.Block.StatementList[0].VarAssignStatement
+	binding := assign.Expression.CallExpression.VarCallExpression.Binding
+	if binding == nil || binding.Type != ast.VarBindingTypeParameter || binding.Parameter != method.ParameterList[0] {
+		t.Error(binding)
+	}
+
+	ret := method.Block.StatementList[1].ReturnStatement
+	binding = ret.Expression.CallExpression.VarCallExpression.Binding
+	if binding == nil || binding.Type != ast.VarBindingTypeProperty || binding.PropertyDefinition.Name != "a" {
+		t.Error(binding)
+	}
+}
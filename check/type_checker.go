@@ -1,15 +1,871 @@
 package check
 
-import "mizar/ast"
+import (
+	"errors"
+	"fmt"
+	"mizar/ast"
+	"mizar/diag"
+	"mizar/utils"
+	"strings"
+)
 
-// 类型检查
+var (
+	TypeMismatchErr     = errors.New("类型不匹配")
+	CondNotBoolErr      = errors.New("条件表达式必须是Bool类型")
+	ReturnValueErr      = errors.New("返回值错误")
+	ArgumentNumErr      = errors.New("参数个数不匹配")
+	MemberNotDefineErr  = errors.New("类型没有该成员")
+	VoidValueErr        = errors.New("void不能作为值使用")
+	ClassNotValueErr    = errors.New("类名不能作为值使用")
+	AbstractInstanceErr = errors.New("不能实例化抽象类")
+	OperatorTypeErr     = errors.New("运算符不支持该类型")
+	ConstructorErr      = errors.New("没有匹配的构造函数")
+	AmbiguousCallErr    = errors.New("调用不明确")
+	SuperCallErr        = errors.New("super调用错误")
+	AccessErr           = errors.New("成员不可访问")
+	MethodMismatchErr   = errors.New("没有匹配的方法")
+	MissingReturnErr    = errors.New("缺少返回语句")
+	InstanceMethodErr   = errors.New("不能通过类名调用实例方法")
+	OutsideLoopErr      = errors.New("break和continue只能用在循环中")
+	AssignTargetErr     = errors.New("不能赋值")
+)
+
+const (
+	typeVoid = "void"
+	typeNull = "null" // null字面量的类型, 可以赋值给任意引用类型
+)
+
+// 类型检查, 需要在Resolve之后进行
+//
+// 类、方法和语句通过Visitor遍历, 表达式的类型需要自底向上计算, 由checkExpr递归完成
+//
+// 重载方法的调用需要实参类型才能确定, 由类型检查完成消解, 以其为接收者的成员访问也在这里消解
 type TypeChecker struct {
+	ast.BaseVisitor
+	tu       *ast.TranslationUnit
+	class    *ast.Class
+	method   *ast.MethodDefinition
+	loops    int                   // 当前语句所在的循环层数
+	resolved map[ast.Node]struct{} // 由类型检查消解的调用表达式
+	diags    diag.List
+}
+
+// 对整个编译单元做类型检查
+func TypeCheck(tu *ast.TranslationUnit) diag.List {
+	tc := &TypeChecker{tu: tu, resolved: make(map[ast.Node]struct{})}
+	tu.Accept(tc)
+	return tc.diags
+}
+
+func (tc *TypeChecker) VisitInterface(inter *ast.Interface) bool {
+	return false
+}
+
+func (tc *TypeChecker) VisitClass(class *ast.Class) bool {
+	tc.class = class
+	tc.checkImplicitSuper(class)
+	return true
+}
+
+func (tc *TypeChecker) VisitPropertyDefinition(pd *ast.PropertyDefinition) bool {
+	if pd.Expr != nil {
+		tc.checkAssign(pd.Type, pd.Expr)
+	}
+	return false
+}
+
+func (tc *TypeChecker) VisitMethodDefinition(method *ast.MethodDefinition) bool {
+	if tc.class.IsConstructor(method) && method.Type != typeVoid {
+		tc.errorf(method.Pos.Extend(method.Name), ReturnValueErr, "构造函数 %s 不能有返回值类型", method.Name)
+	}
+
+	tc.method = method
+	method.Block.Accept(tc)
+	tc.method = nil
+
+	if method.Block != nil && method.Type != typeVoid && !tc.class.IsConstructor(method) && completes(method.Block) {
+		tc.errorf(method.Pos.Extend(method.Name), MissingReturnErr, "方法 %s 需要返回 %s, 但执行可以到达方法末尾", method.Name, method.Type)
+	}
+	return false
+}
+
+func (tc *TypeChecker) VisitExpressionStatement(exprStmt *ast.ExpressionStatement) bool {
+	tc.checkExpr(exprStmt.Expression)
+	return false
+}
+
+func (tc *TypeChecker) VisitVarAssignStatement(varAssignStmt *ast.VarAssignStatement) bool {
+	switch varAssignStmt.Type {
+	case ast.VarAssignStatementTypeVar:
+		tc.checkAssign(varAssignStmt.VarType, varAssignStmt.Expression)
+	case ast.VarAssignStatementTypeVarCall:
+		target := varAssignStmt.VarCallExpression
+		dst := tc.checkVarCallExpr(target)
+		// 只有局部变量、形参和属性可以赋值, this和类名不行
+		if binding := target.Binding; binding != nil && (binding.Type == ast.VarBindingTypeThis || binding.Type == ast.VarBindingTypeClass) {
+			name := target.Var
+			if binding.Type == ast.VarBindingTypeThis {
+				name = "this"
+			}
+			tc.checkExpr(varAssignStmt.Expression)
+			tc.errorf(target.Span, AssignTargetErr, "%s 不是变量或属性", name)
+			return false
+		}
+		tc.checkAssign(dst, varAssignStmt.Expression)
+	}
+	return false
+}
+
+func (tc *TypeChecker) VisitWhileStatement(whileStmt *ast.WhileStatement) bool {
+	tc.checkCond(whileStmt.Expression)
+	tc.loops++
+	whileStmt.Block.Accept(tc)
+	tc.loops--
+	return false
+}
+
+func (tc *TypeChecker) VisitIfStatement(ifStmt *ast.IfStatement) bool {
+	tc.checkCond(ifStmt.CondExpression)
+	ifStmt.IfBlock.Accept(tc)
+	ifStmt.ElseBlock.Accept(tc)
+	return false
+}
+
+func (tc *TypeChecker) VisitForStatement(forStmt *ast.ForStatement) bool {
+	tc.checkExpr(forStmt.InitExpression)
+	tc.checkCond(forStmt.CondExpression)
+	tc.checkExpr(forStmt.PostExpression)
+	tc.loops++
+	forStmt.Block.Accept(tc)
+	tc.loops--
+	return false
+}
+
+func (tc *TypeChecker) VisitBreakStatement(breakStmt *ast.BreakStatement) bool {
+	tc.checkExpr(breakStmt.Expression)
+	if tc.loops == 0 {
+		tc.errorf(breakStmt.Span, OutsideLoopErr, "")
+	}
+	return false
+}
+
+func (tc *TypeChecker) VisitContinueStatement(continueStmt *ast.ContinueStatement) bool {
+	tc.checkExpr(continueStmt.Expression)
+	if tc.loops == 0 {
+		tc.errorf(continueStmt.Span, OutsideLoopErr, "")
+	}
+	return false
+}
+
+func (tc *TypeChecker) VisitReturnStatement(returnStmt *ast.ReturnStatement) bool {
+	tc.checkReturn(returnStmt)
+	return false
+}
+
+func (tc *TypeChecker) VisitSuperCallStatement(superCallStmt *ast.SuperCallStatement) bool {
+	argTypes := tc.checkArgs(superCallStmt.ArgumentList)
+
+	method := tc.method
+	if method == nil || !tc.class.IsConstructor(method) || method.Block.StatementList[0].SuperCallStatement != superCallStmt {
+		tc.errorf(superCallStmt.Span, SuperCallErr, "只能作为构造函数的第一条语句")
+		return false
+	}
+	parent := tc.parent(tc.class)
+	if parent == nil {
+		tc.errorf(superCallStmt.Span, SuperCallErr, "%s 没有父类", tc.class.Name)
+		return false
+	}
+
+	superCallStmt.Constructor, _ = tc.selectConstructor(parent, superCallStmt.ArgumentList, argTypes, superCallStmt.Pos.Extend("super"))
+
+	return false
+}
+
+// 语句之外的表达式(如属性初始值)已经由上层回调检查
+func (tc *TypeChecker) VisitExpression(expr *ast.Expression) bool {
+	return false
+}
+
+func (tc *TypeChecker) checkReturn(returnStmt *ast.ReturnStatement) {
+	if tc.method == nil {
+		return
+	}
+
+	if returnStmt.Expression == nil {
+		if tc.method.Type != typeVoid {
+			tc.errorf(returnStmt.Span, ReturnValueErr, "方法 %s 需要返回 %s", tc.method.Name, tc.method.Type)
+		}
+		return
+	}
+
+	if tc.method.Type == typeVoid {
+		tc.checkExpr(returnStmt.Expression)
+		tc.errorf(returnStmt.Span, ReturnValueErr, "void方法 %s 不能有返回值", tc.method.Name)
+		return
+	}
+
+	tc.checkAssign(tc.method.Type, returnStmt.Expression)
+}
+
+// 执行是否可以到达block的末尾。return之后不能再继续; if和else都不能到达末尾时整个if语句也不能;
+// 条件恒为true且其中没有break的循环不会结束
+func completes(block *ast.Block) bool {
+	if block == nil {
+		return true
+	}
+	for _, stmt := range block.StatementList {
+		switch stmt.Type {
+		case ast.StatementTypeReturn:
+			return false
+		case ast.StatementTypeIf:
+			ifStmt := stmt.IfStatement
+			if ifStmt.ElseBlock != nil && !completes(ifStmt.IfBlock) && !completes(ifStmt.ElseBlock) {
+				return false
+			}
+		case ast.StatementTypeWhile:
+			whileStmt := stmt.WhileStatement
+			if isTrue(whileStmt.Expression) && !breaks(whileStmt.Block) {
+				return false
+			}
+		case ast.StatementTypeFor:
+			forStmt := stmt.ForStatement
+			if (forStmt.CondExpression == nil || isTrue(forStmt.CondExpression)) && !breaks(forStmt.Block) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// block中是否有跳出当前循环的break, 内层循环中的break不算
+func breaks(block *ast.Block) bool {
+	if block == nil {
+		return false
+	}
+	for _, stmt := range block.StatementList {
+		switch stmt.Type {
+		case ast.StatementTypeBreak:
+			return true
+		case ast.StatementTypeIf:
+			if breaks(stmt.IfStatement.IfBlock) || breaks(stmt.IfStatement.ElseBlock) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func isTrue(expr *ast.Expression) bool {
+	return expr != nil && expr.Type == ast.ExpressionTypeBool && expr.BoolLiteral
+}
+
+// 条件表达式必须是Bool类型, nil表示省略(for(;;))
+func (tc *TypeChecker) checkCond(expr *ast.Expression) {
+	if expr == nil {
+		return
+	}
+
+	if t := tc.checkExpr(expr); t != "" && t != "Bool" {
+		tc.errorf(expr.Span, CondNotBoolErr, "实际为 %s", t)
+	}
+}
+
+// 检查expr能否赋值给dst类型
+func (tc *TypeChecker) checkAssign(dst string, expr *ast.Expression) {
+	src := tc.checkExpr(expr)
+	if src == typeVoid {
+		tc.errorf(expr.Span, VoidValueErr, "")
+		return
+	}
+	if dst == "" || src == "" {
+		return
+	}
+	if !tc.assignable(dst, src) {
+		tc.errorf(expr.Span, TypeMismatchErr, "不能将 %s 赋值给 %s", src, dst)
+	}
+}
+
+// 计算表达式的静态类型并记录到expr.ValueType, 返回""表示类型未知(已经报告过错误)
+func (tc *TypeChecker) checkExpr(expr *ast.Expression) string {
+	if expr == nil {
+		return ""
+	}
+
+	expr.ValueType = tc.exprType(expr)
+
+	return expr.ValueType
+}
+
+func (tc *TypeChecker) exprType(expr *ast.Expression) string {
+	switch expr.Type {
+	case ast.ExpressionTypeString:
+		return "String"
+	case ast.ExpressionTypeChar:
+		return "Char"
+	case ast.ExpressionTypeInt:
+		return "Int"
+	case ast.ExpressionTypeDouble:
+		return "Double"
+	case ast.ExpressionTypeBool:
+		return "Bool"
+	case ast.ExpressionTypeNull:
+		return typeNull
+	case ast.ExpressionTypeNewObject:
+		return tc.checkNewObjExpr(expr.NewObjectExpression)
+	case ast.ExpressionTypeCall:
+		return tc.checkCallExpr(expr.CallExpression, false)
+	case ast.ExpressionTypeBinary:
+		return tc.checkBinaryExpr(expr.BinaryExpression)
+	case ast.ExpressionTypeUnary:
+		return tc.checkUnaryExpr(expr.UnaryExpression)
+	}
+
+	return ""
+}
+
+// 二元运算的类型规则:
+//
+//   - Int/Double算术, 混合时结果为Double; String + String 为拼接
+//   - * /      Int/Double算术
+//     %          仅Int
+//     < <= > >=  Int/Double比较, 结果为Bool
+//     == !=      两侧同为数值、同为Bool或者可以互相赋值的引用类型, 结果为Bool
+//     && ||      仅Bool
+func (tc *TypeChecker) checkBinaryExpr(binaryExpr *ast.BinaryExpression) string {
+	left := tc.operand(binaryExpr.Left)
+	right := tc.operand(binaryExpr.Right)
+	if left == "" || right == "" {
+		return ""
+	}
+
+	result := ""
+	switch binaryExpr.Operator {
+	case ast.OperatorAdd:
+		if left == "String" && right == "String" {
+			result = "String"
+		} else {
+			result = arithmeticType(left, right)
+		}
+	case ast.OperatorSub, ast.OperatorMul, ast.OperatorDiv:
+		result = arithmeticType(left, right)
+	case ast.OperatorMod:
+		if left == "Int" && right == "Int" {
+			result = "Int"
+		}
+	case ast.OperatorLt, ast.OperatorLe, ast.OperatorGt, ast.OperatorGe:
+		if arithmeticType(left, right) != "" || left == "Char" && right == "Char" {
+			result = "Bool"
+		}
+	case ast.OperatorEq, ast.OperatorNe:
+		if tc.comparable(left, right) {
+			result = "Bool"
+		}
+	case ast.OperatorAnd, ast.OperatorOr:
+		if left == "Bool" && right == "Bool" {
+			result = "Bool"
+		}
+	}
+
+	if result == "" {
+		tc.errorf(binaryExpr.Span, OperatorTypeErr, "%s %s %s", left, binaryExpr.Operator, right)
+	}
+
+	return result
+}
+
+func (tc *TypeChecker) checkUnaryExpr(unaryExpr *ast.UnaryExpression) string {
+	operand := tc.operand(unaryExpr.Operand)
+	if operand == "" {
+		return ""
+	}
+
+	result := ""
+	switch unaryExpr.Operator {
+	case ast.OperatorNeg:
+		result = arithmeticType(operand, operand)
+	case ast.OperatorNot:
+		if operand == "Bool" {
+			result = "Bool"
+		}
+	}
+
+	if result == "" {
+		tc.errorf(unaryExpr.Span, OperatorTypeErr, "%s%s", unaryExpr.Operator, operand)
+	}
+
+	return result
+}
+
+// 运算符的操作数, void值直接报错
+func (tc *TypeChecker) operand(expr *ast.Expression) string {
+	t := tc.checkExpr(expr)
+	if t == typeVoid {
+		tc.errorf(expr.Span, VoidValueErr, "")
+		return ""
+	}
+
+	return t
+}
+
+// 算术运算的结果类型, 不支持时返回""
+func arithmeticType(left string, right string) string {
+	if !isNumericType(left) || !isNumericType(right) {
+		return ""
+	}
+	if left == "Double" || right == "Double" {
+		return "Double"
+	}
+
+	return "Int"
+}
+
+func isNumericType(typeName string) bool {
+	return typeName == "Int" || typeName == "Double"
+}
+
+// ==和!=两侧的类型是否可以比较
+func (tc *TypeChecker) comparable(left string, right string) bool {
+	if isNumericType(left) && isNumericType(right) {
+		return true
+	}
+	if left == "Bool" || right == "Bool" || left == "Char" || right == "Char" {
+		return left == right
+	}
+	if left == typeNull && right == typeNull {
+		return true
+	}
+
+	return tc.assignable(left, right) || tc.assignable(right, left)
+}
+
+func (tc *TypeChecker) checkNewObjExpr(newObjExpr *ast.NewObjectExpression) string {
+	argTypes := tc.checkArgs(newObjExpr.ArgumentList)
+
+	if newObjExpr.Class == nil {
+		return ""
+	}
+	if newObjExpr.Class.IsAbstract {
+		tc.errorf(newObjExpr.Span, AbstractInstanceErr, "%s", newObjExpr.Name)
+	}
+	newObjExpr.Constructor, _ = tc.selectConstructor(newObjExpr.Class, newObjExpr.ArgumentList, argTypes, newObjExpr.Pos.Extend(newObjExpr.Name))
+
+	return newObjExpr.Class.Name
+}
+
+// 检查实参, void实参直接报错并视为类型未知
+func (tc *TypeChecker) checkArgs(args []*ast.Expression) []string {
+	argTypes := make([]string, 0, len(args))
+	for _, arg := range args {
+		t := tc.checkExpr(arg)
+		if t == typeVoid {
+			tc.errorf(arg.Span, VoidValueErr, "")
+			t = ""
+		}
+		argTypes = append(argTypes, t)
+	}
+
+	return argTypes
+}
+
+// 按实参类型选择构造函数, 没有声明构造函数时只能使用无参的默认构造函数, 此时md为nil
+func (tc *TypeChecker) selectConstructor(class *ast.Class, args []*ast.Expression, argTypes []string, span ast.Span) (md *ast.MethodDefinition, ok bool) {
+	if len(class.ConstructorMap) == 0 {
+		if len(args) > 0 {
+			tc.errorf(span, ConstructorErr, "%s(%s)", class.Name, strings.Join(argTypes, ","))
+			return nil, false
+		}
+		return nil, true
+	}
+
+	keys := utils.SortedKeys(class.ConstructorMap)
+	paramLists := make([][]*ast.Parameter, 0, len(keys))
+	for _, key := range keys {
+		paramLists = append(paramLists, class.ConstructorMap[key].ParameterList)
+	}
+
+	matched := tc.mostSpecific(paramLists, argTypes)
+	switch len(matched) {
+	case 0:
+		tc.errorf(span, ConstructorErr, "%s(%s)", class.Name, strings.Join(argTypes, ","))
+		return nil, false
+	case 1:
+		md = class.ConstructorMap[keys[matched[0]]]
+		tc.checkAccess(class, md.ModifierType, class.Name, span)
+		return md, true
+	}
+
+	candidates := make([]string, 0, len(matched))
+	for _, i := range matched {
+		candidates = append(candidates, fmt.Sprintf("%s(%s)", class.Name, keys[i]))
+	}
+	tc.errorf(span, AmbiguousCallErr, "候选: %s", strings.Join(candidates, ", "))
+
+	return nil, false
+}
+
+// 重载决议: 在实参可以赋值给形参的候选中, 选出形参类型最具体的, 返回其下标; 多于一个时表示调用不明确
+//
+// 候选a比b更具体是指a的每个形参都可以赋值给b对应的形参; 类型未知的实参与任意形参匹配
+func (tc *TypeChecker) mostSpecific(paramLists [][]*ast.Parameter, argTypes []string) []int {
+	var applicable []int
+	for i, paramList := range paramLists {
+		if tc.applicable(paramList, argTypes) {
+			applicable = append(applicable, i)
+		}
+	}
+
+	// 去掉存在更具体候选的, 剩余多于一个时调用不明确
+	var result []int
+	for _, i := range applicable {
+		maximal := true
+		for _, j := range applicable {
+			if i != j && tc.moreSpecific(paramLists[j], paramLists[i]) && !tc.moreSpecific(paramLists[i], paramLists[j]) {
+				maximal = false
+				break
+			}
+		}
+		if maximal {
+			result = append(result, i)
+		}
+	}
+
+	return result
+}
+
+func (tc *TypeChecker) applicable(paramList []*ast.Parameter, argTypes []string) bool {
+	if len(paramList) != len(argTypes) {
+		return false
+	}
+	for i, param := range paramList {
+		if argTypes[i] != "" && !tc.assignable(param.Type, argTypes[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (tc *TypeChecker) moreSpecific(a []*ast.Parameter, b []*ast.Parameter) bool {
+	for i := range a {
+		if !tc.assignable(b[i].Type, a[i].Type) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// 构造函数的第一条语句不是super(...)或者类没有声明构造函数时, 隐式调用父类的无参构造函数,
+// 因此父类没有无参构造函数时每个构造函数都必须显式调用super(...)
+func (tc *TypeChecker) checkImplicitSuper(class *ast.Class) {
+	parent := tc.parent(class)
+	if parent == nil {
+		return
+	}
+	if _, exists := parent.DefaultConstructor(); exists {
+		return
+	}
+
+	if len(class.ConstructorMap) == 0 {
+		tc.errorf(class.Pos.Extend(class.Name), ConstructorErr, "父类 %s 没有无参构造函数, 需要声明构造函数并调用super(...)", parent.Name)
+		return
+	}
+	for _, key := range utils.SortedKeys(class.ConstructorMap) {
+		md := class.ConstructorMap[key]
+		if md.IsNative {
+			continue
+		}
+		if stmts := md.Block.StatementList; len(stmts) == 0 || stmts[0].Type != ast.StatementTypeSuperCall {
+			tc.errorf(md.Pos.Extend(md.Name), ConstructorErr, "父类 %s 没有无参构造函数, 需要调用super(...)", parent.Name)
+		}
+	}
+}
+
+func (tc *TypeChecker) parent(class *ast.Class) *ast.Class {
+	if len(class.Extends) == 0 {
+		return nil
+	}
+
+	return tc.tu.ClassMap[class.Extends[0]]
+}
+
+// asReceiver为true时表示该表达式是成员访问的接收者, 此时允许类名引用
+func (tc *TypeChecker) checkCallExpr(callExpr *ast.CallExpression, asReceiver bool) string {
+	switch callExpr.Type {
+	case ast.CallExpressionTypeValCall:
+		varCallExpr := callExpr.VarCallExpression
+		t := tc.checkVarCallExpr(varCallExpr)
+		if !asReceiver && varCallExpr.Binding != nil && varCallExpr.Binding.Type == ast.VarBindingTypeClass {
+			tc.errorf(varCallExpr.Span, ClassNotValueErr, "%s", t)
+			return ""
+		}
+		return t
+	case ast.CallExpressionTypeMethodCall:
+		return tc.checkMethodCallExpr(callExpr.MethodCallExpression)
+	}
+
+	return ""
+}
+
+func (tc *TypeChecker) checkVarCallExpr(varCallExpr *ast.VarCallExpression) string {
+	if varCallExpr.Type == ast.VarCallExpressionTypeCall {
+		receiverType := tc.checkCallExpr(varCallExpr.CallExpression, true)
+		if varCallExpr.Binding == nil && tc.deferred(varCallExpr.CallExpression) {
+			tc.resolveProperty(varCallExpr, receiverType)
+		}
+		if varCallExpr.Binding == nil {
+			tc.checkMember(receiverType, varCallExpr.Var, varCallExpr.Pos)
+			return ""
+		}
+	}
+
+	if varCallExpr.Binding == nil {
+		return ""
+	}
+	// 直接引用的属性可能是继承自父类的private属性
+	if binding := varCallExpr.Binding; binding.Type == ast.VarBindingTypeProperty {
+		tc.checkAccess(binding.Class, binding.PropertyDefinition.ModifierType, varCallExpr.Var, varCallExpr.Pos.Extend(varCallExpr.Var))
+	}
+
+	return varCallExpr.Binding.VarType()
+}
+
+func (tc *TypeChecker) checkMethodCallExpr(methodCallExpr *ast.MethodCallExpression) string {
+	receiverType := tc.checkCallExpr(methodCallExpr.CallExpression, true)
+
+	argTypes := make([]string, 0, len(methodCallExpr.ArgumentList))
+	for _, arg := range methodCallExpr.ArgumentList {
+		argTypes = append(argTypes, tc.checkExpr(arg))
+	}
+
+	if methodCallExpr.Binding == nil && len(methodCallExpr.Candidates) == 0 && tc.deferred(methodCallExpr.CallExpression) {
+		class, inter := tc.typeDefinition(receiverType)
+		if class != nil || inter != nil {
+			methodCallExpr.Candidates = methodCandidates(tc.tu, class, inter, methodCallExpr.Name, len(argTypes))
+			if len(methodCallExpr.Candidates) == 0 {
+				tc.diags.Add(diag.Errorf(errCodes[MethodNotDefineErr], methodCallExpr.Pos.Extend(methodCallExpr.Name), MethodNotDefineErr, "%s.%s", receiverType, methodCallExpr.Name))
+				return ""
+			}
+		}
+	}
+	if methodCallExpr.Binding == nil && len(methodCallExpr.Candidates) > 0 {
+		tc.selectMethod(methodCallExpr, argTypes)
+	}
+
+	binding := methodCallExpr.Binding
+	if binding == nil {
+		tc.checkMember(receiverType, methodCallExpr.Name, methodCallExpr.Pos)
+		return ""
+	}
+	if binding.Type == ast.MethodBindingTypeClass {
+		tc.checkAccess(binding.Class, binding.MethodDefinition.ModifierType, methodCallExpr.Name, methodCallExpr.Pos.Extend(methodCallExpr.Name))
+		if isClassReceiver(methodCallExpr.CallExpression) && usesThis(binding.Class, binding.MethodDefinition) {
+			tc.errorf(methodCallExpr.Pos.Extend(methodCallExpr.Name), InstanceMethodErr, "%s.%s 使用了this或属性", binding.Class.Name, methodCallExpr.Name)
+		}
+	}
+
+	paramList := binding.ParameterList()
+	if len(paramList) != len(argTypes) {
+		tc.errorf(methodCallExpr.Pos.Extend(methodCallExpr.Name), ArgumentNumErr, "%s 需要 %d 个参数, 实际 %d 个", methodCallExpr.Name, len(paramList), len(argTypes))
+	} else {
+		for i, param := range paramList {
+			if argTypes[i] == typeVoid {
+				tc.errorf(methodCallExpr.ArgumentList[i].Span, VoidValueErr, "")
+			} else if argTypes[i] != "" && !tc.assignable(param.Type, argTypes[i]) {
+				tc.errorf(methodCallExpr.ArgumentList[i].Span, TypeMismatchErr, "不能将 %s 赋值给 %s", argTypes[i], param.Type)
+			}
+		}
+	}
+
+	return binding.ReturnType()
+}
+
+// 接收者是否为类名, 此时方法中的this为null
+func isClassReceiver(callExpr *ast.CallExpression) bool {
+	if callExpr.Type != ast.CallExpressionTypeValCall {
+		return false
+	}
+	binding := callExpr.VarCallExpression.Binding
+
+	return binding != nil && binding.Type == ast.VarBindingTypeClass
+}
+
+// 方法是否用到this, 包括直接引用属性; 这样的方法不能通过类名调用。
+// native方法无法分析: 基本类型上的native方法(parse除外)以值本身为this, 其他类上的native方法不使用this
+func usesThis(class *ast.Class, md *ast.MethodDefinition) bool {
+	if md.IsNative {
+		return ast.IsPrimitiveType(class.Name) && md.Name != "parse"
+	}
+
+	finder := &thisFinder{}
+	ast.Walk(finder, md.Block)
+	return finder.found
+}
+
+type thisFinder struct {
+	ast.BaseVisitor
+	found bool
+}
+
+func (f *thisFinder) VisitVarCallExpression(varCallExpr *ast.VarCallExpression) bool {
+	if binding := varCallExpr.Binding; binding != nil {
+		implicit := varCallExpr.Type == ast.VarCallExpressionTypeVar && binding.Type == ast.VarBindingTypeProperty
+		f.found = f.found || binding.Type == ast.VarBindingTypeThis || implicit
+	}
+	return !f.found
+}
+
+// 按实参类型在重载候选中选择最具体的方法
+func (tc *TypeChecker) selectMethod(methodCallExpr *ast.MethodCallExpression, argTypes []string) {
+	candidates := methodCallExpr.Candidates
+	paramLists := make([][]*ast.Parameter, 0, len(candidates))
+	for _, candidate := range candidates {
+		paramLists = append(paramLists, candidate.ParameterList())
+	}
+
+	span := methodCallExpr.Pos.Extend(methodCallExpr.Name)
+	matched := tc.mostSpecific(paramLists, argTypes)
+	switch len(matched) {
+	case 0:
+		tc.errorf(span, MethodMismatchErr, "%s.%s(%s)", candidates[0].Owner(), methodCallExpr.Name, strings.Join(argTypes, ","))
+		return
+	case 1:
+		methodCallExpr.Binding = candidates[matched[0]]
+		tc.resolved[methodCallExpr] = struct{}{}
+		return
+	}
+
+	names := make([]string, 0, len(matched))
+	for _, i := range matched {
+		names = append(names, candidates[i].Owner()+"."+candidates[i].Signature())
+	}
+	tc.errorf(span, AmbiguousCallErr, "候选: %s", strings.Join(names, ", "))
+}
+
+// 接收者由类型检查消解时, Resolver无法确定接收者的类型, 需要在这里查找属性
+func (tc *TypeChecker) resolveProperty(varCallExpr *ast.VarCallExpression, receiverType string) {
+	class, _ := tc.typeDefinition(receiverType)
+	if class == nil {
+		return
+	}
+
+	for _, c := range classChain(tc.tu, class) {
+		if pd, exists := c.PropertyDefinitionMap[varCallExpr.Var]; exists {
+			varCallExpr.Binding = &ast.VarBinding{Class: c, PropertyDefinition: pd, Type: ast.VarBindingTypeProperty}
+			tc.resolved[varCallExpr] = struct{}{}
+			return
+		}
+	}
+
+	tc.diags.Add(diag.Errorf(errCodes[PropertyNotDefineErr], varCallExpr.Pos.Extend(varCallExpr.Var), PropertyNotDefineErr, "%s.%s", class.Name, varCallExpr.Var))
+}
+
+// 接收者是否由类型检查消解, 此时Resolver没有消解以其为接收者的成员, 也没有报告错误
+func (tc *TypeChecker) deferred(callExpr *ast.CallExpression) bool {
+	var node ast.Node = callExpr.VarCallExpression
+	if callExpr.Type == ast.CallExpressionTypeMethodCall {
+		node = callExpr.MethodCallExpression
+	}
+	_, exists := tc.resolved[node]
+
+	return exists
+}
+
+func (tc *TypeChecker) typeDefinition(typeName string) (*ast.Class, *ast.Interface) {
+	if class, exists := tc.tu.ClassMap[typeName]; exists {
+		return class, nil
+	}
+
+	return nil, tc.tu.InterfaceMap[typeName]
+}
+
+// 成员未被消解时, 对基本类型等非类类型的接收者报告错误, 类类型的错误已经由Resolver报告
+func (tc *TypeChecker) checkMember(receiverType string, name string, pos ast.Pos) {
+	if receiverType == "" {
+		return
+	}
+	if receiverType == typeVoid {
+		tc.errorf(pos.Extend(name), VoidValueErr, "")
+		return
+	}
+	if _, exists := tc.tu.ClassMap[receiverType]; exists {
+		return
+	}
+	if _, exists := tc.tu.InterfaceMap[receiverType]; exists {
+		return
+	}
+
+	tc.errorf(pos.Extend(name), MemberNotDefineErr, "%s.%s", receiverType, name)
+}
+
+// 访问控制: private成员只能在所在类中访问, protected成员可以在所在类及其子类中访问,
+// public和abstract成员以及接口方法可以在任意位置访问
+func (tc *TypeChecker) checkAccess(owner *ast.Class, modifier ast.MemberModifierType, name string, span ast.Span) {
+	accessible := true
+	switch modifier {
+	case ast.ModifierPrivate:
+		accessible = tc.class == owner
+	case ast.ModifierProtected:
+		accessible = tc.class != nil && tc.assignable(owner.Name, tc.class.Name)
+	}
+
+	if !accessible {
+		tc.errorf(span, AccessErr, "%s.%s 是 %s 的", owner.Name, name, modifier)
+	}
+}
+
+func (tc *TypeChecker) assignable(dst string, src string) bool {
+	return assignable(tc.tu, dst, src)
+}
+
+// src类型的值能否赋值给dst类型
+func assignable(tu *ast.TranslationUnit, dst string, src string) bool {
+	if dst == src {
+		return true
+	}
+
+	if src == typeNull {
+		return isReferenceType(tu, dst)
+	}
+
+	class, exists := tu.ClassMap[src]
+	if !exists {
+		return false
+	}
+	for _, c := range classChain(tu, class) {
+		if c.Name == dst {
+			return true
+		}
+		for _, interName := range c.Implements {
+			if interName == dst {
+				return true
+			}
+		}
+	}
+
+	return false
 }
 
-func (tc *TypeChecker) visit(node *ast.Node) {
+// 引用类型: 类、接口以及String, 可以为null; 标准库中基本类型对应的类不是引用类型
+func isReferenceType(tu *ast.TranslationUnit, typeName string) bool {
+	if ast.IsPrimitiveType(typeName) {
+		return typeName == "String"
+	}
+	if _, exists := tu.ClassMap[typeName]; exists {
+		return true
+	}
+	_, exists := tu.InterfaceMap[typeName]
 
+	return exists
 }
 
-func (tc *TypeChecker) checkExpr() {
+func (tc *TypeChecker) errorf(span ast.Span, err error, format string, args ...interface{}) {
+	msg := err.Error()
+	if format != "" {
+		msg = fmt.Sprintf("%s, %s", err, fmt.Sprintf(format, args...))
+	}
 
+	tc.diags.Add(diag.New(diag.SeverityError, errCodes[err], span, err, msg))
 }
@@ -0,0 +1,509 @@
+package check
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTypeCheck(t *testing.T) {
+	tu := parse(t, `
+class C1 {
+    public Int a = 1;
+    public Bool b = "b";
+
+    public Int getA() {
+        int a = this.a;
+        if (this.a) {
+            return;
+        }
+        while (this.b) {
+        }
+        return this.a;
+    }
+
+    public void setA(Int a) {
+        this.a = a;
+        this.setA(1.5);
+        return a;
+    }
+}`)
+	if errs := Resolve(tu); len(errs) != 1 || !errors.Is(errs[0], TypeNotDefineErr) {
+		t.Fatal(errs)
+	}
+
+	expects := []struct {
+		err error
+		msg string
+	}{
+		{TypeMismatchErr, `4:21: 类型不匹配, 不能将 String 赋值给 Bool`},
+		{TypeMismatchErr, `7:17: 类型不匹配, 不能将 Int 赋值给 int`},
+		{CondNotBoolErr, `8:13: 条件表达式必须是Bool类型, 实际为 Int`},
+		{ReturnValueErr, `9:13: 返回值错误, 方法 getA 需要返回 Int`},
+		{TypeMismatchErr, `18:19: 类型不匹配, 不能将 Double 赋值给 Int`},
+		{ReturnValueErr, `19:9: 返回值错误, void方法 setA 不能有返回值`},
+	}
+	errs := TypeCheck(tu)
+	if len(errs) != len(expects) {
+		t.Fatal(errs)
+	}
+	for i, expect := range expects {
+		if !errors.Is(errs[i], expect.err) || errs[i].Error() != expect.msg {
+			t.Error(errs[i])
+		}
+	}
+}
+
+func TestAssignable(t *testing.T) {
+	tu := parse(t, `
+interface I {
+}
+class A implements I {
+}
+class B extends A {
+}`)
+	tc := &TypeChecker{tu: tu}
+	cases := []struct {
+		dst, src string
+		ok       bool
+	}{
+		{"A", "B", true},
+		{"I", "B", true},
+		{"B", "A", false},
+		{"String", typeNull, true},
+		{"Int", typeNull, false},
+		{"Double", "Int", false},
+	}
+	for _, c := range cases {
+		if tc.assignable(c.dst, c.src) != c.ok {
+			t.Error(c)
+		}
+	}
+}
+
+func TestTypeCheckOperator(t *testing.T) {
+	tu := parse(t, `
+class A {
+    public Int i = 1 + 2 * 3 % 4;
+    public Double d = 1 + 2.5;
+    public String s = "a" + "b";
+
+    public void v() {
+        Bool b = 1 < 2.5 && !(this == null) || "a" != "b";
+        Bool c = 1.5 % 2;
+        Bool e = !1;
+        Int f = "a" + 1;
+        Bool g = true == 1;
+        Int h = -this.v();
+    }
+}`)
+	if errs := Resolve(tu); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	expects := []string{
+		`9:18: 运算符不支持该类型, Double % Int`,
+		`10:18: 运算符不支持该类型, !Int`,
+		`11:17: 运算符不支持该类型, String + Int`,
+		`12:18: 运算符不支持该类型, Bool == Int`,
+		`13:18: void不能作为值使用`,
+	}
+	errs := TypeCheck(tu)
+	if len(errs) != len(expects) {
+		t.Fatal(errs)
+	}
+	for i, expect := range expects {
+		if !errors.Is(errs[i], OperatorTypeErr) && !errors.Is(errs[i], VoidValueErr) || errs[i].Error() != expect {
+			t.Error(errs[i])
+		}
+	}
+
+	if d := tu.ClassMap["A"].PropertyDefinitionMap["d"].Expr; d.ValueType != "Double" {
+		t.Error(d.ValueType)
+	}
+}
+
+func TestTypeCheckConstructor(t *testing.T) {
+	tu := parse(t, `
+class P {
+}
+class Q extends P {
+}
+class A {
+    public void A(Int x) {
+    }
+    public void A(P p, P q) {
+    }
+    public void A(Q p, P q) {
+    }
+    public void A(P p, Q q) {
+    }
+}
+class B extends A {
+}
+class C extends A {
+    public void C() {
+        Int y = 1;
+        super(y);
+    }
+    public Int C(Bool b) {
+        super(1);
+    }
+    public void C(String s) {
+        super(new Q(), new P());
+    }
+    public void m() {
+        A a = new A(1.5);
+        A b = new A(new Q(), new Q());
+        P p = new P(1);
+        super(1);
+    }
+}`)
+	if errs := Resolve(tu); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	expects := []struct {
+		err error
+		msg string
+	}{
+		{ConstructorErr, `16:7: 没有匹配的构造函数, 父类 A 没有无参构造函数, 需要声明构造函数并调用super(...)`},
+		{ConstructorErr, `19:17: 没有匹配的构造函数, 父类 A 没有无参构造函数, 需要调用super(...)`},
+		{SuperCallErr, `21:9: super调用错误, 只能作为构造函数的第一条语句`},
+		{ReturnValueErr, `23:16: 返回值错误, 构造函数 C 不能有返回值类型`},
+		{ConstructorErr, `30:19: 没有匹配的构造函数, A(Double)`},
+		{AmbiguousCallErr, `31:19: 调用不明确, 候选: A(P,Q), A(Q,P)`},
+		{ConstructorErr, `32:19: 没有匹配的构造函数, P(Int)`},
+		{SuperCallErr, `33:9: super调用错误, 只能作为构造函数的第一条语句`},
+	}
+	errs := TypeCheck(tu)
+	if len(errs) != len(expects) {
+		t.Fatal(errs)
+	}
+	for i, expect := range expects {
+		if !errors.Is(errs[i], expect.err) || errs[i].Error() != expect.msg {
+			t.Error(errs[i])
+		}
+	}
+
+	c := tu.ClassMap["C"]
+	if stmt := c.ConstructorMap["String"].Block.StatementList[0].SuperCallStatement; stmt.Constructor != tu.ClassMap["A"].ConstructorMap["Q,P"] {
+		t.Error(stmt.Constructor)
+	}
+	if _, exists := c.MethodDefinitionMap["C"]; exists {
+		t.Error("constructor in MethodDefinitionMap")
+	}
+}
+
+func TestTypeCheckAccess(t *testing.T) {
+	tu := parse(t, `
+class C1 {
+    private Int f = 1;
+    protected Int g = 2;
+    public Int h = 3;
+    private void C1() {
+    }
+    protected void C1(Int x) {
+    }
+    private Int m() {
+        return this.f;
+    }
+    protected Int n() {
+        return f + this.m();
+    }
+}
+class C2 extends C1 {
+    public void C2() {
+        super(1);
+        Int a = this.g + g + this.n();
+        Int b = f + this.m();
+    }
+}
+class C3 {
+    public void m(C1 c) {
+        Int a = c.f + c.g + c.h;
+        c.n();
+        C1 d = new C1();
+    }
+}`)
+	if errs := Resolve(tu); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	expects := []struct {
+		err error
+		msg string
+	}{
+		{AccessErr, `21:17: 成员不可访问, C1.f 是 private 的`},
+		{AccessErr, `21:26: 成员不可访问, C1.m 是 private 的`},
+		{AccessErr, `26:19: 成员不可访问, C1.f 是 private 的`},
+		{AccessErr, `26:25: 成员不可访问, C1.g 是 protected 的`},
+		{AccessErr, `27:11: 成员不可访问, C1.n 是 protected 的`},
+		{AccessErr, `28:20: 成员不可访问, C1.C1 是 private 的`},
+	}
+	errs := TypeCheck(tu)
+	if len(errs) != len(expects) {
+		t.Fatal(errs)
+	}
+	for i, expect := range expects {
+		if !errors.Is(errs[i], expect.err) || errs[i].Error() != expect.msg {
+			t.Error(errs[i])
+		}
+	}
+}
+
+func TestTypeCheckOverload(t *testing.T) {
+	tu := parse(t, `
+class P {
+    public Int x;
+}
+class Q extends P {
+}
+class A {
+    public P m(Int a) {
+        return null;
+    }
+    public Q m(Double a) {
+        return null;
+    }
+    public Int m(P p, Q q) {
+        return 1;
+    }
+    public Int m(Q p, P q) {
+        return 2;
+    }
+}
+class B extends A {
+    public Q m(Int a) {
+        return null;
+    }
+    public void f() {
+        Int a = this.m(1).x;
+        Q b = this.m(1.5);
+        this.m(new Q(), new Q());
+        this.m("s");
+        this.m(1).y;
+        Int c = this.m(new P(), new Q()).m(1);
+    }
+}`)
+	if errs := Resolve(tu); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	expects := []struct {
+		err error
+		msg string
+	}{
+		{AmbiguousCallErr, `28:14: 调用不明确, 候选: A.m(P,Q), A.m(Q,P)`},
+		{MethodMismatchErr, `29:14: 没有匹配的方法, B.m(String)`},
+		{PropertyNotDefineErr, `30:19: 未定义的属性 Q.y`},
+		{MemberNotDefineErr, `31:42: 类型没有该成员, Int.m`},
+	}
+	errs := TypeCheck(tu)
+	if len(errs) != len(expects) {
+		t.Fatal(errs)
+	}
+	for i, expect := range expects {
+		if !errors.Is(errs[i], expect.err) || errs[i].Error() != expect.msg {
+			t.Error(errs[i])
+		}
+	}
+
+	block := tu.ClassMap["B"].MethodDefinitionMap["f"][""].Block
+	call := block.StatementList[0].VarAssignStatement.Expression.CallExpression.VarCallExpression
+	if binding := call.CallExpression.MethodCallExpression.Binding; binding == nil || binding.Class.Name != "B" {
+		t.Error(binding)
+	}
+	if binding := call.Binding; binding == nil || binding.PropertyDefinition != tu.ClassMap["P"].PropertyDefinitionMap["x"] {
+		t.Error(binding)
+	}
+}
+
+func TestTypeCheckMissingReturn(t *testing.T) {
+	tu := parse(t, `
+class A {
+    public Bool b(Int x) {
+        if (x > 1) {
+            return true;
+        }
+    }
+
+    public Bool c(Int x) {
+        if (x > 1) {
+            return true;
+        } else {
+            if (x > 0) {
+                return false;
+            } else {
+                return true;
+            }
+        }
+    }
+
+    public Int d(Int x) {
+        while (true) {
+            if (x > 1) {
+                return x;
+            }
+        }
+    }
+
+    public Int e(Int x) {
+        for (;;) {
+            while (true) {
+                break;
+            }
+        }
+    }
+
+    public Int f(Int x) {
+        while (true) {
+            if (x > 1) {
+                break;
+            }
+        }
+    }
+
+    public Int g(Int x) {
+        while (x > 1) {
+            return x;
+        }
+    }
+
+    public void h(Int x) {
+    }
+}`)
+	if errs := Resolve(tu); len(errs) != 0 {
+		t.Fatal(errs)
+	}
+
+	expects := []string{
+		`3:17: 缺少返回语句, 方法 b 需要返回 Bool, 但执行可以到达方法末尾`,
+		`37:16: 缺少返回语句, 方法 f 需要返回 Int, 但执行可以到达方法末尾`,
+		`45:16: 缺少返回语句, 方法 g 需要返回 Int, 但执行可以到达方法末尾`,
+	}
+	errs := TypeCheck(tu)
+	if len(errs) != len(expects) {
+		t.Fatal(errs)
+	}
+	for i, expect := range expects {
+		if !errors.Is(errs[i], MissingReturnErr) || errs[i].Error() != expect {
+			t.Error(errs[i])
+		}
+	}
+}
+
+func TestTypeCheckInstanceMethod(t *testing.T) {
+	tu := parse(t, `
+class A {
+    public Int x;
+    public Int get() {
+        return this.x;
+    }
+    public Int field() {
+        return x;
+    }
+    public void set(Int a) {
+        x = a;
+    }
+    public Int add(Int a, Int b) {
+        return a + b;
+    }
+    public void f() {
+        Int q = A.get();
+        q = A.field();
+        A.set(1);
+        q = A.add(1, 2);
+        q = this.get();
+    }
+}`)
+	if errs := Resolve(tu); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	expects := []string{
+		`17:19: 不能通过类名调用实例方法, A.get 使用了this或属性`,
+		`18:15: 不能通过类名调用实例方法, A.field 使用了this或属性`,
+		`19:11: 不能通过类名调用实例方法, A.set 使用了this或属性`,
+	}
+	errs := TypeCheck(tu)
+	if len(errs) != len(expects) {
+		t.Fatal(errs)
+	}
+	for i, expect := range expects {
+		if !errors.Is(errs[i], InstanceMethodErr) || errs[i].Error() != expect {
+			t.Error(errs[i])
+		}
+	}
+}
+
+func TestTypeCheckOutsideLoop(t *testing.T) {
+	tu := parse(t, `
+class A {
+    public void f(Int a) {
+        break;
+        while (a > 0) {
+            if (a > 1) {
+                break;
+            }
+            continue;
+        }
+        for (;;) {
+            for (;;) {
+                break;
+            }
+            continue;
+        }
+        if (a > 1) {
+            continue;
+        }
+    }
+}`)
+	if errs := Resolve(tu); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	expects := []string{
+		`4:9: break和continue只能用在循环中`,
+		`18:13: break和continue只能用在循环中`,
+	}
+	errs := TypeCheck(tu)
+	if len(errs) != len(expects) {
+		t.Fatal(errs)
+	}
+	for i, expect := range expects {
+		if !errors.Is(errs[i], OutsideLoopErr) || errs[i].Error() != expect {
+			t.Error(errs[i])
+		}
+	}
+}
+
+func TestTypeCheckAssignTarget(t *testing.T) {
+	tu := parse(t, `
+class A {
+    public A a;
+    public void f(Int b) {
+        this = new A();
+        A = null;
+        this.a = this;
+        a = null;
+        b = 1;
+    }
+}`)
+	if errs := Resolve(tu); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	expects := []string{
+		`5:9: 不能赋值, this 不是变量或属性`,
+		`6:9: 不能赋值, A 不是变量或属性`,
+	}
+	errs := TypeCheck(tu)
+	if len(errs) != len(expects) {
+		t.Fatal(errs)
+	}
+	for i, expect := range expects {
+		if !errors.Is(errs[i], AssignTargetErr) || errs[i].Error() != expect {
+			t.Error(errs[i])
+		}
+	}
+}
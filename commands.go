@@ -0,0 +1,308 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mizar/asm"
+	"mizar/diag"
+	"mizar/format"
+	"mizar/interp"
+	"mizar/lexer"
+	"mizar/loader"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+func buildCommand(cmd *command, args []string) int {
+	fs, logLevel := cmd.flagSet()
+	output := fs.String("o", "", "输出文件, 扩展名为 .s 时输出汇编, 为 .o 时输出目标文件, 否则输出可执行文件; 默认为入口名去掉扩展名")
+	entry, code, ok := cmd.parse(fs, logLevel, args)
+	if !ok {
+		return code
+	}
+
+	if *output == "" {
+		*output = strings.TrimSuffix(filepath.Base(entry), filepath.Ext(entry))
+	}
+
+	return build(entry, *output)
+}
+
+func checkCommand(cmd *command, args []string) int {
+	fs, logLevel := cmd.flagSet()
+	entry, code, ok := cmd.parse(fs, logLevel, args)
+	if !ok {
+		return code
+	}
+
+	if tu, _ := compile(entry); tu == nil {
+		return exitError
+	}
+
+	return exitOK
+}
+
+// 默认由解释器执行, -native时编译到临时目录中再执行; 程序的标准输入输出直接使用当前进程的
+func runCommand(cmd *command, args []string) int {
+	fs, logLevel := cmd.flagSet()
+	native := fs.Bool("native", false, "编译为可执行文件后执行, 需要 as 和 ld")
+	entry, code, ok := cmd.parse(fs, logLevel, args)
+	if !ok {
+		return code
+	}
+
+	if *native {
+		return runNative(entry)
+	}
+
+	tu, renderer := compile(entry)
+	if tu == nil {
+		return exitError
+	}
+
+	code, err := interp.Run(tu, os.Stdin, os.Stdout)
+	if err != nil {
+		report(renderer, err)
+	}
+
+	return code
+}
+
+func runNative(entry string) int {
+	dir, err := ioutil.TempDir("", "mizar")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "mizar:", err)
+		return exitError
+	}
+	defer os.RemoveAll(dir)
+
+	exeFile := filepath.Join(dir, "main")
+	if code := build(entry, exeFile); code != exitOK {
+		return code
+	}
+
+	program := exec.Command(exeFile)
+	program.Stdin, program.Stdout, program.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err = program.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			// 与shell相同, 被信号终止(如访问空引用时的SIGSEGV)时退出码为128+信号值
+			if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+				fmt.Fprintf(os.Stderr, "mizar: 程序被信号终止: %s\n", status.Signal())
+				return 128 + int(status.Signal())
+			}
+			return exitErr.ExitCode()
+		}
+		fmt.Fprintln(os.Stderr, "mizar:", err)
+		return exitError
+	}
+
+	return exitOK
+}
+
+// 每行一个token: 位置 类型 词素
+func tokensCommand(cmd *command, args []string) int {
+	fs, logLevel := cmd.flagSet()
+	file, code, ok := cmd.parse(fs, logLevel, args)
+	if !ok {
+		return code
+	}
+
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "mizar:", err)
+		return exitError
+	}
+
+	renderer := diag.NewRenderer()
+	renderer.AddSource(file, string(b))
+	l := lexer.NewFileLexer(file, string(b))
+	code = exitOK
+	for {
+		t, err := l.Next()
+		if err != nil {
+			report(renderer, err)
+			if !errors.Is(err, lexer.TokenUnknownErr) {
+				break
+			}
+			code = exitError
+			continue
+		}
+
+		token := t.(*lexer.Token)
+		if token.T == lexer.EoiToken {
+			break
+		}
+		fmt.Printf("%s:%d:%d\t%s\t%q\n", file, token.StartLine, token.StartColumn, token.T, token.Lexeme)
+	}
+
+	if diags := l.Diagnostics(); len(diags) > 0 {
+		report(renderer, diags)
+		if diags.HasErrors() {
+			code = exitError
+		}
+	}
+
+	return code
+}
+
+func astCommand(cmd *command, args []string) int {
+	fs, logLevel := cmd.flagSet()
+	entry, code, ok := cmd.parse(fs, logLevel, args)
+	if !ok {
+		return code
+	}
+
+	tu, _, renderer, err := load(entry)
+	if err != nil {
+		report(renderer, err)
+		return exitError
+	}
+
+	b, err := json.MarshalIndent(tu, "", "  ")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "mizar:", err)
+		return exitError
+	}
+	fmt.Println(string(b))
+
+	return exitOK
+}
+
+// 与gofmt相同: 默认将格式化后的源码输出到标准输出; -l 和 -d 用于检查, 有文件需要格式化时退出码为1
+func fmtCommand(cmd *command, args []string) int {
+	fs, logLevel := cmd.flagSet()
+	write := fs.Bool("w", false, "将结果写回源文件")
+	list := fs.Bool("l", false, "列出格式与结果不同的文件")
+	diff := fs.Bool("d", false, "输出与结果的差异")
+	path, code, ok := cmd.parse(fs, logLevel, args)
+	if !ok {
+		return code
+	}
+
+	files, err := sourceFiles(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "mizar:", err)
+		return exitError
+	}
+
+	code = exitOK
+	for _, file := range files {
+		src, err := ioutil.ReadFile(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "mizar:", err)
+			code = exitError
+			continue
+		}
+		res, err := format.Source(file, src)
+		if err != nil {
+			renderer := diag.NewRenderer()
+			renderer.AddSource(file, string(src))
+			report(renderer, err)
+			code = exitError
+			continue
+		}
+
+		changed := string(res) != string(src)
+		if changed && (*list || *diff) && !*write {
+			code = exitError
+		}
+		if changed && *list {
+			fmt.Println(file)
+		}
+		if changed && *diff {
+			os.Stdout.Write(format.Diff(file+".orig", file, src, res))
+		}
+		if changed && *write {
+			if exitCode := writeFile(file, string(res)); exitCode != exitOK {
+				code = exitCode
+			}
+		}
+		if !*list && !*diff && !*write {
+			os.Stdout.Write(res)
+		}
+	}
+
+	return code
+}
+
+// path为文件时只有该文件, 为目录时是其中(包括子目录中)的全部源文件
+func sourceFiles(path string) (files []string, err error) {
+	err = filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && (file == path || filepath.Ext(file) == loader.SourceExt) {
+			files = append(files, file)
+		}
+		return nil
+	})
+
+	return
+}
+
+// 编译entry并按output的扩展名输出汇编、目标文件或可执行文件, 后两者需要 as 和 ld
+func build(entry string, output string) int {
+	tu, renderer := compile(entry)
+	if tu == nil {
+		return exitError
+	}
+
+	code, err := asm.Generate(tu)
+	if err != nil {
+		report(renderer, err)
+		return exitError
+	}
+
+	if filepath.Ext(output) == ".s" {
+		return writeFile(output, code)
+	}
+
+	dir, err := ioutil.TempDir("", "mizar")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "mizar:", err)
+		return exitError
+	}
+	defer os.RemoveAll(dir)
+
+	asmFile := filepath.Join(dir, "main.s")
+	if exitCode := writeFile(asmFile, code); exitCode != exitOK {
+		return exitCode
+	}
+
+	if filepath.Ext(output) == ".o" {
+		return execTool("as", "-o", output, asmFile)
+	}
+
+	objFile := filepath.Join(dir, "main.o")
+	if exitCode := execTool("as", "-o", objFile, asmFile); exitCode != exitOK {
+		return exitCode
+	}
+
+	return execTool("ld", "-o", output, objFile)
+}
+
+func writeFile(file string, content string) int {
+	if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+		fmt.Fprintln(os.Stderr, "mizar:", err)
+		return exitError
+	}
+
+	return exitOK
+}
+
+// 执行汇编器、链接器等外部工具, 失败时输出其错误信息
+func execTool(name string, args ...string) int {
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "mizar: %s: %v\n%s", name, err, out)
+		return exitError
+	}
+
+	return exitOK
+}
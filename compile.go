@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"mizar/ast"
+	"mizar/check"
+	"mizar/diag"
+	"mizar/loader"
+	"os"
+)
+
+// 加载入口及其导入的包, 诊断输出到标准错误
+func load(entry string) (*ast.TranslationUnit, *loader.Loader, *diag.Renderer, error) {
+	l := loader.NewLoader("")
+	tu, err := l.Load(entry)
+
+	renderer := diag.NewRenderer()
+	for file, source := range l.Sources() {
+		renderer.AddSource(file, source)
+	}
+
+	return tu, l, renderer, err
+}
+
+// 加载并检查, 有错误时输出全部诊断并返回nil; 只有警告时输出警告后继续
+func compile(entry string) (*ast.TranslationUnit, *diag.Renderer) {
+	tu, l, renderer, err := load(entry)
+	if err != nil {
+		report(renderer, err)
+		return nil, renderer
+	}
+
+	diags := l.Diagnostics()
+	diags.Append(check.CheckHierarchy(tu))
+	diags.Append(check.Resolve(tu))
+	diags.Append(check.TypeCheck(tu))
+	if len(diags) > 0 {
+		report(renderer, diags)
+	}
+	if diags.HasErrors() {
+		return nil, renderer
+	}
+
+	return tu, renderer
+}
+
+// 将错误输出到标准错误, 诊断信息按位置排序并带上源码
+func report(renderer *diag.Renderer, err error) {
+	var (
+		diags diag.List
+		d     *diag.Diagnostic
+	)
+	switch {
+	case errors.As(err, &diags):
+		diags.Sort()
+		renderer.RenderAll(os.Stderr, diags)
+	case errors.As(err, &d):
+		renderer.Render(os.Stderr, d)
+	default:
+		fmt.Fprintln(os.Stderr, "mizar:", err)
+	}
+}
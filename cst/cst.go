@@ -0,0 +1,125 @@
+package cst
+
+import (
+	"io"
+	"mizar/lexer"
+	"strings"
+)
+
+// 具体语法树: 保留源码中的每一个字符。
+// 空白和注释作为trivia附加在token上, 按顺序输出所有token及其trivia即得到与源码逐字节相同的文本
+type Element interface {
+	// 输出该元素覆盖的全部源码, 包括其中所有token的trivia
+	WriteTo(w io.Writer) (n int64, err error)
+	element()
+}
+
+type TriviaKind int8
+
+const (
+	TriviaWhitespace   TriviaKind = iota + 1 // 空格、制表符和单独的 \r, 以及文件开头的BOM
+	TriviaNewline                            // \n 或 \r\n
+	TriviaLineComment                        // 行注释, 不含行尾的换行符
+	TriviaBlockComment                       // 块注释, 包括没有结束的块注释
+	TriviaSkipped                            // 词法分析不识别而跳过的字符, 包括非法的UTF-8字节
+)
+
+type Trivia struct {
+	Kind TriviaKind
+	Text string
+}
+
+type Token struct {
+	*lexer.Token
+	Text     string // 源码中的原文; Lexeme是处理后的值, 如字符串字面量去掉了引号和转义
+	Leading  []Trivia
+	Trailing []Trivia // 同一行中token之后的trivia, 到换行符为止(包括换行符)
+}
+
+func (t *Token) element() {}
+
+func (t *Token) WriteTo(w io.Writer) (n int64, err error) {
+	var b strings.Builder
+	for _, trivia := range t.Leading {
+		b.WriteString(trivia.Text)
+	}
+	b.WriteString(t.Text)
+	for _, trivia := range t.Trailing {
+		b.WriteString(trivia.Text)
+	}
+
+	m, err := io.WriteString(w, b.String())
+	return int64(m), err
+}
+
+// 非终结符节点。Kind是产生式左部的符号名, 如 class_declaration;
+// 另外文件为File, 包声明为Package, 导入声明为Import, 有语法错误而没有分析的token为Error
+type Node struct {
+	Kind     string
+	Children []Element
+	AST      interface{} // 对应的ast节点, 如 *ast.Class、*ast.Expression; 没有对应的节点时为nil
+}
+
+const (
+	KindFile    = "File"
+	KindPackage = "Package"
+	KindImport  = "Import"
+	KindError   = "Error"
+)
+
+func (node *Node) element() {}
+
+func (node *Node) WriteTo(w io.Writer) (n int64, err error) {
+	for _, child := range node.Children {
+		m, err := child.WriteTo(w)
+		n += m
+		if err != nil {
+			return n, err
+		}
+	}
+
+	return
+}
+
+// 节点覆盖的源码
+func (node *Node) String() string {
+	var b strings.Builder
+	node.WriteTo(&b)
+	return b.String()
+}
+
+// 节点中的全部token, 按源码中的顺序
+func (node *Node) Tokens() (tokens []*Token) {
+	Walk(node, func(e Element) bool {
+		if t, ok := e.(*Token); ok {
+			tokens = append(tokens, t)
+		}
+		return true
+	})
+
+	return
+}
+
+// 对应x的第一个节点(先序), 用于从ast节点找回其源码; 没有时返回nil
+func (node *Node) Find(x interface{}) (found *Node) {
+	Walk(node, func(e Element) bool {
+		if n, ok := e.(*Node); ok && found == nil && n.AST == x {
+			found = n
+		}
+		return found == nil
+	})
+
+	return
+}
+
+// 先序遍历, f返回false时不再访问该元素的子节点
+func Walk(e Element, f func(e Element) bool) {
+	if !f(e) {
+		return
+	}
+	if node, ok := e.(*Node); ok {
+		for _, child := range node.Children {
+			Walk(child, f)
+		}
+	}
+}
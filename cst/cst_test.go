@@ -0,0 +1,48 @@
+package cst
+
+import (
+	"fmt"
+	"mizar/lexer"
+	"mizar/log"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestNewTokens(t *testing.T) {
+	log.Init(logrus.ErrorLevel)
+	source := "a /* 1 */ // 2\r\n\t/* 3\n */ b\n\n// 4\n"
+	l := lexer.NewLexer(source)
+	var tokens []*lexer.Token
+	for {
+		next, err := l.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens = append(tokens, next.(*lexer.Token))
+		if next.(*lexer.Token).T == lexer.EoiToken {
+			break
+		}
+	}
+
+	result := NewTokens(source, tokens)
+	var b strings.Builder
+	for _, token := range result {
+		token.WriteTo(&b)
+	}
+	if b.String() != source {
+		t.Errorf("%q", b.String())
+	}
+
+	expects := []string{
+		"[] a [{1  } {4 /* 1 */} {1  } {3 // 2} {2 \r\n}]",
+		"[{1 \t} {4 /* 3\n */} {1  }] b [{2 \n}]",
+		"[{2 \n} {3 // 4} {2 \n}]  []",
+	}
+	for i, token := range result {
+		if s := fmt.Sprint(token.Leading, " ", token.Text, " ", token.Trailing); s != expects[i] {
+			t.Errorf("%q", s)
+		}
+	}
+}
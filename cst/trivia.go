@@ -0,0 +1,86 @@
+package cst
+
+import (
+	"mizar/lexer"
+	"strings"
+	"unicode/utf8"
+)
+
+// 将source中token之间的文本切分为trivia附加到token上。
+// tokens按顺序排列, 最后一个是输入结束token, 文件末尾的trivia作为其Leading;
+// token间的文本先作为前一个token的Trailing, 到第一个换行符为止, 其余作为后一个token的Leading
+func NewTokens(source string, tokens []*lexer.Token) []*Token {
+	result := make([]*Token, len(tokens))
+	end := 0
+	for i, t := range tokens {
+		result[i] = &Token{Token: t, Text: source[t.StartOffset:t.EndOffset]}
+		trivia := split(source[end:t.StartOffset])
+		end = t.EndOffset
+		if i > 0 {
+			n := trailing(trivia)
+			result[i-1].Trailing, trivia = trivia[:n], trivia[n:]
+		}
+		result[i].Leading = trivia
+	}
+
+	return result
+}
+
+// 属于前一个token的trivia数量: 到第一个换行符为止; 跨行的块注释属于后一个token
+func trailing(trivia []Trivia) int {
+	for i, t := range trivia {
+		if t.Kind == TriviaNewline {
+			return i + 1
+		}
+		if t.Kind == TriviaBlockComment && strings.Contains(t.Text, "\n") {
+			return i
+		}
+	}
+
+	return len(trivia)
+}
+
+func split(text string) (trivia []Trivia) {
+	for text != "" {
+		n, kind := scan(text)
+		// 相邻的空白和跳过的字符合并为一个
+		if last := len(trivia) - 1; last >= 0 && trivia[last].Kind == kind && (kind == TriviaWhitespace || kind == TriviaSkipped) {
+			trivia[last].Text += text[:n]
+		} else {
+			trivia = append(trivia, Trivia{Kind: kind, Text: text[:n]})
+		}
+		text = text[n:]
+	}
+
+	return
+}
+
+// text开头一个trivia的长度和种类
+func scan(text string) (int, TriviaKind) {
+	switch {
+	case strings.HasPrefix(text, "\r\n"):
+		return 2, TriviaNewline
+	case text[0] == '\n':
+		return 1, TriviaNewline
+	case text[0] == ' ' || text[0] == '\t' || text[0] == '\r':
+		return 1, TriviaWhitespace
+	case strings.HasPrefix(text, "\uFEFF"):
+		return len("\uFEFF"), TriviaWhitespace
+	case strings.HasPrefix(text, "//"):
+		if i := strings.IndexByte(text, '\n'); i >= 0 {
+			if i > 0 && text[i-1] == '\r' {
+				i--
+			}
+			return i, TriviaLineComment
+		}
+		return len(text), TriviaLineComment
+	case strings.HasPrefix(text, "/*"):
+		if i := strings.Index(text[2:], "*/"); i >= 0 {
+			return i + 4, TriviaBlockComment
+		}
+		return len(text), TriviaBlockComment
+	}
+
+	_, size := utf8.DecodeRuneInString(text)
+	return size, TriviaSkipped
+}
@@ -0,0 +1,83 @@
+package diag
+
+import (
+	"fmt"
+	"mizar/ast"
+)
+
+type Severity int8
+
+const (
+	SeverityError Severity = iota + 1
+	SeverityWarning
+	SeverityNote
+)
+
+func (severity Severity) String() string {
+	switch severity {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	case SeverityNote:
+		return "note"
+	}
+
+	return "unknown"
+}
+
+// 错误码, 按阶段分段:
+// E00xx 词法分析, E01xx 语法分析, E02xx 引用消解和继承检查, E03xx 类型检查, E04xx 代码生成,
+// E05xx 加载包, E06xx 解释执行
+type Code string
+
+// 附加在诊断上的说明, 例如重复定义时指向上一次定义的位置
+type Note struct {
+	Span    ast.Span
+	Message string
+}
+
+// 一条诊断信息, 同时实现了error接口, 可以通过errors.Is判断其对应的哨兵错误
+type Diagnostic struct {
+	Severity Severity
+	Code     Code
+	Span     ast.Span
+	Message  string
+	Notes    []*Note
+	Err      error
+}
+
+func New(severity Severity, code Code, span ast.Span, err error, message string) *Diagnostic {
+	return &Diagnostic{Severity: severity, Code: code, Span: span, Message: message, Err: err}
+}
+
+// 错误信息为 err 加上格式化后的详情
+func Errorf(code Code, span ast.Span, err error, format string, args ...interface{}) *Diagnostic {
+	return New(SeverityError, code, span, err, message(err, format, args...))
+}
+
+func Warningf(code Code, span ast.Span, err error, format string, args ...interface{}) *Diagnostic {
+	return New(SeverityWarning, code, span, err, message(err, format, args...))
+}
+
+func message(err error, format string, args ...interface{}) string {
+	detail := fmt.Sprintf(format, args...)
+	if detail == "" {
+		return err.Error()
+	}
+
+	return err.Error() + " " + detail
+}
+
+func (d *Diagnostic) WithNote(span ast.Span, format string, args ...interface{}) *Diagnostic {
+	d.Notes = append(d.Notes, &Note{Span: span, Message: fmt.Sprintf(format, args...)})
+	return d
+}
+
+func (d *Diagnostic) Error() string {
+	return fmt.Sprintf("%s: %s", d.Span.Start, d.Message)
+}
+
+func (d *Diagnostic) Unwrap() error {
+	return d.Err
+}
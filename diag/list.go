@@ -0,0 +1,59 @@
+package diag
+
+import (
+	"sort"
+	"strings"
+)
+
+// 一次编译过程中收集到的所有诊断
+type List []*Diagnostic
+
+func (l *List) Add(d *Diagnostic) {
+	*l = append(*l, d)
+}
+
+func (l *List) Append(other List) {
+	*l = append(*l, other...)
+}
+
+func (l List) HasErrors() bool {
+	for _, d := range l {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+
+	return false
+}
+
+// 按文件名、行、列排序, 位置相同时保持原有顺序
+func (l List) Sort() {
+	sort.SliceStable(l, func(i, j int) bool {
+		a, b := l[i].Span.Start, l[j].Span.Start
+		if a.FileName != b.FileName {
+			return a.FileName < b.FileName
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+}
+
+// 有错误时返回l本身, 只有警告或为空时返回nil
+func (l List) Err() error {
+	if l.HasErrors() {
+		return l
+	}
+
+	return nil
+}
+
+func (l List) Error() string {
+	msgs := make([]string, 0, len(l))
+	for _, d := range l {
+		msgs = append(msgs, d.Error())
+	}
+
+	return strings.Join(msgs, "\n")
+}
@@ -0,0 +1,84 @@
+package diag
+
+import (
+	"fmt"
+	"io"
+	"mizar/ast"
+	"strconv"
+	"strings"
+)
+
+// 将诊断渲染为带有源码行和插入符的文本, 例如:
+//
+//	demo/base.mi:7:9: error[E0201]: 未定义的变量 c1
+//	    7 |         c1.f();
+//	      |         ^^
+type Renderer struct {
+	sources map[string][]string // 文件名 -> 源码各行
+}
+
+func NewRenderer() *Renderer {
+	return &Renderer{sources: make(map[string][]string)}
+}
+
+func (r *Renderer) AddSource(fileName string, source string) {
+	// 与lexer一致, 开头的BOM不占列
+	source = strings.TrimPrefix(source, "\uFEFF")
+	r.sources[fileName] = strings.Split(strings.ReplaceAll(source, "\r\n", "\n"), "\n")
+}
+
+func (r *Renderer) Render(w io.Writer, d *Diagnostic) {
+	if d.Code == "" {
+		fmt.Fprintf(w, "%s: %s: %s\n", d.Span.Start, d.Severity, d.Message)
+	} else {
+		fmt.Fprintf(w, "%s: %s[%s]: %s\n", d.Span.Start, d.Severity, d.Code, d.Message)
+	}
+	r.snippet(w, d.Span)
+
+	for _, note := range d.Notes {
+		fmt.Fprintf(w, "%s: %s: %s\n", note.Span.Start, SeverityNote, note.Message)
+		r.snippet(w, note.Span)
+	}
+}
+
+func (r *Renderer) RenderAll(w io.Writer, l List) {
+	for _, d := range l {
+		r.Render(w, d)
+	}
+}
+
+// 输出span起始行, 并在其下方用^标出span在该行中的部分
+func (r *Renderer) snippet(w io.Writer, span ast.Span) {
+	lines, exists := r.sources[span.Start.FileName]
+	if !exists || span.Start.Line < 1 || span.Start.Line > len(lines) {
+		return
+	}
+
+	line := []rune(lines[span.Start.Line-1])
+	start := span.Start.Column - 1
+	if start < 0 || start > len(line) {
+		return
+	}
+	end := len(line)
+	if span.End.Line == span.Start.Line && span.End.Column-1 <= end {
+		end = span.End.Column - 1
+	}
+	if end <= start {
+		end = start + 1
+	}
+
+	// 插入符之前保留原有的制表符, 使其与源码对齐
+	indent := make([]rune, start)
+	for i := range indent {
+		if line[i] == '\t' {
+			indent[i] = '\t'
+		} else {
+			indent[i] = ' '
+		}
+	}
+
+	lineNum := strconv.Itoa(span.Start.Line)
+	gutter := strings.Repeat(" ", len(lineNum))
+	fmt.Fprintf(w, " %s | %s\n", lineNum, string(line))
+	fmt.Fprintf(w, " %s | %s%s\n", gutter, string(indent), strings.Repeat("^", end-start))
+}
@@ -0,0 +1,52 @@
+package diag
+
+import (
+	"errors"
+	"mizar/ast"
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	r := NewRenderer()
+	r.AddSource("a.mi", "class A {\n\tpublic Int a;\n\tpublic Int a;\n}")
+
+	err := errors.New("重复定义的属性")
+	d := Errorf("E0001", ast.Pos{FileName: "a.mi", Line: 3, Column: 13}.Extend("a"), err, "%s", "a").
+		WithNote(ast.Pos{FileName: "a.mi", Line: 2, Column: 2}.Extend("public Int a;"), "上一次定义")
+
+	var b strings.Builder
+	r.Render(&b, d)
+	expect := "a.mi:3:13: error[E0001]: 重复定义的属性 a\n" +
+		" 3 | \tpublic Int a;\n" +
+		"   | \t           ^\n" +
+		"a.mi:2:2: note: 上一次定义\n" +
+		" 2 | \tpublic Int a;\n" +
+		"   | \t^^^^^^^^^^^^^\n"
+	if b.String() != expect {
+		t.Errorf("got:\n%s", b.String())
+	}
+
+	if !errors.Is(d, err) || d.Error() != "a.mi:3:13: 重复定义的属性 a" {
+		t.Error(d)
+	}
+}
+
+func TestList(t *testing.T) {
+	var l List
+	if l.Err() != nil {
+		t.Error(l)
+	}
+
+	err := errors.New("err")
+	l.Add(Warningf("W0001", ast.Span{Start: ast.Pos{Line: 2, Column: 1}}, err, ""))
+	if l.Err() != nil {
+		t.Error("warnings only")
+	}
+
+	l.Add(Errorf("E0001", ast.Span{Start: ast.Pos{Line: 1, Column: 5}}, err, ""))
+	l.Sort()
+	if l.Err() == nil || l[0].Severity != SeverityError || l.Error() != "1:5: err\n2:1: err" {
+		t.Error(l)
+	}
+}
@@ -0,0 +1,169 @@
+package format
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 差异中每个变化前后保留的上下文行数
+const diffContext = 3
+
+type edit struct {
+	op   byte // ' ' 相同, '-' 删除, '+' 插入
+	text string
+}
+
+// 逐行比较a和b, 以 diff -u 的统一格式输出差异; 没有差异时返回nil
+func Diff(oldName string, newName string, a []byte, b []byte) []byte {
+	if string(a) == string(b) {
+		return nil
+	}
+
+	edits := diffLines(splitLines(string(a)), splitLines(string(b)))
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+	// oldLine和newLine为edits[i]之前在两边已经经过的行数
+	oldLine, newLine := 0, 0
+	for i := 0; i < len(edits); {
+		if edits[i].op == ' ' {
+			oldLine, newLine, i = oldLine+1, newLine+1, i+1
+			continue
+		}
+
+		// 从变化前的上下文开始; 两个变化之间相同的行不超过两倍上下文时合并为一段
+		start := i
+		for start > 0 && i-start < diffContext && edits[start-1].op == ' ' {
+			start--
+		}
+		last := i
+		for j := i + 1; j < len(edits) && j-last-1 <= 2*diffContext; j++ {
+			if edits[j].op != ' ' {
+				last = j
+			}
+		}
+		end := last + 1
+		for end < len(edits) && end-last-1 < diffContext {
+			end++
+		}
+
+		oldStart, newStart := oldLine-(i-start), newLine-(i-start)
+		oldCount, newCount := 0, 0
+		for _, e := range edits[start:end] {
+			if e.op != '+' {
+				oldCount++
+			}
+			if e.op != '-' {
+				newCount++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(oldStart, oldCount), hunkRange(newStart, newCount))
+		for _, e := range edits[start:end] {
+			out.WriteByte(e.op)
+			out.WriteString(e.text)
+			if !strings.HasSuffix(e.text, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+
+		for _, e := range edits[i:end] {
+			if e.op != '+' {
+				oldLine++
+			}
+			if e.op != '-' {
+				newLine++
+			}
+		}
+		i = end
+	}
+
+	return []byte(out.String())
+}
+
+// 行号从1开始; 范围为空时行号是其前一行
+func hunkRange(start int, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// 每行保留行尾的换行符, 最后一行可能没有
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
+
+// Myers差分算法, 得到从a到b的最短编辑序列
+func diffLines(a []string, b []string) []edit {
+	n, m := len(a), len(b)
+	max := n + m
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	// trace[d]是第d轮开始前v的状态, 用于回溯
+	var trace [][]int
+	for d := 0; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || k != d && v[offset+k-1] < v[offset+k+1] {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(a, b, trace, d, offset)
+			}
+		}
+	}
+
+	return nil
+}
+
+func backtrack(a []string, b []string, trace [][]int, depth int, offset int) []edit {
+	var reversed []edit
+	x, y := len(a), len(b)
+	for d := depth; d > 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || k != d && v[offset+k-1] < v[offset+k+1] {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x, y = x-1, y-1
+			reversed = append(reversed, edit{op: ' ', text: a[x]})
+		}
+		if prevK == k+1 {
+			reversed = append(reversed, edit{op: '+', text: b[prevY]})
+		} else {
+			reversed = append(reversed, edit{op: '-', text: a[prevX]})
+		}
+		x, y = prevX, prevY
+	}
+	for x > 0 && y > 0 {
+		x, y = x-1, y-1
+		reversed = append(reversed, edit{op: ' ', text: a[x]})
+	}
+
+	edits := make([]edit, len(reversed))
+	for i, e := range reversed {
+		edits[len(reversed)-1-i] = e
+	}
+
+	return edits
+}
@@ -0,0 +1,264 @@
+package format
+
+import (
+	"mizar/cst"
+	"mizar/lexer"
+	"mizar/parser"
+	"strings"
+	"sync"
+)
+
+// 构造语法分析表较慢, 所有文件共用一个Parser
+var shared struct {
+	sync.Mutex
+	parser *parser.Parser
+}
+
+// 格式化一个文件。有词法或语法错误时不格式化, 返回由诊断组成的错误
+func Source(fileName string, source []byte) ([]byte, error) {
+	shared.Lock()
+	if shared.parser == nil {
+		shared.parser = parser.NewParser()
+	}
+	tree, _, err := shared.parser.ParseCST(fileName, string(source))
+	shared.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	return Node(tree), nil
+}
+
+// 按统一的风格输出具体语法树:
+//   - 每层大括号缩进4个空格, 每条语句和成员各占一行, 空的大括号写作 {}
+//   - 二元运算符、赋值号两边以及逗号之后各有一个空格, 括号内侧、点和一元运算符两边没有空格
+//   - 顶层的声明之间、包和导入声明之后空一行; 其余地方保留原有的空行, 连续的空行合并为一行
+//   - 注释原样保留: 原来在行尾的仍在行尾, 独占一行的仍独占一行, 行首的块注释仍与其后的token(右大括号除外)在同一行, 例如 /* c */ Int x;
+//
+// 输出只由token和注释决定, 因此对输出再次格式化得到的结果不变
+func Node(tree *cst.Node) []byte {
+	p := &printer{unary: make(map[*cst.Token]bool)}
+	cst.Walk(tree, func(e cst.Element) bool {
+		if node, ok := e.(*cst.Node); ok && node.Kind == string(lexer.SymbolUnaryExpression) {
+			if t, ok := node.Children[0].(*cst.Token); ok {
+				p.unary[t] = true
+			}
+		}
+		return true
+	})
+
+	var prev *cst.Token
+	for _, t := range tree.Tokens() {
+		p.token(prev, t)
+		prev = t
+	}
+
+	return []byte(p.b.String())
+}
+
+type separator int8
+
+const (
+	sepNone separator = iota
+	sepSpace
+	sepNewline
+	sepBlank // 空一行
+)
+
+type printer struct {
+	b      strings.Builder
+	unary  map[*cst.Token]bool // 一元运算符
+	depth  int                 // 大括号的层数
+	parens int                 // 未闭合的小括号数, 其中的分号(for语句)不换行
+	header lexer.TokenType     // 当前的包或导入声明的关键字
+}
+
+// 输出t以及它与prev之间的注释
+func (p *printer) token(prev *cst.Token, t *cst.Token) {
+	natural := p.separator(prev, t)
+
+	// 原来位于prev之后同一行的注释, 以及t之前的注释, newlines为注释之前的换行数
+	type comment struct {
+		text     string
+		newlines int
+		line     bool
+	}
+	var comments []comment
+	newlines := 0
+	if prev != nil {
+		for _, trivia := range prev.Trailing {
+			if trivia.Kind == cst.TriviaNewline {
+				newlines++
+			} else if trivia.Kind == cst.TriviaLineComment || trivia.Kind == cst.TriviaBlockComment {
+				comments = append(comments, comment{text: trivia.Text, line: trivia.Kind == cst.TriviaLineComment})
+			}
+		}
+	}
+	for _, trivia := range t.Leading {
+		switch trivia.Kind {
+		case cst.TriviaNewline:
+			newlines++
+		case cst.TriviaLineComment, cst.TriviaBlockComment:
+			comments = append(comments, comment{text: trivia.Text, newlines: newlines, line: trivia.Kind == cst.TriviaLineComment})
+			newlines = 0
+		}
+	}
+
+	isRc := t.T == lexer.TokenRc
+	if prev != nil && prev.T == lexer.TokenLc && isRc && len(comments) > 0 {
+		// 空大括号中只有同一行的块注释时仍写在一行中, 否则分行
+		natural = sepSpace
+		for _, c := range comments {
+			if c.line || c.newlines > 0 {
+				natural = sepNewline
+			}
+		}
+	}
+	// 换行是因为注释而不是语法结构时, 后面的内容是上一行的延续, 多缩进一层
+	level := p.depth
+	if natural < sepNewline {
+		level++
+	}
+	blankAllowed := prev != nil && prev.T != lexer.TokenLc
+
+	broken := false  // 上一个输出的是行注释, 之后必须换行
+	leading := false // 上一个输出的注释位于行首
+	blank := natural == sepBlank
+	for _, c := range comments {
+		sep := sepSpace
+		switch {
+		case c.newlines == 0 && !broken:
+			sep = sepSpace
+		case blank:
+			sep, blank = sepBlank, false
+		case c.newlines >= 2 && blankAllowed:
+			sep = sepBlank
+		case c.newlines > 0 || broken:
+			sep = sepNewline
+		}
+		leading = sep >= sepNewline || p.b.Len() == 0
+		p.separate(sep, level)
+		p.b.WriteString(c.text)
+		broken = c.line
+		blankAllowed = true
+	}
+
+	sep := natural
+	if len(comments) > 0 {
+		last := comments[len(comments)-1]
+		switch {
+		case natural >= sepNewline && leading && !last.line && newlines == 0 && !isRc:
+			sep = sepSpace
+		case natural >= sepNewline && (blank || newlines >= 2 && !isRc):
+			sep = sepBlank
+		case natural >= sepNewline:
+			sep = sepNewline
+		case last.line || newlines > 0:
+			sep = sepNewline
+		default:
+			sep = sepSpace
+		}
+	} else if natural == sepNewline && newlines >= 2 && blankAllowed && !isRc {
+		sep = sepBlank
+	}
+	if isRc {
+		p.depth--
+		level--
+	}
+	if t.T == lexer.EoiToken {
+		if p.b.Len() > 0 {
+			p.b.WriteString("\n")
+		}
+		return
+	}
+	p.separate(sep, level)
+	p.b.WriteString(t.Text)
+
+	switch t.T {
+	case lexer.TokenLc:
+		p.depth++
+	case lexer.TokenLp:
+		p.parens++
+	case lexer.TokenRp:
+		p.parens--
+	case lexer.TokenPackage, lexer.TokenImport:
+		if p.depth == 0 {
+			p.header = t.T
+		}
+	}
+}
+
+// 输出分隔, 换行后按level缩进; 文件开头不输出任何分隔
+func (p *printer) separate(sep separator, level int) {
+	if p.b.Len() == 0 {
+		return
+	}
+
+	switch sep {
+	case sepSpace:
+		p.b.WriteString(" ")
+	case sepNewline, sepBlank:
+		if sep == sepBlank {
+			p.b.WriteString("\n")
+		}
+		p.b.WriteString("\n")
+		p.b.WriteString(strings.Repeat("    ", level))
+	}
+}
+
+// 不考虑注释时prev和t之间的分隔
+func (p *printer) separator(prev *cst.Token, t *cst.Token) separator {
+	// 文件的第一个token位于最外层的行首, 其前的注释与之间的空行也按行首处理; 文件开头不输出分隔
+	if prev == nil || t.T == lexer.EoiToken {
+		return sepNewline
+	}
+
+	switch prev.T {
+	case lexer.TokenLc:
+		if t.T == lexer.TokenRc {
+			return sepNone
+		}
+		return sepNewline
+	case lexer.TokenRc:
+		switch t.T {
+		case lexer.TokenElse:
+			return sepSpace
+		case lexer.TokenSemicolon, lexer.TokenRp, lexer.TokenComma:
+			return sepNone
+		}
+		if p.depth == 0 {
+			return sepBlank
+		}
+		return sepNewline
+	case lexer.TokenSemicolon:
+		if p.parens > 0 {
+			if t.T == lexer.TokenSemicolon || t.T == lexer.TokenRp {
+				return sepNone
+			}
+			return sepSpace
+		}
+		if p.depth == 0 && (t.T != lexer.TokenImport || p.header != lexer.TokenImport) {
+			return sepBlank
+		}
+		return sepNewline
+	case lexer.TokenLp, lexer.TokenDot:
+		return sepNone
+	}
+
+	switch t.T {
+	case lexer.TokenRc:
+		return sepNewline
+	case lexer.TokenSemicolon, lexer.TokenComma, lexer.TokenRp, lexer.TokenDot:
+		return sepNone
+	case lexer.TokenLp:
+		// 方法调用、方法声明和super调用的括号紧跟在名字之后
+		if prev.T == lexer.TokenIdentifier || prev.T == lexer.TokenSuper {
+			return sepNone
+		}
+	}
+	if p.unary[prev] {
+		return sepNone
+	}
+
+	return sepSpace
+}
@@ -0,0 +1,124 @@
+package format
+
+import (
+	"io/ioutil"
+	"mizar/log"
+	"mizar/prelude"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestSource(t *testing.T) {
+	log.Init(logrus.ErrorLevel)
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{
+			"package   a.b ;\nimport c.d;import e;\n// 头部注释\n/** 文档 */\nclass  A extends B implements I,J{ // 行尾\n\tpublic Int x=-1 ;   /* 块 */\n\n\n  // 独立注释\n  public Int f( Int a ,Int b ){ return -a * !(a==b); }\n  public void g(){ /* 空 */ }\n  public void h(){\n    Int c = a +   // 续行\n       b;\n    for(;c<3;c.Increment()){}\n    if(c>1){c=2;}else{ this.g(); }\n  }\n}\ninterface I{void f(Int a);}\n",
+			`package a.b;
+
+import c.d;
+import e;
+
+// 头部注释
+/** 文档 */
+class A extends B implements I, J { // 行尾
+    public Int x = -1; /* 块 */
+
+    // 独立注释
+    public Int f(Int a, Int b) {
+        return -a * !(a == b);
+    }
+    public void g() { /* 空 */ }
+    public void h() {
+        Int c = a + // 续行
+            b;
+        for (; c < 3; c.Increment()) {}
+        if (c > 1) {
+            c = 2;
+        } else {
+            this.g();
+        }
+    }
+}
+
+interface I {
+    void f(Int a);
+}
+`,
+		},
+		// 文件开头的注释
+		{"// 版权声明\nclass A {}\n", "// 版权声明\nclass A {}\n"},
+		{"/*\n * 版权声明\n */\n\npackage a;\nclass A {}", "/*\n * 版权声明\n */\n\npackage a;\n\nclass A {}\n"},
+		{"/** 文档 */ package a;", "/** 文档 */ package a;\n"},
+		{"/** 文档 */\npackage a;", "/** 文档 */\npackage a;\n"},
+		// 行首的块注释与其后的token在同一行
+		{"class A {\n  public Int a;\n\n  /* c */ public Int x;\n /* d */\n public Int y; /* e */ }", "class A {\n    public Int a;\n\n    /* c */ public Int x;\n    /* d */\n    public Int y; /* e */\n}\n"},
+		// CRLF和BOM, 文件末尾的空行和注释
+		{"\uFEFFclass A {}\r\n\r\n\r\n// 结束\r\n\r\n", "class A {}\n\n// 结束\n"},
+		{"", ""},
+	}
+	for _, test := range tests {
+		got, err := Source("a.mi", []byte(test.source))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != test.want {
+			t.Errorf("%q\n%s", test.source, got)
+		}
+	}
+
+	if _, err := Source("a.mi", []byte("class A { Int a = ; }")); err == nil {
+		t.Error("语法错误")
+	}
+}
+
+func TestIdempotent(t *testing.T) {
+	log.Init(logrus.ErrorLevel)
+	base, err := ioutil.ReadFile("../demo/base.mi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, source := range []string{string(base), prelude.Source} {
+		once, err := Source("a.mi", []byte(source))
+		if err != nil {
+			t.Fatal(err)
+		}
+		twice, err := Source("a.mi", once)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(once) != string(twice) {
+			t.Errorf("%s", Diff("once", "twice", once, twice))
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10"
+	b := "1\n2\n3\n4\nfive\n6\n7\n8\n9\n10\n"
+	want := `--- a
++++ b
+@@ -2,9 +2,9 @@
+ 2
+ 3
+ 4
+-5
++five
+ 6
+ 7
+ 8
+ 9
+-10
+\ No newline at end of file
++10
+`
+	if got := string(Diff("a", "b", []byte(a), []byte(b))); got != want {
+		t.Error(got)
+	}
+	if Diff("a", "b", []byte(a), []byte(a)) != nil {
+		t.Error("相同的文本")
+	}
+}
@@ -5,4 +5,5 @@ go 1.14
 require (
 	github.com/Orlion/merak v0.0.0-20200919063955-0ad89df87e2e
 	github.com/sirupsen/logrus v1.6.0
+	golang.org/x/text v0.13.0
 )
@@ -0,0 +1,491 @@
+package interp
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"mizar/ast"
+	"mizar/diag"
+)
+
+var (
+	ClassNotDefineErr  = errors.New("未定义的类")
+	MethodNotDefineErr = errors.New("未定义的方法")
+	UnresolvedErr      = errors.New("未解析的引用")
+	UnsupportedErr     = errors.New("不支持")
+	NullPointerErr     = errors.New("空引用")
+	DivideErr          = errors.New("整数除法错误")
+	StackOverflowErr   = errors.New("调用层数过多")
+	InternalErr        = errors.New("解释器内部错误")
+)
+
+var errCodes = map[error]diag.Code{
+	ClassNotDefineErr:  "E0601",
+	MethodNotDefineErr: "E0602",
+	UnresolvedErr:      "E0603",
+	UnsupportedErr:     "E0604",
+	NullPointerErr:     "E0605",
+	DivideErr:          "E0606",
+	StackOverflowErr:   "E0607",
+	InternalErr:        "E0608",
+}
+
+// 调用的最大深度, 超过时报告StackOverflowErr而不是耗尽Go的栈
+const maxCallDepth = 10000
+
+// 解释执行经过check的编译单元: 创建入口包中的Main对象, 调用无参构造函数和main,
+// Int main()的返回值按进程退出码截断为0-255, void main()时为0
+//
+// 语义与asm后端一致, 可以作为测试后端的参照: 实参从右到左求值并且先于接收者求值, 未初始化的变量为零值,
+// 通过类名调用方法时this为null, 标准库中的native方法见natives;
+// 后端中导致进程崩溃的操作(访问null的成员、整数除以0等)在这里返回带位置的诊断;
+// 执行中的Go运行时错误也转换为诊断InternalErr, 位置为正在执行的语句
+func Run(tu *ast.TranslationUnit, stdin io.Reader, stdout io.Writer) (exitCode int, err error) {
+	in := &Interpreter{
+		tu:     tu,
+		stdin:  bufio.NewReader(stdin),
+		stdout: bufio.NewWriter(stdout),
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			d, ok := r.(*diag.Diagnostic)
+			if !ok {
+				d = diag.Errorf(errCodes[InternalErr], in.span, InternalErr, "%v", r)
+			}
+			exitCode, err = 1, d
+		}
+		if flushErr := in.stdout.Flush(); err == nil {
+			err = flushErr
+		}
+	}()
+
+	return in.run(), nil
+}
+
+type Interpreter struct {
+	tu     *ast.TranslationUnit
+	stdin  *bufio.Reader
+	stdout *bufio.Writer
+	frame  *frame
+	depth  int
+	span   ast.Span // 正在执行的语句
+}
+
+// 当前正在执行的方法
+type frame struct {
+	this   Value
+	class  *ast.Class            // 方法所在的类
+	vars   map[interface{}]Value // 形参、局部变量声明 -> 值
+	result Value                 // return的值
+}
+
+// 语句执行后的控制流
+type flow int8
+
+const (
+	flowNormal flow = iota
+	flowBreak
+	flowContinue
+	flowReturn
+)
+
+func (in *Interpreter) run() int {
+	mainName := ast.QualifiedName(in.tu.Package, "Main")
+	mainClass, exists := in.tu.ClassMap[mainName]
+	if !exists {
+		in.errorf(in.tu.Span, ClassNotDefineErr, "%s", mainName)
+	}
+
+	mainMethod, exists := mainClass.MethodDefinitionMap["main"][""]
+	if !exists {
+		in.errorf(mainClass.Pos.Extend(mainClass.Name), MethodNotDefineErr, "main")
+	}
+
+	mainConstructor, exists := mainClass.DefaultConstructor()
+	if !exists {
+		in.errorf(mainClass.Pos.Extend(mainClass.Name), MethodNotDefineErr, "%s()", mainClass.Name)
+	}
+
+	main := in.newObject(mainClass)
+	in.construct(mainClass, mainConstructor, main, nil, mainClass.Pos.Extend(mainClass.Name))
+	result := in.call(mainClass, mainMethod, main, nil, mainMethod.Pos.Extend(mainMethod.Name))
+	if code, ok := result.(int64); ok {
+		return int(uint8(code))
+	}
+
+	return 0
+}
+
+// 分配对象, 包括祖先类在内的全部属性都是零值
+func (in *Interpreter) newObject(class *ast.Class) *Object {
+	obj := &Object{Class: class, Properties: make(map[*ast.PropertyDefinition]Value)}
+	for c := class; c != nil; c = in.parent(c) {
+		for _, pd := range c.PropertyDefinitionMap {
+			obj.Properties[pd] = zeroValue(pd.Type)
+		}
+	}
+
+	return obj
+}
+
+func (in *Interpreter) parent(class *ast.Class) *ast.Class {
+	if len(class.Extends) == 0 {
+		return nil
+	}
+
+	return in.tu.ClassMap[class.Extends[0]]
+}
+
+// 进入新的栈帧执行body, span为调用处, 用于报告调用层数过多
+func (in *Interpreter) enter(class *ast.Class, this Value, params []*ast.Parameter, args []Value, span ast.Span, body func()) Value {
+	if in.depth >= maxCallDepth {
+		in.errorf(span, StackOverflowErr, "%d", maxCallDepth)
+	}
+
+	f := &frame{this: this, class: class, vars: make(map[interface{}]Value)}
+	for i, param := range params {
+		f.vars[param] = args[i]
+	}
+
+	caller, span := in.frame, in.span
+	in.frame = f
+	in.depth++
+	body()
+	in.depth--
+	in.frame, in.span = caller, span
+
+	return f.result
+}
+
+// 构造函数依次: 调用父类的构造函数(第一条语句super(...), 或者隐式调用无参构造函数),
+// 按声明顺序执行本类的属性初始值, 执行其余语句; md为nil时是默认构造函数
+func (in *Interpreter) construct(class *ast.Class, md *ast.MethodDefinition, this *Object, args []Value, span ast.Span) {
+	var (
+		params []*ast.Parameter
+		stmts  []*ast.Statement
+	)
+	if md != nil {
+		params, stmts = md.ParameterList, md.Block.StatementList
+	}
+
+	in.enter(class, this, params, args, span, func() {
+		if len(stmts) > 0 && stmts[0].Type == ast.StatementTypeSuperCall {
+			in.superCall(stmts[0].SuperCallStatement)
+			stmts = stmts[1:]
+		} else if parent := in.parent(class); parent != nil {
+			constructor, _ := parent.DefaultConstructor()
+			in.construct(parent, constructor, this, nil, span)
+		}
+
+		for _, pd := range class.PropertyList() {
+			if pd.Expr != nil {
+				this.Properties[pd] = in.eval(pd.Expr)
+			}
+		}
+
+		for _, stmt := range stmts {
+			if in.exec(stmt) != flowNormal {
+				return
+			}
+		}
+	})
+}
+
+// 调用类中定义的方法, native方法交给natives; 执行到方法末尾而没有return时返回值为返回类型的零值
+func (in *Interpreter) call(class *ast.Class, md *ast.MethodDefinition, this Value, args []Value, span ast.Span) Value {
+	if md.IsNative {
+		name := nativeName(class, md)
+		native, exists := natives[name]
+		if !exists {
+			in.errorf(span, UnsupportedErr, "native method %s", name)
+		}
+		return native(in, this, args, span)
+	}
+
+	return in.enter(class, this, md.ParameterList, args, span, func() {
+		if in.execBlock(md.Block) != flowReturn {
+			in.frame.result = zeroValue(md.Type)
+		}
+	})
+}
+
+// 按接收者的实际类型查找签名相同的方法, 从该类开始沿extends向上
+func (in *Interpreter) lookup(class *ast.Class, name string, key string) (*ast.Class, *ast.MethodDefinition) {
+	for c := class; c != nil; c = in.parent(c) {
+		if md, exists := c.MethodDefinitionMap[name][key]; exists {
+			return c, md
+		}
+	}
+
+	return nil, nil
+}
+
+func (in *Interpreter) execBlock(block *ast.Block) flow {
+	if block == nil {
+		return flowNormal
+	}
+
+	for _, stmt := range block.StatementList {
+		if f := in.exec(stmt); f != flowNormal {
+			return f
+		}
+	}
+
+	return flowNormal
+}
+
+func (in *Interpreter) exec(stmt *ast.Statement) flow {
+	in.span = stmt.Span
+	switch stmt.Type {
+	case ast.StatementTypeExpression:
+		in.eval(stmt.ExpressionStatement.Expression)
+	case ast.StatementTypeVarDeclaration:
+		in.frame.vars[stmt.VarDeclarationStatement] = zeroValue(stmt.VarDeclarationStatement.Type)
+	case ast.StatementTypeVarAssign:
+		in.assign(stmt.VarAssignStatement)
+	case ast.StatementTypeWhile:
+		return in.loop(nil, stmt.WhileStatement.Expression, nil, stmt.WhileStatement.Block)
+	case ast.StatementTypeFor:
+		forStmt := stmt.ForStatement
+		return in.loop(forStmt.InitExpression, forStmt.CondExpression, forStmt.PostExpression, forStmt.Block)
+	case ast.StatementTypeIf:
+		if in.eval(stmt.IfStatement.CondExpression).(bool) {
+			return in.execBlock(stmt.IfStatement.IfBlock)
+		}
+		return in.execBlock(stmt.IfStatement.ElseBlock)
+	case ast.StatementTypeBreak:
+		in.evalOptional(stmt.BreakStatement.Expression)
+		return flowBreak
+	case ast.StatementTypeContinue:
+		in.evalOptional(stmt.ContinueStatement.Expression)
+		return flowContinue
+	case ast.StatementTypeReturn:
+		in.frame.result = in.evalOptional(stmt.ReturnStatement.Expression)
+		return flowReturn
+	case ast.StatementTypeSuperCall:
+		in.superCall(stmt.SuperCallStatement)
+	}
+
+	return flowNormal
+}
+
+// while和for, 条件为nil时一直循环; continue之后执行post
+func (in *Interpreter) loop(init *ast.Expression, cond *ast.Expression, post *ast.Expression, block *ast.Block) flow {
+	in.evalOptional(init)
+	for cond == nil || in.eval(cond).(bool) {
+		switch in.execBlock(block) {
+		case flowBreak:
+			return flowNormal
+		case flowReturn:
+			return flowReturn
+		}
+		in.evalOptional(post)
+	}
+
+	return flowNormal
+}
+
+func (in *Interpreter) assign(stmt *ast.VarAssignStatement) {
+	value := in.eval(stmt.Expression)
+	if stmt.Type == ast.VarAssignStatementTypeVar {
+		in.frame.vars[stmt] = value
+		return
+	}
+
+	expr := stmt.VarCallExpression
+	binding := in.binding(expr)
+	switch binding.Type {
+	case ast.VarBindingTypeParameter:
+		in.frame.vars[binding.Parameter] = value
+	case ast.VarBindingTypeLocal:
+		in.frame.vars[binding.Local()] = value
+	case ast.VarBindingTypeProperty:
+		in.owner(expr).Properties[binding.PropertyDefinition] = value
+	default:
+		in.errorf(expr.Span, UnsupportedErr, "assign to %s", expr.Var)
+	}
+}
+
+// 父类的构造函数以当前的this为接收者
+func (in *Interpreter) superCall(stmt *ast.SuperCallStatement) {
+	parent := in.parent(in.frame.class)
+	if parent == nil {
+		in.errorf(stmt.Span, UnresolvedErr, "super")
+	}
+
+	args := in.evalArgs(stmt.ArgumentList)
+	in.construct(parent, stmt.Constructor, in.frame.this.(*Object), args, stmt.Span)
+}
+
+func (in *Interpreter) evalOptional(expr *ast.Expression) Value {
+	if expr == nil {
+		return nil
+	}
+
+	return in.eval(expr)
+}
+
+func (in *Interpreter) eval(expr *ast.Expression) Value {
+	switch expr.Type {
+	case ast.ExpressionTypeString:
+		return expr.StringLiteral
+	case ast.ExpressionTypeInt:
+		return expr.IntLiteral
+	case ast.ExpressionTypeChar:
+		return expr.CharLiteral
+	case ast.ExpressionTypeDouble:
+		return expr.DoubleLiteral
+	case ast.ExpressionTypeBool:
+		return expr.BoolLiteral
+	case ast.ExpressionTypeNull:
+		return nil
+	case ast.ExpressionTypeNewObject:
+		return in.evalNewObject(expr.NewObjectExpression)
+	case ast.ExpressionTypeCall:
+		return in.evalCall(expr.CallExpression)
+	case ast.ExpressionTypeBinary:
+		return in.evalBinary(expr.BinaryExpression)
+	case ast.ExpressionTypeUnary:
+		return in.unary(expr.UnaryExpression, in.eval(expr.UnaryExpression.Operand))
+	}
+
+	return nil
+}
+
+// && 和 || 短路求值
+func (in *Interpreter) evalBinary(expr *ast.BinaryExpression) Value {
+	left := in.eval(expr.Left)
+	switch expr.Operator {
+	case ast.OperatorAnd:
+		return left.(bool) && in.eval(expr.Right).(bool)
+	case ast.OperatorOr:
+		return left.(bool) || in.eval(expr.Right).(bool)
+	}
+
+	return in.binary(expr, left, in.eval(expr.Right))
+}
+
+// 基本类型的值不是对象: new Int(1) 就是1, new Int() 是零值
+func (in *Interpreter) evalNewObject(expr *ast.NewObjectExpression) Value {
+	if expr.Class == nil {
+		in.errorf(expr.Pos.Extend(expr.Name), UnresolvedErr, "%s", expr.Name)
+	}
+
+	args := in.evalArgs(expr.ArgumentList)
+	if ast.IsPrimitiveType(expr.Class.Name) {
+		switch {
+		case len(args) > 0:
+			return args[0]
+		case expr.Class.Name == "String":
+			return ""
+		}
+		return zeroValue(expr.Class.Name)
+	}
+
+	obj := in.newObject(expr.Class)
+	in.construct(expr.Class, expr.Constructor, obj, args, expr.Span)
+
+	return obj
+}
+
+func (in *Interpreter) evalCall(expr *ast.CallExpression) Value {
+	if expr.Type == ast.CallExpressionTypeMethodCall {
+		return in.evalMethodCall(expr.MethodCallExpression)
+	}
+
+	return in.evalVarCall(expr.VarCallExpression)
+}
+
+func (in *Interpreter) evalVarCall(expr *ast.VarCallExpression) Value {
+	binding := in.binding(expr)
+	switch binding.Type {
+	case ast.VarBindingTypeThis:
+		return in.frame.this
+	case ast.VarBindingTypeParameter:
+		return in.frame.vars[binding.Parameter]
+	case ast.VarBindingTypeLocal:
+		return in.frame.vars[binding.Local()]
+	case ast.VarBindingTypeProperty:
+		return in.owner(expr).Properties[binding.PropertyDefinition]
+	}
+
+	// 类名
+	return nil
+}
+
+func (in *Interpreter) binding(expr *ast.VarCallExpression) *ast.VarBinding {
+	if expr.Binding == nil {
+		in.errorf(expr.Span, UnresolvedErr, "%s", expr.Var)
+	}
+
+	return expr.Binding
+}
+
+// 属性所属的对象, 没有接收者时为this
+func (in *Interpreter) owner(expr *ast.VarCallExpression) *Object {
+	var owner Value
+	if expr.Type == ast.VarCallExpressionTypeCall {
+		owner = in.evalCall(expr.CallExpression)
+	} else {
+		owner = in.frame.this
+	}
+
+	obj, ok := owner.(*Object)
+	if !ok {
+		in.errorf(expr.Span, NullPointerErr, "%s", expr.Var)
+	}
+
+	return obj
+}
+
+// 通过类名调用、调用private方法以及基本类型的方法时直接调用绑定的方法, 否则按接收者的实际类型动态分派
+func (in *Interpreter) evalMethodCall(expr *ast.MethodCallExpression) Value {
+	binding := expr.Binding
+	span := expr.Pos.Extend(expr.Name)
+	if binding == nil {
+		in.errorf(span, UnresolvedErr, "%s", expr.Name)
+	}
+
+	args := in.evalArgs(expr.ArgumentList)
+	receiver := in.evalCall(expr.CallExpression)
+
+	if binding.Type == ast.MethodBindingTypeClass && (isClassReceiver(expr.CallExpression) || binding.MethodDefinition.ModifierType == ast.ModifierPrivate || ast.IsPrimitiveType(binding.Class.Name)) {
+		return in.call(binding.Class, binding.MethodDefinition, receiver, args, span)
+	}
+
+	obj, ok := receiver.(*Object)
+	if !ok {
+		in.errorf(span, NullPointerErr, "%s", expr.Name)
+	}
+	class, md := in.lookup(obj.Class, expr.Name, ast.ParameterListKey(binding.ParameterList()))
+	if md == nil {
+		in.errorf(span, MethodNotDefineErr, "%s.%s", obj.Class.Name, binding.Signature())
+	}
+
+	return in.call(class, md, obj, args, span)
+}
+
+// 接收者是类名, 例如 Out.printString("")
+func isClassReceiver(callExpr *ast.CallExpression) bool {
+	if callExpr.Type != ast.CallExpressionTypeValCall {
+		return false
+	}
+	binding := callExpr.VarCallExpression.Binding
+
+	return binding != nil && binding.Type == ast.VarBindingTypeClass
+}
+
+// 从右到左求值实参, 结果按形参顺序排列
+func (in *Interpreter) evalArgs(exprs []*ast.Expression) []Value {
+	args := make([]Value, len(exprs))
+	for i := len(exprs) - 1; i >= 0; i-- {
+		args[i] = in.eval(exprs[i])
+	}
+
+	return args
+}
+
+// 报告运行时错误并结束执行, 由Run恢复为返回的诊断
+func (in *Interpreter) errorf(span ast.Span, err error, format string, args ...interface{}) {
+	panic(diag.Errorf(errCodes[err], span, err, format, args...))
+}
@@ -0,0 +1,189 @@
+package interp
+
+import (
+	"errors"
+	"mizar/ast"
+	"mizar/check"
+	"mizar/lexer"
+	"mizar/log"
+	"mizar/parser"
+	"mizar/prelude"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 标准库附加在源码之后, 与loader加载的程序一样可以使用Out等类
+func compile(t *testing.T, source string) *ast.TranslationUnit {
+	log.Init(logrus.ErrorLevel)
+	tu, err := parser.NewParser().Parse(lexer.NewLexer(source + prelude.Source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	diags := check.CheckHierarchy(tu)
+	diags.Append(check.Resolve(tu))
+	diags.Append(check.TypeCheck(tu))
+	if len(diags) > 0 {
+		t.Fatal(diags)
+	}
+
+	return tu
+}
+
+func run(t *testing.T, source string, stdin string) (int, string, error) {
+	var out strings.Builder
+	exitCode, err := Run(compile(t, source), strings.NewReader(stdin), &out)
+
+	return exitCode, out.String(), err
+}
+
+func TestRun(t *testing.T) {
+	exitCode, out, err := run(t, `
+interface C {
+    void setA(Int a);
+}
+
+class C1 implements C {
+    public Int a;
+    public String b;
+    public Double c = 123.5;
+
+    public void C1(Int a, String b) {
+        this.a = a;
+        this.b = b;
+    }
+
+    public Int getA() {
+        return this.a;
+    }
+
+    public void setA(Int a) {
+        this.a = a;
+    }
+}
+
+class C2 extends C1 {
+    public void C2(Int a) {
+        super(a, "C2");
+    }
+
+    public Int getA() {
+        return this.a * 100;
+    }
+}
+
+class Main {
+    private Int a = 3;
+
+    public Int main() {
+        Int b = this.a;
+        while (b >= 0) {
+            C1 c = new C2(b);
+            Out.printInt(c.getA());
+            Out.printString(c.b + " ");
+            b = b - 1;
+            if (b == 1) {
+                break;
+            } else {
+                continue;
+            }
+        }
+
+        C1 o = new C1(1, "2");
+        C c = o;
+        Int i = 0;
+        for (; i < 3; Out.printString(".")) {
+            c.setA(i);
+            i = i + 1;
+            if (i == 2) {
+                continue;
+            }
+            Out.printInt(o.getA());
+        }
+        Out.println("");
+        return 256 + i;
+    }
+}
+`, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exitCode != 3 || out != "300C2 200C2 0..2.\n" {
+		t.Errorf("%d %q", exitCode, out)
+	}
+}
+
+func TestRunNative(t *testing.T) {
+	_, out, err := run(t, `
+class Main {
+    public void main() {
+        String line = In.readLine();
+        while (line != null) {
+            Out.printInt(Int.parse(line) * 2);
+            Out.printString("|" + line.substring(1, 3) + "|");
+            Out.printInt(line.length());
+            Out.println("");
+            line = In.readLine();
+        }
+        Double d = -2.9;
+        Out.printInt(d.toInt());
+    }
+}
+`, "21\r\n-7x\n\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "42|1|2\n-14|7x|3\n0||0\n-2" {
+		t.Errorf("%q", out)
+	}
+}
+
+func TestRunError(t *testing.T) {
+	tests := []struct {
+		body string
+		err  error
+		msg  string
+	}{
+		{`Main m = null; return m.a;`, NullPointerErr, "4:47: 空引用 a"},
+		{`String s; return s.length();`, NullPointerErr, "4:44: 空引用 String"},
+		{`return this.a / this.a;`, DivideErr, "4:32: 整数除法错误 除数为0"},
+		{`return this.f(1);`, StackOverflowErr, "5:39: 调用层数过多 10000"},
+	}
+	for _, test := range tests {
+		exitCode, _, err := run(t, `
+class Main {
+    public Int a;
+    public Int main() { `+test.body+` }
+    public Int f(Int n) { return this.f(n + 1); }
+}
+`, "")
+		if exitCode != 1 || !errors.Is(err, test.err) || err.Error() != test.msg {
+			t.Error(exitCode, err)
+		}
+	}
+}
+
+// 没有经过类型检查的程序可能让解释器出现Go运行时错误, 需要转换为诊断而不是崩溃
+func TestRunInternalError(t *testing.T) {
+	log.Init(logrus.ErrorLevel)
+	tu, err := parser.NewParser().Parse(lexer.NewLexer(`
+class Main {
+    public void main() {
+        if (1) {
+        }
+    }
+}
+` + prelude.Source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := check.Resolve(tu); len(errs) > 0 {
+		t.Fatal(errs)
+	}
+
+	exitCode, err := Run(tu, strings.NewReader(""), &strings.Builder{})
+	if exitCode != 1 || !errors.Is(err, InternalErr) || !strings.HasPrefix(err.Error(), "4:9: 解释器内部错误 ") {
+		t.Error(exitCode, err)
+	}
+}
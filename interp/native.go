@@ -0,0 +1,149 @@
+package interp
+
+import (
+	"io"
+	"math"
+	"mizar/ast"
+	"strconv"
+	"strings"
+)
+
+// 标准库中native方法的实现, 以 类名.方法名.形参类型... 为键, 与asm后端的符号名相同;
+// 这些方法只访问this和实参, 因此不需要栈帧
+var natives = map[string]func(in *Interpreter, this Value, args []Value, span ast.Span) Value{
+	"Int.toDouble": func(in *Interpreter, this Value, args []Value, span ast.Span) Value {
+		return float64(this.(int64))
+	},
+	"Int.toString": func(in *Interpreter, this Value, args []Value, span ast.Span) Value {
+		return strconv.FormatInt(this.(int64), 10)
+	},
+	"Int.parse.String": func(in *Interpreter, this Value, args []Value, span ast.Span) Value {
+		s, _ := args[0].(string)
+		return parseInt(s)
+	},
+	// 与cvttsd2si一致, NaN和超出范围的值为Int的最小值
+	"Double.toInt": func(in *Interpreter, this Value, args []Value, span ast.Span) Value {
+		d := this.(float64)
+		if math.IsNaN(d) || d >= math.MaxInt64 || d < math.MinInt64 {
+			return int64(math.MinInt64)
+		}
+		return int64(d)
+	},
+	"Char.toInt": func(in *Interpreter, this Value, args []Value, span ast.Span) Value {
+		return int64(this.(rune))
+	},
+	"Char.toString": func(in *Interpreter, this Value, args []Value, span ast.Span) Value {
+		return string(this.(rune))
+	},
+	"String.length": func(in *Interpreter, this Value, args []Value, span ast.Span) Value {
+		return int64(len(in.str(this, span)))
+	},
+	"String.charAt.Int": func(in *Interpreter, this Value, args []Value, span ast.Span) Value {
+		s, index := in.str(this, span), args[0].(int64)
+		if index < 0 || index >= int64(len(s)) {
+			return rune(0)
+		}
+		return rune(s[index])
+	},
+	"String.substring.Int.Int": func(in *Interpreter, this Value, args []Value, span ast.Span) Value {
+		s := in.str(this, span)
+		clamp := func(i int64, min int64) int64 {
+			if i < min {
+				return min
+			}
+			if i > int64(len(s)) {
+				return int64(len(s))
+			}
+			return i
+		}
+		begin := clamp(args[0].(int64), 0)
+		return s[begin:clamp(args[1].(int64), begin)]
+	},
+	// null不输出任何内容
+	"Out.printString.String": func(in *Interpreter, this Value, args []Value, span ast.Span) Value {
+		if s, ok := args[0].(string); ok {
+			in.stdout.WriteString(s)
+		}
+		return nil
+	},
+	"In.readLine": func(in *Interpreter, this Value, args []Value, span ast.Span) Value {
+		in.stdout.Flush()
+		return readLine(in.stdin)
+	},
+	// 解释器中的对象由Go的垃圾回收管理, 没有可以报告的状态
+	"Runtime.gc": func(in *Interpreter, this Value, args []Value, span ast.Span) Value {
+		return nil
+	},
+	"Runtime.gcCount": func(in *Interpreter, this Value, args []Value, span ast.Span) Value {
+		return int64(0)
+	},
+	"Runtime.heapSize": func(in *Interpreter, this Value, args []Value, span ast.Span) Value {
+		return int64(0)
+	},
+}
+
+// 符号名, 例如 String.substring.Int.Int
+func nativeName(class *ast.Class, md *ast.MethodDefinition) string {
+	parts := []string{class.Name, md.Name}
+	for _, param := range md.ParameterList {
+		parts = append(parts, param.Type)
+	}
+
+	return strings.Join(parts, ".")
+}
+
+// String方法的接收者不能是null
+func (in *Interpreter) str(this Value, span ast.Span) string {
+	s, ok := this.(string)
+	if !ok {
+		in.errorf(span, NullPointerErr, "String")
+	}
+
+	return s
+}
+
+// 十进制整数, 可以有前导的负号, 遇到非数字字符时停止, 溢出时回绕
+func parseInt(s string) int64 {
+	var (
+		n   int64
+		neg bool
+	)
+	if strings.HasPrefix(s, "-") {
+		neg, s = true, s[1:]
+	}
+	for i := 0; i < len(s) && s[i] >= '0' && s[i] <= '9'; i++ {
+		n = n*10 + int64(s[i]-'0')
+	}
+	if neg {
+		return -n
+	}
+
+	return n
+}
+
+// 读取一行, 一行最多4096字节, 去掉行尾的\r\n; 没有读到任何字节就到达输入末尾时返回null
+func readLine(r io.ByteReader) Value {
+	var line []byte
+	for len(line) < 4096 {
+		c, err := r.ReadByte()
+		if err != nil {
+			if line == nil {
+				return nil
+			}
+			// 最后一行没有换行符时保留\r
+			return string(line)
+		}
+		if line == nil {
+			line = []byte{}
+		}
+		if c == '\n' {
+			break
+		}
+		line = append(line, c)
+	}
+	if len(line) > 0 && line[len(line)-1] == '\r' {
+		line = line[:len(line)-1]
+	}
+
+	return string(line)
+}
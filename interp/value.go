@@ -0,0 +1,150 @@
+package interp
+
+import (
+	"math"
+	"mizar/ast"
+)
+
+// 运行时的值: Int为int64, Double为float64, Bool为bool, Char为rune, String为string, 对象为*Object, null为nil
+type Value interface{}
+
+// 对象, 属性以声明为键, 子类与父类的同名属性互不影响
+type Object struct {
+	Class      *ast.Class
+	Properties map[*ast.PropertyDefinition]Value
+}
+
+// 类型的零值, 与asm后端中清零的内存一致; 引用类型为null
+func zeroValue(typeName string) Value {
+	switch typeName {
+	case "Int":
+		return int64(0)
+	case "Double":
+		return float64(0)
+	case "Bool":
+		return false
+	case "Char":
+		return rune(0)
+	}
+
+	return nil
+}
+
+// Int操作数参与Double运算时先转换为Double
+func toDouble(v Value) float64 {
+	if i, ok := v.(int64); ok {
+		return float64(i)
+	}
+
+	return v.(float64)
+}
+
+func isDouble(v Value) bool {
+	_, ok := v.(float64)
+	return ok
+}
+
+// 除 && 和 || 以外的二元运算, 操作数已经求值
+func (in *Interpreter) binary(expr *ast.BinaryExpression, left Value, right Value) Value {
+	if expr.Operator == ast.OperatorEq || expr.Operator == ast.OperatorNe {
+		return equal(left, right) == (expr.Operator == ast.OperatorEq)
+	}
+
+	switch l := left.(type) {
+	case string:
+		// 只有String + String
+		r, ok := right.(string)
+		if !ok {
+			in.errorf(expr.Right.Span, NullPointerErr, "%s", expr.Operator)
+		}
+		return l + r
+	case rune:
+		return compare(expr.Operator, float64(l), float64(right.(rune)))
+	case nil:
+		in.errorf(expr.Left.Span, NullPointerErr, "%s", expr.Operator)
+	}
+
+	if isDouble(left) || isDouble(right) {
+		l, r := toDouble(left), toDouble(right)
+		switch expr.Operator {
+		case ast.OperatorAdd:
+			return l + r
+		case ast.OperatorSub:
+			return l - r
+		case ast.OperatorMul:
+			return l * r
+		case ast.OperatorDiv:
+			return l / r
+		}
+		return compare(expr.Operator, l, r)
+	}
+
+	l, r := left.(int64), right.(int64)
+	switch expr.Operator {
+	case ast.OperatorAdd:
+		return l + r
+	case ast.OperatorSub:
+		return l - r
+	case ast.OperatorMul:
+		return l * r
+	case ast.OperatorDiv, ast.OperatorMod:
+		// 与idivq一致, 除数为0和 最小值/-1 都是错误
+		if r == 0 {
+			in.errorf(expr.Span, DivideErr, "除数为0")
+		}
+		if l == math.MinInt64 && r == -1 {
+			in.errorf(expr.Span, DivideErr, "结果溢出")
+		}
+		if expr.Operator == ast.OperatorDiv {
+			return l / r
+		}
+		return l % r
+	case ast.OperatorLt:
+		return l < r
+	case ast.OperatorLe:
+		return l <= r
+	case ast.OperatorGt:
+		return l > r
+	case ast.OperatorGe:
+		return l >= r
+	}
+
+	return nil
+}
+
+// 大小比较, 操作数为NaN时结果为false
+func compare(operator ast.Operator, l float64, r float64) bool {
+	switch operator {
+	case ast.OperatorLt:
+		return l < r
+	case ast.OperatorLe:
+		return l <= r
+	case ast.OperatorGt:
+		return l > r
+	case ast.OperatorGe:
+		return l >= r
+	}
+
+	return false
+}
+
+// ==: 数值按值比较(Int与Double比较时先转换为Double), String按内容比较, 对象按引用比较, null只和null相等
+func equal(left Value, right Value) bool {
+	if isDouble(left) || isDouble(right) {
+		return toDouble(left) == toDouble(right)
+	}
+
+	return left == right
+}
+
+func (in *Interpreter) unary(expr *ast.UnaryExpression, operand Value) Value {
+	if expr.Operator == ast.OperatorNot {
+		return !operand.(bool)
+	}
+
+	if d, ok := operand.(float64); ok {
+		return -d
+	}
+
+	return -operand.(int64)
+}
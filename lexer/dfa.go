@@ -0,0 +1,477 @@
+package lexer
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 由词法规则生成的确定有限自动机: 先为每条规则构造NFA(Thompson构造), 合并后通过子集构造得到DFA。
+// 转移以互不相交的字符区间表示, 因此字符类不会展开成单个字符
+type dfa struct {
+	states []dfaState // 0为初始状态
+}
+
+type dfaState struct {
+	edges  []dfaEdge // 按lo排序, 区间互不相交
+	accept int       // 接受的规则在specs中的下标, -1表示不是接受状态
+}
+
+type dfaEdge struct {
+	lo, hi rune
+	to     int
+}
+
+// 从state经过r到达的状态, 没有转移时返回-1
+func (d *dfa) next(state int, r rune) int {
+	edges := d.states[state].edges
+	i := sort.Search(len(edges), func(i int) bool { return edges[i].hi >= r })
+	if i < len(edges) && edges[i].lo <= r {
+		return edges[i].to
+	}
+
+	return -1
+}
+
+type nfa struct {
+	states []nfaState
+}
+
+type nfaState struct {
+	edges  []dfaEdge // to为NFA状态
+	eps    []int
+	accept int // 规则下标, -1表示不是接受状态
+}
+
+// NFA片段, 只有一个开始状态和一个结束状态
+type fragment struct {
+	start, end int
+}
+
+func (n *nfa) newState() int {
+	n.states = append(n.states, nfaState{accept: -1})
+	return len(n.states) - 1
+}
+
+func (n *nfa) edge(from int, lo rune, hi rune, to int) {
+	n.states[from].edges = append(n.states[from].edges, dfaEdge{lo: lo, hi: hi, to: to})
+}
+
+func (n *nfa) epsilon(from int, to int) {
+	n.states[from].eps = append(n.states[from].eps, to)
+}
+
+// 按字面匹配的字符串
+func (n *nfa) literal(s string) fragment {
+	start := n.newState()
+	end := start
+	for _, r := range s {
+		next := n.newState()
+		n.edge(end, r, r, next)
+		end = next
+	}
+
+	return fragment{start: start, end: end}
+}
+
+// 构造DFA; 同一输入被多条规则接受时, 选择优先级高的规则, 优先级相同时选择先注册的
+func newDFA(specs []TokenSpec) (*dfa, error) {
+	n := &nfa{}
+	start := n.newState()
+	for i, spec := range specs {
+		var f fragment
+		if spec.Kind == SpecPattern {
+			p := &patternParser{nfa: n, pattern: []rune(spec.Pattern)}
+			var err error
+			if f, err = p.parse(); err != nil {
+				return nil, err
+			}
+		} else {
+			f = n.literal(spec.Pattern)
+		}
+		n.epsilon(start, f.start)
+		n.states[f.end].accept = i
+	}
+
+	d := &dfa{}
+	index := make(map[string]int)
+	var queue [][]int
+	add := func(set []int) int {
+		key := setKey(set)
+		if i, exists := index[key]; exists {
+			return i
+		}
+		accept := -1
+		for _, s := range set {
+			if a := n.states[s].accept; a >= 0 && (accept < 0 || prior(specs[a], a, specs[accept], accept)) {
+				accept = a
+			}
+		}
+		d.states = append(d.states, dfaState{accept: accept})
+		index[key] = len(d.states) - 1
+		queue = append(queue, set)
+		return len(d.states) - 1
+	}
+
+	add(n.closure([]int{start}))
+	for i := 0; i < len(queue); i++ {
+		set := queue[i]
+		// 字符类的各个区间通常到达同样的状态, 不必重复求闭包
+		closures := make(map[string]int)
+		for _, iv := range n.intervals(set) {
+			key := setKey(iv.targets)
+			to, exists := closures[key]
+			if !exists {
+				to = add(n.closure(iv.targets))
+				closures[key] = to
+			}
+			edges := d.states[i].edges
+			// 相邻且目标相同的区间合并为一个
+			if last := len(edges) - 1; last >= 0 && edges[last].to == to && edges[last].hi+1 == iv.lo {
+				edges[last].hi = iv.hi
+			} else {
+				d.states[i].edges = append(edges, dfaEdge{lo: iv.lo, hi: iv.hi, to: to})
+			}
+		}
+	}
+
+	return d, nil
+}
+
+// 规则a(下标i)是否比规则b(下标j)优先
+func prior(a TokenSpec, i int, b TokenSpec, j int) bool {
+	if a.Kind.priority() != b.Kind.priority() {
+		return a.Kind.priority() > b.Kind.priority()
+	}
+
+	return i < j
+}
+
+// 状态集合经过epsilon转移能到达的全部状态, 结果有序
+func (n *nfa) closure(set []int) []int {
+	seen := make(map[int]bool)
+	stack := append([]int(nil), set...)
+	for len(stack) > 0 {
+		s := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if seen[s] {
+			continue
+		}
+		seen[s] = true
+		stack = append(stack, n.states[s].eps...)
+	}
+
+	result := make([]int, 0, len(seen))
+	for s := range seen {
+		result = append(result, s)
+	}
+	sort.Ints(result)
+
+	return result
+}
+
+// 区间内的字符从状态集合出发到达的NFA状态相同
+type interval struct {
+	lo, hi  rune
+	targets []int
+}
+
+// 将集合中各状态转移的字符区间切分为互不相交的小区间, 按lo排序, 没有转移的区间不在结果中。
+// 字符类可能有上千个区间, 因此每条边只在它覆盖的小区间上登记目标, 而不是逐个小区间检查所有边
+func (n *nfa) intervals(set []int) []interval {
+	var bounds []rune
+	for _, s := range set {
+		for _, e := range n.states[s].edges {
+			bounds = append(bounds, e.lo, e.hi+1)
+		}
+	}
+	sort.Slice(bounds, func(i, j int) bool { return bounds[i] < bounds[j] })
+
+	var all []interval
+	for i := 0; i+1 < len(bounds); i++ {
+		if bounds[i] != bounds[i+1] {
+			all = append(all, interval{lo: bounds[i], hi: bounds[i+1] - 1})
+		}
+	}
+	for _, s := range set {
+		for _, e := range n.states[s].edges {
+			i := sort.Search(len(all), func(i int) bool { return all[i].lo >= e.lo })
+			for ; i < len(all) && all[i].hi <= e.hi; i++ {
+				all[i].targets = append(all[i].targets, e.to)
+			}
+		}
+	}
+
+	result := all[:0]
+	for _, iv := range all {
+		if len(iv.targets) > 0 {
+			result = append(result, iv)
+		}
+	}
+
+	return result
+}
+
+func setKey(set []int) string {
+	parts := make([]string, len(set))
+	for i, s := range set {
+		parts[i] = strconv.Itoa(s)
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// 模式的语法:
+//
+//	ab      连接
+//	a|b     或
+//	a* a+ a? 重复
+//	(a)     分组
+//	[a-z_]  字符类, [^...] 为取反
+//	\p{L}   命名的字符类, 见namedClass, 也可以出现在[...]中
+//	\c      转义, 使c按字面匹配
+type patternParser struct {
+	nfa     *nfa
+	pattern []rune
+	pos     int
+}
+
+func (p *patternParser) parse() (fragment, error) {
+	f, err := p.alternation()
+	if err == nil && p.pos < len(p.pattern) {
+		err = p.errorf("多余的 %q", p.pattern[p.pos])
+	}
+
+	return f, err
+}
+
+func (p *patternParser) alternation() (fragment, error) {
+	f, err := p.concatenation()
+	if err != nil {
+		return f, err
+	}
+
+	for p.peek('|') {
+		p.pos++
+		other, err := p.concatenation()
+		if err != nil {
+			return f, err
+		}
+		start, end := p.nfa.newState(), p.nfa.newState()
+		p.nfa.epsilon(start, f.start)
+		p.nfa.epsilon(start, other.start)
+		p.nfa.epsilon(f.end, end)
+		p.nfa.epsilon(other.end, end)
+		f = fragment{start: start, end: end}
+	}
+
+	return f, nil
+}
+
+func (p *patternParser) concatenation() (fragment, error) {
+	start := p.nfa.newState()
+	f := fragment{start: start, end: start}
+	for p.pos < len(p.pattern) && !p.peek('|') && !p.peek(')') {
+		next, err := p.repetition()
+		if err != nil {
+			return f, err
+		}
+		p.nfa.epsilon(f.end, next.start)
+		f.end = next.end
+	}
+
+	return f, nil
+}
+
+func (p *patternParser) repetition() (fragment, error) {
+	f, err := p.atom()
+	if err != nil {
+		return f, err
+	}
+
+	for p.pos < len(p.pattern) {
+		op := p.pattern[p.pos]
+		if op != '*' && op != '+' && op != '?' {
+			break
+		}
+		p.pos++
+		start, end := p.nfa.newState(), p.nfa.newState()
+		p.nfa.epsilon(start, f.start)
+		p.nfa.epsilon(f.end, end)
+		if op != '+' {
+			p.nfa.epsilon(start, end)
+		}
+		if op != '?' {
+			p.nfa.epsilon(f.end, f.start)
+		}
+		f = fragment{start: start, end: end}
+	}
+
+	return f, nil
+}
+
+func (p *patternParser) atom() (fragment, error) {
+	r := p.pattern[p.pos]
+	p.pos++
+
+	switch r {
+	case '(':
+		f, err := p.alternation()
+		if err != nil {
+			return f, err
+		}
+		if !p.peek(')') {
+			return f, p.errorf("缺少 )")
+		}
+		p.pos++
+		return f, nil
+	case '[':
+		return p.class()
+	case '*', '+', '?', ')', ']':
+		return fragment{}, p.errorf("意外的 %q", r)
+	case '\\':
+		if p.peek('p') {
+			ranges, err := p.namedClass()
+			if err != nil {
+				return fragment{}, err
+			}
+			return p.ranges(ranges), nil
+		}
+		if p.pos >= len(p.pattern) {
+			return fragment{}, p.errorf("转义不完整")
+		}
+		r = p.pattern[p.pos]
+		p.pos++
+	}
+
+	return p.nfa.literal(string(r)), nil
+}
+
+// \p{Name}, 反斜杠已经读入
+func (p *patternParser) namedClass() ([]dfaEdge, error) {
+	p.pos++
+	if !p.peek('{') {
+		return nil, p.errorf("缺少 {")
+	}
+	end := p.pos
+	for end < len(p.pattern) && p.pattern[end] != '}' {
+		end++
+	}
+	if end == len(p.pattern) {
+		return nil, p.errorf("缺少 }")
+	}
+	name := string(p.pattern[p.pos+1 : end])
+	ranges, ok := namedClass(name)
+	if !ok {
+		return nil, p.errorf("未知的字符类 %s", name)
+	}
+	p.pos = end + 1
+
+	return ranges, nil
+}
+
+// 匹配ranges中任意一个字符的片段
+func (p *patternParser) ranges(ranges []dfaEdge) fragment {
+	start, end := p.nfa.newState(), p.nfa.newState()
+	for _, iv := range ranges {
+		p.nfa.edge(start, iv.lo, iv.hi, end)
+	}
+
+	return fragment{start: start, end: end}
+}
+
+// [...] 中可以有单个字符和a-z形式的区间, 开头的^表示取反
+func (p *patternParser) class() (fragment, error) {
+	negate := p.peek('^')
+	if negate {
+		p.pos++
+	}
+
+	var ranges []dfaEdge
+	for !p.peek(']') {
+		if p.peek('\\') && p.pos+1 < len(p.pattern) && p.pattern[p.pos+1] == 'p' {
+			p.pos++
+			named, err := p.namedClass()
+			if err != nil {
+				return fragment{}, err
+			}
+			ranges = append(ranges, named...)
+			continue
+		}
+		lo, err := p.classChar()
+		if err != nil {
+			return fragment{}, err
+		}
+		hi := lo
+		if p.peek('-') && p.pos+1 < len(p.pattern) && p.pattern[p.pos+1] != ']' {
+			p.pos++
+			if hi, err = p.classChar(); err != nil {
+				return fragment{}, err
+			}
+			if hi < lo {
+				return fragment{}, p.errorf("区间 %c-%c 的顺序错误", lo, hi)
+			}
+		}
+		ranges = append(ranges, dfaEdge{lo: lo, hi: hi})
+	}
+	p.pos++
+
+	ranges = union(ranges)
+	if negate {
+		ranges = complement(ranges)
+	}
+	if len(ranges) == 0 {
+		return fragment{}, p.errorf("空的字符类")
+	}
+
+	return p.ranges(ranges), nil
+}
+
+func (p *patternParser) classChar() (rune, error) {
+	if p.pos >= len(p.pattern) {
+		return 0, p.errorf("缺少 ]")
+	}
+	r := p.pattern[p.pos]
+	p.pos++
+	if r == '\\' {
+		if p.pos >= len(p.pattern) {
+			return 0, p.errorf("转义不完整")
+		}
+		r = p.pattern[p.pos]
+		p.pos++
+	}
+
+	return r, nil
+}
+
+// 全部Unicode字符中不在ranges里的部分
+func complement(ranges []dfaEdge) []dfaEdge {
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].lo < ranges[j].lo })
+
+	var result []dfaEdge
+	next := rune(0)
+	for _, iv := range ranges {
+		if iv.lo > next {
+			result = append(result, dfaEdge{lo: next, hi: iv.lo - 1})
+		}
+		if iv.hi+1 > next {
+			next = iv.hi + 1
+		}
+	}
+	if next <= maxRune {
+		result = append(result, dfaEdge{lo: next, hi: maxRune})
+	}
+
+	return result
+}
+
+const maxRune = '\U0010FFFF'
+
+func (p *patternParser) peek(r rune) bool {
+	return p.pos < len(p.pattern) && p.pattern[p.pos] == r
+}
+
+func (p *patternParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w %s: 第%d个字符处%s", InvalidSpecErr, string(p.pattern), p.pos, fmt.Sprintf(format, args...))
+}
@@ -1,95 +1,212 @@
 package lexer
 
 import (
+	"bufio"
 	"errors"
+	"io"
+	"unicode/utf8"
 )
 
+// 从io.Reader中按需读取字符的输入流, 只在缓冲区中保留尚未读取的字符和最早的标记之后的字符。
+//
+// 位置: LineNum和ColumnNum从1开始, 列按字符计数, 制表符也是一列; Offset是从0开始的字节偏移。
+// \r\n 作为一个换行符 \n 读出, 占两个字节; 开头的UTF-8 BOM被跳过, 只计入Offset;
+// 非法的UTF-8字节读出为U+FFFD, 可以通过isInvalid与源码中真正的U+FFFD区分
 type Input struct {
-	source            []rune
-	lens              int
-	pos               int
-	lastLineColumnNum int // 上一行的列数
-	LineNum           int // 当前所在行数
-	ColumnNum         int // 当前所在列数
+	reader    *bufio.Reader
+	buf       []rune // 缓冲区中的字符, buf[0]的序号为base
+	sizes     []int  // 各字符在源码中的字节数
+	base      int
+	cur       int          // 下一个未读字符的序号
+	marks     []int        // 尚未释放的标记的序号
+	err       error        // 读取时遇到的错误, io.EOF除外
+	invalid   map[int]byte // 非法的UTF-8字节, 以字符序号为键
+	visited   int          // 序号小于visited的字符已经步进过
+	onInvalid func(b byte) // 第一次步进过非法字节时调用, 此时位置还在该字节上
+	onRune    func(r rune) // 第一次步进过其余字符时调用
+	LineNum   int          // 当前所在行数
+	ColumnNum int          // 当前所在列数
+	Offset    int          // 当前的字节偏移
 }
 
 var inputEofErr = errors.New("文件结束")
 
-func newInput(source string) *Input {
-	sourceRunes := []rune(source)
-	return &Input{sourceRunes, len(sourceRunes), -1, 1, 1, 1}
-}
+// 缓冲区中已经不需要的字符超过该数量时才移出, 避免频繁复制
+const inputDiscardSize = 4096
 
-func (input *Input) nextRune() (r rune, err error) {
-	if err = input.advance(1); err != nil {
-		return
+func newInput(r io.Reader) *Input {
+	input := &Input{reader: bufio.NewReader(r), invalid: make(map[int]byte), LineNum: 1, ColumnNum: 1}
+	if bom, size, err := input.reader.ReadRune(); err == nil {
+		if bom == '\uFEFF' {
+			input.Offset = size
+		} else {
+			input.reader.UnreadRune()
+		}
 	}
 
-	r = input.source[input.pos]
+	return input
+}
 
-	return
+// 输入中的位置, 由mark返回, 可以通过reset回到该位置
+type mark struct {
+	index     int
+	lineNum   int
+	columnNum int
+	offset    int
 }
 
-// 步进
-func (input *Input) advance(num int) (err error) {
-	if input.pos >= input.lens-num {
-		err = inputEofErr
-		return
+// 标记当前位置, 在release之前reset和since都可以使用该标记
+func (input *Input) mark() mark {
+	input.marks = append(input.marks, input.cur)
+	return mark{index: input.cur, lineNum: input.LineNum, columnNum: input.ColumnNum, offset: input.Offset}
+}
+
+// 回到标记的位置, 标记仍然有效
+func (input *Input) reset(m mark) {
+	input.cur = m.index
+	input.LineNum, input.ColumnNum, input.Offset = m.lineNum, m.columnNum, m.offset
+}
+
+func (input *Input) release(m mark) {
+	for i := len(input.marks) - 1; i >= 0; i-- {
+		if input.marks[i] == m.index {
+			input.marks = append(input.marks[:i], input.marks[i+1:]...)
+			break
+		}
 	}
+	input.discard()
+}
 
-	// 检测步进过程中是否遇到了换行符，如果遇到换行符则将当前行数+1, 并修改当前列数
-	for i := 1; i <= num; i++ {
-		if input.source[input.pos+i] == 10 {
-			input.LineNum++
-			input.lastLineColumnNum = input.ColumnNum
-			input.ColumnNum = 1
-		} else {
-			input.ColumnNum++
+// 从标记处到当前位置之间的文本
+func (input *Input) since(m mark) string {
+	return string(input.buf[m.index-input.base : input.cur-input.base])
+}
+
+// 移出缓冲区中位于当前位置和所有标记之前的字符
+func (input *Input) discard() {
+	keep := input.cur
+	for _, index := range input.marks {
+		if index < keep {
+			keep = index
 		}
 	}
 
-	input.pos = input.pos + num
+	if n := keep - input.base; n >= inputDiscardSize {
+		input.buf = append(input.buf[:0], input.buf[n:]...)
+		input.sizes = append(input.sizes[:0], input.sizes[n:]...)
+		input.base = keep
+	}
+}
 
-	return
+// 读入字符直到缓冲区中有序号为index的字符, 没有更多字符时返回false
+func (input *Input) fill(index int) bool {
+	for index-input.base >= len(input.buf) {
+		if input.err != nil {
+			return false
+		}
+
+		r, size, err := input.reader.ReadRune()
+		if err != nil {
+			input.err = err
+			return false
+		}
+		if r == utf8.RuneError && size == 1 {
+			input.reader.UnreadRune()
+			input.invalid[input.base+len(input.buf)], _ = input.reader.ReadByte()
+		}
+		if r == '\r' {
+			if next, _, err := input.reader.ReadRune(); err == nil {
+				if next == '\n' {
+					r, size = '\n', size+1
+				} else {
+					input.reader.UnreadRune()
+				}
+			}
+		}
+		input.buf = append(input.buf, r)
+		input.sizes = append(input.sizes, size)
+	}
+
+	return true
 }
 
-// 回退
-func (input *Input) back(num int) (err error) {
-	if input.pos-num < -1 {
-		err = inputEofErr
-		return
+// 读取时遇到的错误, 到达输入末尾不是错误
+func (input *Input) Err() error {
+	if input.err == io.EOF {
+		return nil
+	}
+
+	return input.err
+}
+
+func (input *Input) nextRune() (r rune, err error) {
+	if r, ok := input.peek(0); ok {
+		input.advance(1)
+		return r, nil
+	}
+
+	return 0, inputEofErr
+}
+
+// 步进num个字符, 剩余字符不足时不步进
+func (input *Input) advance(num int) (err error) {
+	if !input.fill(input.cur + num - 1) {
+		return inputEofErr
 	}
 
-	// 检测步进过程中是否遇到了换行符，如果遇到换行符则将当前行数+1, 并修改当前列数
-	for i := 1; i < num; i++ {
-		if input.pos-i != -1 && input.source[input.pos-i] == 10 {
-			input.LineNum--
-			input.ColumnNum = input.lastLineColumnNum
+	for i := 0; i < num; i++ {
+		index := input.cur - input.base
+		if input.cur >= input.visited {
+			input.visited = input.cur + 1
+			if b, ok := input.invalid[input.cur]; ok {
+				delete(input.invalid, input.cur)
+				if input.onInvalid != nil {
+					input.onInvalid(b)
+				}
+			} else if input.onRune != nil {
+				input.onRune(input.buf[index])
+			}
+		}
+		if input.buf[index] == '\n' {
+			input.LineNum++
+			input.ColumnNum = 1
 		} else {
-			input.ColumnNum--
+			input.ColumnNum++
 		}
+		input.Offset += input.sizes[index]
+		input.cur++
 	}
-
-	input.pos = input.pos - num
+	input.discard()
 
 	return
 }
 
 // 往前查询num个字符，但不步进
 func (input *Input) lookahead(num int) (runes []rune, err error) {
-	if input.pos >= input.lens-num {
+	if !input.fill(input.cur + num - 1) {
 		err = inputEofErr
 		return
 	}
 
-	for i := 0; i < num; i++ {
-		runes = append(runes, input.source[input.pos+1+i])
+	start := input.cur - input.base
+	return append([]rune(nil), input.buf[start:start+num]...), nil
+}
+
+// 下一个未读字符之后第i个字符是否为非法的UTF-8字节
+func (input *Input) isInvalid(i int) bool {
+	if !input.fill(input.cur + i) {
+		return false
 	}
+	_, ok := input.invalid[input.cur+i]
 
-	return
+	return ok
 }
 
-// 判断输入流是否已结束
-func (input *Input) isEof() bool {
-	return input.pos >= input.lens-1
+// 下一个未读字符之后第i个字符(从0开始), 不步进
+func (input *Input) peek(i int) (r rune, ok bool) {
+	if !input.fill(input.cur + i) {
+		return
+	}
+
+	return input.buf[input.cur+i-input.base], true
 }
@@ -2,71 +2,151 @@ package lexer
 
 import (
 	"errors"
-	"fmt"
+	"io"
+	"mizar/ast"
+	"mizar/diag"
 	"mizar/log"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/Orlion/merak/lexer"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/text/unicode/norm"
 )
 
 type Lexer struct {
-	input *Input
+	input    *Input
+	fileName string
+	keepDoc  bool      // 是否保留文档注释
+	doc      string    // 尚未附加到token上的文档注释
+	diags    diag.List // 词法分析过程中产生的警告
 }
 
 var TokenEofErr = errors.New("token eof")
 var TokenUnknownErr = errors.New("不识别的字符")
+var UnterminatedCommentErr = errors.New("块注释没有结束")
+var NestedCommentErr = errors.New("块注释不能嵌套")
+var UnterminatedLiteralErr = errors.New("字面量没有结束")
+var InvalidEscapeErr = errors.New("非法的转义序列")
+var InvalidCharErr = errors.New("字符字面量必须恰好包含一个字符")
+var InvalidUTF8Err = errors.New("非法的UTF-8编码")
+var BidiControlErr = errors.New("双向文本控制字符会使代码显示的顺序与实际不同")
+var ConfusableErr = errors.New("标识符中有与ASCII字符外形相同的字符")
+
+const (
+	CodeUnknownChar         diag.Code = "E0001"
+	CodeUnterminatedComment diag.Code = "E0002"
+	CodeNestedComment       diag.Code = "E0003"
+	CodeUnterminatedLiteral diag.Code = "E0004"
+	CodeInvalidEscape       diag.Code = "E0005"
+	CodeInvalidChar         diag.Code = "E0006"
+	CodeInvalidUTF8         diag.Code = "E0007"
+	CodeBidiControl         diag.Code = "E0008"
+	CodeConfusable          diag.Code = "E0009"
+)
 
 func NewLexer(source string) *Lexer {
-	input := newInput(source)
-	return &Lexer{input: input}
+	return NewReaderLexer("", strings.NewReader(source))
+}
+
+// 生成的token会带上文件名, 用于报错时定位
+func NewFileLexer(fileName string, source string) *Lexer {
+	return NewReaderLexer(fileName, strings.NewReader(source))
+}
+
+// 从r中按需读取源码, 读取出错时Next返回该错误
+func NewReaderLexer(fileName string, r io.Reader) *Lexer {
+	lexer := &Lexer{input: newInput(r), fileName: fileName}
+	// 源码中任何位置的非法字节和双向文本控制字符都要报告, 包括注释和字面量中
+	lexer.input.onInvalid = func(b byte) {
+		lexer.diags.Add(diag.Errorf(CodeInvalidUTF8, lexer.charSpan(), InvalidUTF8Err, "字节 0x%02X", b))
+	}
+	lexer.input.onRune = func(r rune) {
+		if isBidiControl(r) {
+			lexer.diags.Add(diag.Warningf(CodeBidiControl, lexer.charSpan(), BidiControlErr, "U+%04X", r))
+		}
+	}
+
+	return lexer
+}
+
+func (lexer *Lexer) FileName() string {
+	return lexer.fileName
+}
+
+// 开启后, 文档注释(/** */)会附加到其后的第一个token上, 见Token.Doc
+func (lexer *Lexer) SetKeepDocComments(keep bool) {
+	lexer.keepDoc = keep
+}
+
+// 词法分析过程中记录下来的诊断: 警告, 以及不影响继续识别token的错误(如字面量中的错误);
+// 其余错误通过Next返回
+func (lexer *Lexer) Diagnostics() diag.List {
+	return lexer.diags
+}
+
+// 下一个未读字符的位置
+func (lexer *Lexer) pos() ast.Pos {
+	return ast.Pos{FileName: lexer.fileName, Line: lexer.input.LineNum, Column: lexer.input.ColumnNum}
+}
+
+// 下一个未读字符所占的范围
+func (lexer *Lexer) charSpan() ast.Span {
+	end := lexer.pos()
+	end.Column++
+	return ast.Span{Start: lexer.pos(), End: end}
 }
 
 func (lexer *Lexer) Next() (token lexer.Token, err error) {
-	if lexer.input.isEof() {
+	// 忽略空白和注释
+	if err = lexer.skipTrivia(); err != nil {
+		return
+	}
+
+	r, ok := lexer.input.peek(0)
+	if !ok {
+		if err = lexer.input.Err(); err != nil {
+			return
+		}
+
 		t := new(Token)
 		t.T = EoiToken
 		t.StartColumn = lexer.input.ColumnNum
 		t.EndColumn = lexer.input.ColumnNum
 		t.StartLine = lexer.input.LineNum
 		t.EndLine = lexer.input.LineNum
+		t.StartOffset = lexer.input.Offset
+		t.EndOffset = lexer.input.Offset
+		t.FileName = lexer.fileName
 
 		token = t
 		return
 	}
 
-	// 首先匹配保留字
-	token, err = lexer.reservedWords()
-	if err == nil {
-		log.Trace(logrus.Fields{
-			"token": token,
-			"err":   err,
-		}, "lexer.NextToken output token")
-		return
-	}
-
-	r, err := lexer.input.nextRune()
-	if err != nil {
-		if err == inputEofErr {
-			err = TokenEofErr
-		}
-		return
-	}
-
-	if 9 == r || r == 10 || r == 13 || r == ' ' {
-		// 忽略空格换行
-		return lexer.Next()
-	}
+	start := lexer.pos()
+	startOffset := lexer.input.Offset
 
+	var t *Token
 	if '"' == r {
-		token, err = lexer.string()
-	} else if r >= '0' && r <= '9' {
-		lexer.input.back(1)
-		token, err = lexer.number()
+		t, err = lexer.string()
+	} else if '\'' == r {
+		t, err = lexer.char()
+	} else if isDigit(r) || lexer.peekFraction() {
+		t, err = lexer.number()
 	} else {
-		// 回退一个字符
-		lexer.input.back(1)
-		// 如果不是关键字则尝试识别标识符
-		token, err = lexer.identifier()
+		// 关键字、运算符、标识符等注册的规则
+		t, err = lexer.match()
+	}
+
+	if errors.Is(err, TokenUnknownErr) {
+		// 至少跳过一个字符, 使调用方可以继续识别后面的token
+		if lexer.input.Offset == startOffset {
+			lexer.input.advance(1)
+		}
+		err = diag.Errorf(CodeUnknownChar, ast.Span{Start: start, End: lexer.pos()}, TokenUnknownErr, "%q", r)
+	} else if err == nil {
+		t.StartOffset, t.EndOffset = startOffset, lexer.input.Offset
+		token = lexer.attachDoc(t)
 	}
 
 	log.Trace(logrus.Fields{
@@ -78,210 +158,389 @@ func (lexer *Lexer) Next() (token lexer.Token, err error) {
 	return
 }
 
-func (lexer *Lexer) string() (token *Token, err error) {
-	var v []rune
-	var r rune
-
-	startColumn := lexer.input.ColumnNum
-	startLine := lexer.input.LineNum
+func (lexer *Lexer) attachDoc(t *Token) *Token {
+	t.Doc, lexer.doc = lexer.doc, ""
+	return t
+}
 
+// 跳过空白、行注释(//)和块注释(/* */)
+func (lexer *Lexer) skipTrivia() error {
 	for {
-		r, err = lexer.input.nextRune()
-		rStr := string([]rune{r})
+		runes, err := lexer.input.lookahead(1)
 		if err != nil {
-			if err == inputEofErr {
-				err = nil
-				break
+			return nil
+		}
+
+		// 非法的字节已经在读过时报告, 不再作为不识别的字符
+		if lexer.input.isInvalid(0) {
+			lexer.input.advance(1)
+			continue
+		}
+
+		switch runes[0] {
+		case ' ', '\t', '\r', '\n':
+			lexer.input.advance(1)
+			continue
+		case '/':
+			if runes, err = lexer.input.lookahead(2); err != nil {
+				return nil
+			}
+			if runes[1] == '/' {
+				lexer.lineComment()
+				continue
+			}
+			if runes[1] == '*' {
+				if err = lexer.blockComment(); err != nil {
+					return err
+				}
+				continue
 			}
+		}
+
+		return nil
+	}
+}
+
+// 行注释到换行符为止, 换行符本身作为空白处理
+func (lexer *Lexer) lineComment() {
+	for {
+		runes, err := lexer.input.lookahead(1)
+		if err != nil || runes[0] == '\n' {
 			return
 		}
+		lexer.input.advance(1)
+	}
+}
 
-		if `"` == rStr {
-			break
+// 块注释不能嵌套, 注释中出现的 /* 通常意味着前面的注释少写了结尾, 因此给出警告;
+// 以 /** 开头(/**/ 除外)的是文档注释
+func (lexer *Lexer) blockComment() error {
+	start := lexer.pos()
+	m := lexer.input.mark()
+	defer lexer.input.release(m)
+	lexer.input.advance(2)
+
+	isDoc := false
+	if runes, err := lexer.input.lookahead(2); err == nil && runes[0] == '*' && runes[1] != '/' {
+		isDoc = true
+	}
+
+	for {
+		runes, err := lexer.input.lookahead(2)
+		if err != nil {
+			// 最后一个字符不可能是注释的结尾
+			for lexer.input.advance(1) == nil {
+			}
+			return diag.Errorf(CodeUnterminatedComment, ast.Span{Start: start, End: lexer.pos()}, UnterminatedCommentErr, "")
 		}
 
-		v = append(v, r)
+		switch string(runes) {
+		case "*/":
+			lexer.input.advance(2)
+			if isDoc && lexer.keepDoc {
+				lexer.doc = lexer.input.since(m)
+			}
+			return nil
+		case "/*":
+			nestedStart := lexer.pos()
+			lexer.input.advance(2)
+			lexer.diags.Add(diag.Warningf(CodeNestedComment, ast.Span{Start: nestedStart, End: lexer.pos()}, NestedCommentErr, ""))
+		default:
+			lexer.input.advance(1)
+		}
 	}
+}
 
-	if len(v) >= 1 {
-		token = new(Token)
-		token.Lexeme = string(v)
-		token.T = TokenStringLiteral
-		token.StartColumn = startColumn
-		token.StartLine = startLine
-		token.EndColumn = lexer.input.ColumnNum
-		token.EndLine = lexer.input.LineNum
-	} else {
-		err = TokenUnknownErr
+// 字符串字面量, Lexeme为转义之后的值; 字面量中的错误记录为诊断, 仍然返回token以便继续分析
+func (lexer *Lexer) string() (token *Token, err error) {
+	startColumn := lexer.input.ColumnNum
+	startLine := lexer.input.LineNum
+
+	v, _ := lexer.quoted('"')
+
+	token = new(Token)
+	token.Lexeme = string(v)
+	token.T = TokenStringLiteral
+	token.FileName = lexer.fileName
+	token.StartColumn = startColumn
+	token.StartLine = startLine
+	token.EndColumn = lexer.input.ColumnNum
+	token.EndLine = lexer.input.LineNum
+
+	return
+}
+
+// 字符字面量, 单引号中必须恰好有一个字符
+func (lexer *Lexer) char() (token *Token, err error) {
+	start := lexer.pos()
+
+	v, terminated := lexer.quoted('\'')
+	if terminated && len(v) != 1 {
+		lexer.diags.Add(diag.Errorf(CodeInvalidChar, ast.Span{Start: start, End: lexer.pos()}, InvalidCharErr, ""))
 	}
 
+	token = new(Token)
+	token.Lexeme = string(v)
+	token.T = TokenCharLiteral
+	token.FileName = lexer.fileName
+	token.StartColumn = start.Column
+	token.StartLine = start.Line
+	token.EndColumn = lexer.input.ColumnNum
+	token.EndLine = lexer.input.LineNum
+
 	return
 }
 
-func (lexer *Lexer) number() (token *Token, err error) {
-	var v []rune
-	var r rune
+// 读取以quote包围的内容并处理转义, 字面量不能跨行; 没有遇到结尾的quote时terminated为false
+func (lexer *Lexer) quoted(quote rune) (v []rune, terminated bool) {
+	start := lexer.pos()
 
-	startColumn := lexer.input.ColumnNum
-	startLine := lexer.input.LineNum
+	// 跳过开头的引号
+	lexer.input.nextRune()
 
-	// 识别状态机
-	state := 0
-State:
 	for {
-		r, err = lexer.input.nextRune()
-		if err != nil {
-			if err == inputEofErr {
-				err = nil
-				break State
-			}
+		r, ok := lexer.input.peek(0)
+		if !ok || r == '\n' {
+			lexer.diags.Add(diag.Errorf(CodeUnterminatedLiteral, ast.Span{Start: start, End: lexer.pos()}, UnterminatedLiteralErr, ""))
 			return
 		}
+		lexer.input.advance(1)
 
-		switch state {
-		case 0:
-			if '0' <= r && '9' >= r {
-				state = 1
-			} else {
-				lexer.input.back(1)
-				break State
-			}
-		case 1:
-			if '.' == r {
-				state = 2
-			} else if '0' <= r && '9' >= r {
-				state = 1
-			} else {
-				lexer.input.back(1)
-				break State
-			}
-		case 2:
-			if '0' <= r && '9' >= r {
-				state = 2
-			} else {
-				lexer.input.back(1)
-				break State
+		switch r {
+		case quote:
+			terminated = true
+			return
+		case '\\':
+			if r, ok := lexer.escape(); ok {
+				v = append(v, r)
 			}
+		default:
+			v = append(v, r)
 		}
+	}
+}
+
+var escapes = map[rune]rune{
+	'n':  '\n',
+	't':  '\t',
+	'r':  '\r',
+	'0':  0,
+	'\\': '\\',
+	'"':  '"',
+	'\'': '\'',
+}
+
+// 反斜杠之后的转义序列, 支持 \n \t \r \0 \\ \" \' 和 \u{十六进制码点}
+func (lexer *Lexer) escape() (r rune, ok bool) {
+	// 反斜杠已经读入
+	start := ast.Pos{FileName: lexer.fileName, Line: lexer.input.LineNum, Column: lexer.input.ColumnNum - 1}
+	m := lexer.input.mark()
+	defer lexer.input.release(m)
 
-		v = append(v, r)
+	c, ok := lexer.input.peek(0)
+	if !ok || c == '\n' {
+		// 交给调用方报告未结束的字面量
+		return
 	}
+	lexer.input.advance(1)
 
-	switch state {
-	case 1:
-		token = new(Token)
-		token.T = TokenIntLiteral
-		token.Lexeme = string(v)
-		token.StartColumn = startColumn
-		token.StartLine = startLine
-		token.EndColumn = lexer.input.ColumnNum
-		token.EndLine = lexer.input.LineNum
-	case 2:
-		token = new(Token)
-		token.T = TokenDoubleLiteral
-		token.Lexeme = string(v)
-		token.StartColumn = startColumn
-		token.StartLine = startLine
-		token.EndColumn = lexer.input.ColumnNum
-		token.EndLine = lexer.input.LineNum
-	default:
-		err = TokenUnknownErr
+	if r, ok = escapes[c]; ok {
+		return
 	}
 
+	if c == 'u' {
+		if r, ok = lexer.unicodeEscape(); ok {
+			return
+		}
+	}
+
+	seq := "\\" + lexer.input.since(m)
+	lexer.diags.Add(diag.Errorf(CodeInvalidEscape, ast.Span{Start: start, End: lexer.pos()}, InvalidEscapeErr, "%s", seq))
+
 	return
 }
 
-func (lexer *Lexer) reservedWords() (token *Token, err error) {
-	var runes []rune
+// \u{...}, 1到6位十六进制数字, 必须是合法的Unicode标量值
+func (lexer *Lexer) unicodeEscape() (r rune, ok bool) {
+	if runes, err := lexer.input.lookahead(1); err != nil || runes[0] != '{' {
+		return
+	}
+	lexer.input.advance(1)
 
-	startColumn := lexer.input.ColumnNum
-	startLine := lexer.input.LineNum
+	digits := 0
+	for {
+		runes, err := lexer.input.lookahead(1)
+		if err != nil || runes[0] == '\n' {
+			return
+		}
+		c := runes[0]
+		if c == '}' {
+			lexer.input.advance(1)
+			break
+		}
 
-	for _, reservedWord := range reservedWords {
-		reservedWordRunes := []rune(reservedWord)
-		reservedWordRunesLen := len(reservedWordRunes)
-		runes, err = lexer.input.lookahead(reservedWordRunesLen)
-		if err != nil {
-			if err == inputEofErr {
-				continue
-			}
+		var d rune
+		switch {
+		case '0' <= c && c <= '9':
+			d = c - '0'
+		case 'a' <= c && c <= 'f':
+			d = c - 'a' + 10
+		case 'A' <= c && c <= 'F':
+			d = c - 'A' + 10
+		default:
 			return
 		}
-		if reservedWord == string(runes) {
-			lexer.input.advance(reservedWordRunesLen)
-			tokenT, exists := reservedWords2TokenTypeMap[reservedWord]
-			if !exists {
-				panic(fmt.Sprintf("reservedWords2TokenTypeMap 中不存在 %s", reservedWord))
-			}
-			token = new(Token)
-			token.T = tokenT
-			token.Lexeme = reservedWord
-			token.StartColumn = startColumn
-			token.StartLine = startLine
-			token.EndColumn = lexer.input.ColumnNum
-			token.EndLine = lexer.input.LineNum
+		lexer.input.advance(1)
+		if digits++; digits > 6 {
 			return
 		}
+		r = r*16 + d
 	}
 
-	err = TokenUnknownErr
+	ok = digits > 0 && utf8.ValidRune(r)
 
 	return
 }
 
-func (lexer *Lexer) identifier() (token *Token, err error) {
-	var v []rune
-	var r rune
-
+// 数字字面量:
+//
+//	十进制整数  123, 1_000_000
+//	十六进制    0x1F
+//	二进制      0b1010
+//	八进制      0o17, 017
+//	浮点数      1.5, .5, 1., 1e10, 1.5e-3
+//
+// 这里只按形状区分整数和浮点数, 紧跟在后面的字母、数字和下划线都属于该字面量,
+// 数值的合法性(下划线位置、各进制的数字、溢出)在语法分析转换数值时检查, 以便整体报告为一个非法字面量
+func (lexer *Lexer) number() (token *Token, err error) {
 	startColumn := lexer.input.ColumnNum
 	startLine := lexer.input.LineNum
+	m := lexer.input.mark()
+	defer lexer.input.release(m)
 
-	r, err = lexer.input.nextRune()
-	if err != nil {
-		if err == inputEofErr {
-			err = TokenEofErr
+	t := TokenType(TokenIntLiteral)
+	if lexer.peekPrefix() {
+		lexer.input.advance(2)
+	} else {
+		lexer.skipWhile(isDecimalDigit)
+		if lexer.peekIs(func(r rune) bool { return r == '.' }) {
+			t = TokenDoubleLiteral
+			lexer.input.advance(1)
+			lexer.skipWhile(isDecimalDigit)
+		}
+		if lexer.peekIs(func(r rune) bool { return r == 'e' || r == 'E' }) {
+			t = TokenDoubleLiteral
+			lexer.input.advance(1)
+			if lexer.peekIs(func(r rune) bool { return r == '+' || r == '-' }) {
+				lexer.input.advance(1)
+			}
 		}
-		return
 	}
+	lexer.skipWhile(isIdentifierChar)
 
-	// 标识符必须以字母下划线开始
-	if !(r == '_' || (r <= 'Z' && r >= 'A') || (r <= 'z' && r >= 'a') || (r >= '0' && r <= '9')) {
-		err = errors.New("不识别的字符")
-		lexer.input.back(1)
+	if lexer.input.Offset == m.offset {
+		err = TokenUnknownErr
 		return
 	}
 
-	v = append(v, r)
+	token = new(Token)
+	token.T = t
+	token.FileName = lexer.fileName
+	token.Lexeme = lexer.input.since(m)
+	token.StartColumn = startColumn
+	token.StartLine = startLine
+	token.EndColumn = lexer.input.ColumnNum
+	token.EndLine = lexer.input.LineNum
+
+	return
+}
+
+// 下一个字符是否满足f
+func (lexer *Lexer) peekIs(f func(r rune) bool) bool {
+	runes, err := lexer.input.lookahead(1)
+	return err == nil && f(runes[0])
+}
+
+func (lexer *Lexer) skipWhile(f func(r rune) bool) {
+	for lexer.peekIs(f) {
+		lexer.input.advance(1)
+	}
+}
+
+// 是否为 0x 0b 0o 进制前缀
+func (lexer *Lexer) peekPrefix() bool {
+	runes, err := lexer.input.lookahead(2)
+	if err != nil || runes[0] != '0' {
+		return false
+	}
+
+	switch runes[1] {
+	case 'x', 'X', 'b', 'B', 'o', 'O':
+		return true
+	}
 
+	return false
+}
+
+// 是否为 .5 这样以小数点开始的浮点数, 需要先于运算符 . 识别
+func (lexer *Lexer) peekFraction() bool {
+	runes, err := lexer.input.lookahead(2)
+	return err == nil && runes[0] == '.' && '0' <= runes[1] && runes[1] <= '9'
+}
+
+func isDigit(r rune) bool {
+	return '0' <= r && r <= '9'
+}
+
+func isDecimalDigit(r rune) bool {
+	return '0' <= r && r <= '9' || r == '_'
+}
+
+// 按注册的规则进行最长匹配, 同样长的匹配按规则的优先级选择, 见Register。
+// 标识符按NFC规范化, 因此组合字符序列不同但显示相同的标识符是同一个标识符
+func (lexer *Lexer) match() (token *Token, err error) {
+	table, specs := tokenTable()
+
+	var runes []rune
+	state, length, accept := 0, 0, -1
 	for {
-		r, err = lexer.input.nextRune()
-		if err != nil {
-			if err == inputEofErr {
-				err = nil
-				break
-			}
-			return
+		r, ok := lexer.input.peek(len(runes))
+		if !ok {
+			break
 		}
-
-		if r == '_' || (r <= 'Z' && r >= 'A') || (r <= 'z' && r >= 'a') || (r >= '0' && r <= '9') {
-			v = append(v, r)
-		} else {
-			lexer.input.back(1)
+		if state = table.next(state, r); state < 0 {
 			break
 		}
+		runes = append(runes, r)
+		if a := table.states[state].accept; a >= 0 {
+			length, accept = len(runes), a
+		}
 	}
 
-	if len(v) >= 1 {
-		token = new(Token)
-		token.Lexeme = string(v)
-		token.T = TokenIdentifier
-		token.StartColumn = startColumn
-		token.StartLine = startLine
-		token.EndLine = lexer.input.LineNum
-		token.EndColumn = lexer.input.ColumnNum
-	} else {
+	if accept < 0 {
 		err = TokenUnknownErr
+		return
+	}
+
+	token = new(Token)
+	token.T = specs[accept].T
+	token.FileName = lexer.fileName
+	token.Lexeme = string(runes[:length])
+	token.StartColumn = lexer.input.ColumnNum
+	token.StartLine = lexer.input.LineNum
+	if token.T == TokenIdentifier {
+		token.Lexeme = norm.NFC.String(token.Lexeme)
+		if r, ascii, found := findConfusable(token.Lexeme); found {
+			start := lexer.pos()
+			end := start
+			end.Column += length
+			lexer.diags.Add(diag.Warningf(CodeConfusable, ast.Span{Start: start, End: end}, ConfusableErr,
+				"%s 中的 %c(U+%04X) 与 %c 相似", token.Lexeme, r, r, ascii))
+		}
 	}
+	lexer.input.advance(length)
+	token.EndColumn = lexer.input.ColumnNum
+	token.EndLine = lexer.input.LineNum
 
 	return
 }
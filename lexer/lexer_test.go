@@ -1,73 +1,51 @@
 package lexer
 
 import (
+	"errors"
+	"fmt"
+	"mizar/diag"
 	"mizar/log"
+	"strings"
 	"testing"
+	"testing/iotest"
 
 	"github.com/sirupsen/logrus"
 )
 
-// func TestNumber(t *testing.T) {
-// 	log.Init(logrus.ErrorLevel)
-// 	lexer := NewLexer("123")
-// 	if token, err := lexer.number(); err != nil {
-// 		t.Error(err)
-// 	} else {
-// 		if token.T != TokenIntLiteral {
-// 			t.Error(token)
-// 		}
-// 	}
-
-// 	lexer = NewLexer("123.0")
-// 	if token, err := lexer.number(); err != nil {
-// 		t.Error(err)
-// 	} else {
-// 		if token.T != TokenDoubleLiteral {
-// 			t.Error(token)
-// 		}
-// 	}
-
-// 	lexer = NewLexer("123.00000000001")
-// 	if token, err := lexer.number(); err != nil {
-// 		t.Error(err)
-// 	} else {
-// 		if token.T != TokenDoubleLiteral {
-// 			t.Error(token)
-// 		}
-// 	}
-
-// 	lexer = NewLexer("0")
-// 	if token, err := lexer.number(); err != nil {
-// 		t.Error(err)
-// 	} else {
-// 		if token.T != TokenIntLiteral {
-// 			t.Error(token)
-// 		}
-// 	}
-
-// 	lexer = NewLexer("0.1")
-// 	if token, err := lexer.number(); err != nil {
-// 		t.Error(err)
-// 	} else {
-// 		if token.T != TokenDoubleLiteral {
-// 			t.Error(token)
-// 		}
-// 	}
-
-// 	lexer = NewLexer("0.10")
-// 	if token, err := lexer.number(); err != nil {
-// 		t.Error(err)
-// 	} else {
-// 		if token.T != TokenDoubleLiteral {
-// 			t.Error(token)
-// 		}
-// 	}
-
-// 	lexer = NewLexer("01")
-// 	if _, err := lexer.number(); err != nil {
-// 		t.Error(err)
-// 	}
-// }
+func TestNumber(t *testing.T) {
+	log.Init(logrus.ErrorLevel)
+	cases := []struct {
+		source string
+		t      TokenType
+		lexeme string
+	}{
+		{"123", TokenIntLiteral, "123"},
+		{"0", TokenIntLiteral, "0"},
+		{"1_000_000;", TokenIntLiteral, "1_000_000"},
+		{"0x1F)", TokenIntLiteral, "0x1F"},
+		{"0b1010", TokenIntLiteral, "0b1010"},
+		{"0o17", TokenIntLiteral, "0o17"},
+		{"017", TokenIntLiteral, "017"},
+		{"123.0", TokenDoubleLiteral, "123.0"},
+		{"0.10", TokenDoubleLiteral, "0.10"},
+		{".5", TokenDoubleLiteral, ".5"},
+		{"1.", TokenDoubleLiteral, "1."},
+		{"1e10", TokenDoubleLiteral, "1e10"},
+		{"1.5E-3+1", TokenDoubleLiteral, "1.5E-3"},
+		{"0x1G", TokenIntLiteral, "0x1G"},
+		{"123abc", TokenIntLiteral, "123abc"},
+	}
+	for _, c := range cases {
+		token, err := NewLexer(c.source).Next()
+		if err != nil {
+			t.Error(c.source, err)
+			continue
+		}
+		if token := token.(*Token); token.T != c.t || token.Lexeme != c.lexeme {
+			t.Errorf("%s: %+v", c.source, token)
+		}
+	}
+}
 
 func TestNextToken(t *testing.T) {
 	log.Init(logrus.TraceLevel)
@@ -102,3 +80,342 @@ func TestNextToken(t *testing.T) {
 		}
 	}
 }
+
+func TestComment(t *testing.T) {
+	log.Init(logrus.ErrorLevel)
+	lexer := NewFileLexer("a.mi", `// line comment
+/** doc */
+// other comment
+class /* block
+/* nested */ A / 2 /**/
+/* unterminated`)
+	lexer.SetKeepDocComments(true)
+
+	expects := []struct {
+		lexeme string
+		doc    string
+	}{
+		{"class", "/** doc */"},
+		{"A", ""},
+		{"/", ""},
+		{"2", ""},
+	}
+	for _, expect := range expects {
+		token, err := lexer.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token := token.(*Token); token.Lexeme != expect.lexeme || token.Doc != expect.doc {
+			t.Error(token)
+		}
+	}
+
+	_, err := lexer.Next()
+	if !errors.Is(err, UnterminatedCommentErr) || err.Error() != "a.mi:6:1: 块注释没有结束" {
+		t.Error(err)
+	}
+	if token, err := lexer.Next(); err != nil || token.(*Token).T != EoiToken {
+		t.Error(token, err)
+	}
+
+	warnings := lexer.Diagnostics()
+	if len(warnings) != 1 || !errors.Is(warnings[0], NestedCommentErr) || warnings[0].Error() != "a.mi:5:1: 块注释不能嵌套" {
+		t.Error(warnings)
+	}
+}
+
+func TestLiteral(t *testing.T) {
+	log.Init(logrus.ErrorLevel)
+	lexer := NewFileLexer("a.mi", `"a\"b\n\t\\\u{4e2d}" "" 'c' '\'' '\u{1F600}'
+"\q\u{110000}x" '' 'ab' "abc
+'d`)
+
+	expects := []struct {
+		t      TokenType
+		lexeme string
+	}{
+		{TokenStringLiteral, "a\"b\n\t\\中"},
+		{TokenStringLiteral, ""},
+		{TokenCharLiteral, "c"},
+		{TokenCharLiteral, "'"},
+		{TokenCharLiteral, "😀"},
+		{TokenStringLiteral, "x"},
+		{TokenCharLiteral, ""},
+		{TokenCharLiteral, "ab"},
+		{TokenStringLiteral, "abc"},
+		{TokenCharLiteral, "d"},
+	}
+	for _, expect := range expects {
+		token, err := lexer.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token := token.(*Token); token.T != expect.t || token.Lexeme != expect.lexeme {
+			t.Errorf("%+v", token)
+		}
+	}
+
+	msgs := []string{
+		`a.mi:2:2: 非法的转义序列 \q`,
+		`a.mi:2:4: 非法的转义序列 \u{110000}`,
+		`a.mi:2:17: 字符字面量必须恰好包含一个字符`,
+		`a.mi:2:20: 字符字面量必须恰好包含一个字符`,
+		`a.mi:2:25: 字面量没有结束`,
+		`a.mi:3:1: 字面量没有结束`,
+	}
+	diags := lexer.Diagnostics()
+	if len(diags) != len(msgs) {
+		t.Fatal(diags)
+	}
+	for i, msg := range msgs {
+		if diags[i].Error() != msg {
+			t.Error(diags[i])
+		}
+	}
+}
+
+func TestKeywordBoundary(t *testing.T) {
+	log.Init(logrus.ErrorLevel)
+	lexer := NewLexer("superman newValue classes format new(x) for_ if1 this.a>=!b")
+
+	expects := []struct {
+		t      TokenType
+		lexeme string
+	}{
+		{TokenIdentifier, "superman"},
+		{TokenIdentifier, "newValue"},
+		{TokenIdentifier, "classes"},
+		{TokenIdentifier, "format"},
+		{TokenNew, "new"},
+		{TokenLp, "("},
+		{TokenIdentifier, "x"},
+		{TokenRp, ")"},
+		{TokenIdentifier, "for_"},
+		{TokenIdentifier, "if1"},
+		{TokenThis, "this"},
+		{TokenDot, "."},
+		{TokenIdentifier, "a"},
+		{TokenGe, ">="},
+		{TokenNot, "!"},
+		{TokenIdentifier, "b"},
+		{EoiToken, ""},
+	}
+	for _, expect := range expects {
+		token, err := lexer.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token := token.(*Token); token.T != expect.t || token.Lexeme != expect.lexeme {
+			t.Errorf("%+v", token)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	log.Init(logrus.ErrorLevel)
+	// 内置的token在声明时注册, 都是终结符
+	builtin := Specs()
+	if len(builtin) == 0 || builtin[0].T != TokenAssign || builtin[len(builtin)-1].T != TokenIdentifier {
+		t.Fatal(builtin)
+	}
+	for _, spec := range builtin {
+		if !spec.T.IsTerminals() {
+			t.Error(spec)
+		}
+	}
+
+	for _, spec := range []TokenSpec{
+		{Kind: SpecPunctuator, Pattern: "->", T: "ARROW"},
+		{Kind: SpecKeyword, Pattern: "yield", T: "YIELD"},
+		{Kind: SpecPattern, Pattern: "@[a-z]+(\\.[a-z]+)*", T: "ANNOTATION"},
+	} {
+		if err := Register(spec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lexer := NewLexer("a->yield yields @b.c -")
+	expects := []TokenType{TokenIdentifier, "ARROW", "YIELD", TokenIdentifier, "ANNOTATION", TokenSub}
+	for _, expect := range expects {
+		token, err := lexer.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token := token.(*Token); token.T != expect {
+			t.Errorf("%+v", token)
+		}
+	}
+
+	for _, spec := range []TokenSpec{
+		{Kind: SpecPunctuator, Pattern: "==", T: "EQUALS"},
+		{Kind: SpecKeyword, Pattern: "1st", T: "FIRST"},
+		{Kind: SpecPattern, Pattern: "[a-", T: "BROKEN"},
+		{Kind: SpecPattern, Pattern: "a)", T: "BROKEN"},
+		{Kind: SpecPunctuator, Pattern: "", T: "EMPTY"},
+	} {
+		if err := Register(spec); !errors.Is(err, InvalidSpecErr) {
+			t.Error(spec, err)
+		}
+	}
+}
+
+func TestDFA(t *testing.T) {
+	d, err := newDFA([]TokenSpec{
+		{Kind: SpecPattern, Pattern: "[^0-9]b*|ab?c", T: "A"},
+		{Kind: SpecPattern, Pattern: "[0-9]+", T: "B"},
+		{Kind: SpecKeyword, Pattern: "abc", T: "C"},
+		{Kind: SpecPattern, Pattern: `[\p{Nd}\p{Han}]\p{Han}+`, T: "D"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		input  string
+		accept int
+	}{
+		{"x", 0},
+		{"abbb", 0},
+		{"ac", 0},
+		{"abc", 2},
+		{"中", 0},
+		{"007", 1},
+		{"中文", 3},
+		{"1文", 3},
+		{"ab0", -1},
+		{"", -1},
+	}
+	for _, c := range cases {
+		state := 0
+		for _, r := range c.input {
+			if state = d.next(state, r); state < 0 {
+				break
+			}
+		}
+		accept := -1
+		if state >= 0 {
+			accept = d.states[state].accept
+		}
+		if accept != c.accept {
+			t.Error(c.input, accept)
+		}
+	}
+
+	if _, err := newDFA([]TokenSpec{{Kind: SpecPattern, Pattern: `\p{Foo}`, T: "A"}}); !errors.Is(err, InvalidSpecErr) {
+		t.Error(err)
+	}
+}
+
+func TestPosition(t *testing.T) {
+	log.Init(logrus.ErrorLevel)
+	lexer := NewReaderLexer("a.mi", iotest.OneByteReader(strings.NewReader("\uFEFFclass\r\n\tA {\r\n\"中\" }")))
+	want := []struct {
+		lexeme                   string
+		line, column, start, end int
+	}{
+		{"class", 1, 1, 3, 8},
+		{"A", 2, 2, 11, 12},
+		{"{", 2, 4, 13, 14},
+		{"中", 3, 1, 16, 21},
+		{"}", 3, 5, 22, 23},
+		{"", 3, 6, 23, 23},
+	}
+	for _, w := range want {
+		next, err := lexer.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		token := next.(*Token)
+		if token.Lexeme != w.lexeme || token.StartLine != w.line || token.StartColumn != w.column ||
+			token.StartOffset != w.start || token.EndOffset != w.end || token.FileName != "a.mi" {
+			t.Errorf("%+v", token)
+		}
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read(p []byte) (int, error) {
+	return 0, errors.New("读取失败")
+}
+
+func TestInput(t *testing.T) {
+	// 缓冲区只保留标记之后的字符
+	source := strings.Repeat("ab\n", 10000)
+	input := newInput(strings.NewReader(source))
+	input.advance(5)
+	m := input.mark()
+	input.advance(20000)
+	if input.LineNum != 6669 || input.ColumnNum != 2 || input.Offset != 20005 {
+		t.Error(input.LineNum, input.ColumnNum, input.Offset)
+	}
+	if s := input.since(m); s != source[5:20005] {
+		t.Error(len(s))
+	}
+	input.reset(m)
+	if input.LineNum != 2 || input.ColumnNum != 3 || input.Offset != 5 {
+		t.Error(input.LineNum, input.ColumnNum, input.Offset)
+	}
+	input.release(m)
+	for input.advance(1) == nil {
+	}
+	if len(input.buf) > 2*inputDiscardSize || input.LineNum != 10001 || input.Err() != nil {
+		t.Error(len(input.buf), input.LineNum, input.Err())
+	}
+
+	if _, err := NewReaderLexer("a.mi", failingReader{}).Next(); err == nil || err.Error() != "读取失败" {
+		t.Error(err)
+	}
+}
+
+func TestUnicode(t *testing.T) {
+	log.Init(logrus.ErrorLevel)
+	tests := []struct {
+		source  string
+		lexemes []string
+		codes   []diag.Code
+	}{
+		{"变量1 _x Привет", []string{"变量1", "_x", "Привет"}, nil},
+		// 组合字符序列规范化为NFC
+		{"cafe\u0301", []string{"caf\u00e9"}, nil},
+		{"a \xff b", []string{"a", "b"}, []diag.Code{CodeInvalidUTF8}},
+		{"\"x\xc3y\" /* \xfe */", []string{"x�y"}, []diag.Code{CodeInvalidUTF8, CodeInvalidUTF8}},
+		{"// \u202E }\nx", []string{"x"}, []diag.Code{CodeBidiControl}},
+		{"pаss рас", []string{"pаss", "рас"}, []diag.Code{CodeConfusable, CodeConfusable}},
+		{"ｐass １", []string{"ｐass"}, []diag.Code{CodeUnknownChar, CodeConfusable}},
+	}
+	for _, test := range tests {
+		lexer := NewLexer(test.source)
+		var lexemes []string
+		var codes []diag.Code
+		for {
+			next, err := lexer.Next()
+			if err != nil {
+				var d *diag.Diagnostic
+				if !errors.As(err, &d) {
+					t.Fatal(test.source, err)
+				}
+				codes = append(codes, d.Code)
+				continue
+			}
+			if token := next.(*Token); token.T != EoiToken {
+				lexemes = append(lexemes, token.Lexeme)
+				continue
+			}
+			break
+		}
+		for _, d := range lexer.Diagnostics() {
+			codes = append(codes, d.Code)
+		}
+		if fmt.Sprint(lexemes) != fmt.Sprint(test.lexemes) || fmt.Sprint(codes) != fmt.Sprint(test.codes) {
+			t.Errorf("%q: %q %v", test.source, lexemes, codes)
+		}
+	}
+
+	d := NewLexer("Int x = \xff;")
+	for token, err := d.Next(); err == nil && token.(*Token).T != EoiToken; token, err = d.Next() {
+	}
+	if diags := d.Diagnostics(); len(diags) != 1 || diags[0].Span.Start.Column != 9 || diags[0].Severity != diag.SeverityError {
+		t.Error(diags)
+	}
+}
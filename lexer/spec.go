@@ -0,0 +1,112 @@
+package lexer
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var InvalidSpecErr = errors.New("非法的词法规则")
+
+type SpecKind int8
+
+const (
+	SpecKeyword    SpecKind = iota + 1 // 关键字, 按字面匹配, 必须是标识符的形式
+	SpecPunctuator                     // 运算符和分隔符, 按字面匹配
+	SpecPattern                        // 模式, 语法见patternParser, 例如标识符 [\p{XID_Start}_]\p{XID_Continue}*
+)
+
+// 多条规则接受同一个词素时, 按字面匹配的规则优先于模式, 因此 new 是关键字, 而 newValue 是标识符
+func (kind SpecKind) priority() int {
+	if kind == SpecPattern {
+		return 0
+	}
+
+	return 1
+}
+
+// 词法规则: 匹配Pattern的词素识别为T类型的token, T同时是语法分析中终结符的名字
+type TokenSpec struct {
+	Kind    SpecKind
+	Pattern string
+	T       TokenType
+}
+
+// 已注册的规则和由其生成的DFA, 注册新规则后DFA在下一次使用时重新生成
+var registry struct {
+	sync.Mutex
+	specs []TokenSpec
+	dfa   *dfa
+}
+
+// 注册一条词法规则, 对之后识别的token生效。
+// 识别时选择最长的匹配, 因此关键字只在完整的单词上匹配; 字符串、字符和数字字面量以及注释由Lexer直接处理
+func Register(spec TokenSpec) error {
+	if err := validate(spec); err != nil {
+		return err
+	}
+
+	registry.Lock()
+	defer registry.Unlock()
+
+	for _, s := range registry.specs {
+		if s.Kind != SpecPattern && spec.Kind != SpecPattern && s.Pattern == spec.Pattern {
+			return fmt.Errorf("%w, %s 已注册为 %s", InvalidSpecErr, spec.Pattern, s.T)
+		}
+	}
+	if spec.Kind == SpecPattern {
+		// 提前检查模式的语法
+		if _, err := newDFA([]TokenSpec{spec}); err != nil {
+			return err
+		}
+	}
+
+	registry.specs = append(registry.specs, spec)
+	registry.dfa = nil
+
+	return nil
+}
+
+func validate(spec TokenSpec) error {
+	if spec.Pattern == "" || spec.T == "" {
+		return fmt.Errorf("%w, 规则和token类型不能为空", InvalidSpecErr)
+	}
+
+	switch spec.Kind {
+	case SpecKeyword:
+		for i, r := range spec.Pattern {
+			if i == 0 && !isIdentifierStart(r) || !isIdentifierChar(r) {
+				return fmt.Errorf("%w, 关键字 %s 不是标识符的形式", InvalidSpecErr, spec.Pattern)
+			}
+		}
+	case SpecPunctuator, SpecPattern:
+	default:
+		return fmt.Errorf("%w, 未知的规则种类 %d", InvalidSpecErr, spec.Kind)
+	}
+
+	return nil
+}
+
+// 已注册的全部规则, 按注册顺序排列
+func Specs() []TokenSpec {
+	registry.Lock()
+	defer registry.Unlock()
+
+	return append([]TokenSpec(nil), registry.specs...)
+}
+
+func tokenTable() (*dfa, []TokenSpec) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	if registry.dfa == nil {
+		d, err := newDFA(registry.specs)
+		if err != nil {
+			// 注册时已经检查过每条模式
+			panic(err)
+		}
+		registry.dfa = d
+	}
+
+	return registry.dfa, registry.specs
+}
@@ -4,42 +4,9 @@ import "unicode"
 
 type Symbol string
 
+// 非终结符; 终结符就是token类型, 见token.go
 const (
 	NilSymbol                                     Symbol = "NIL"
-	EOISymbol                                     Symbol = "EOI"
-	SymbolStringLiteral                           Symbol = "STRING_LITERAL"
-	SymbolIntLiteral                              Symbol = "INT_LITERAL"
-	SymbolDoubleLiteral                           Symbol = "DOUBLE_LITERAL"
-	SymbolNull                                    Symbol = "NULL"
-	SymbolTrue                                    Symbol = "TRUE"
-	SymbolFalse                                   Symbol = "FALSE"
-	SymbolIdentifier                              Symbol = "IDENTIFIER"
-	SymbolNew                                     Symbol = "NEW"
-	SymbolLp                                      Symbol = "LP"
-	SymbolRp                                      Symbol = "RP"
-	SymbolDot                                     Symbol = "DOT"
-	SymbolLc                                      Symbol = "LC"
-	SymbolRc                                      Symbol = "RC"
-	SymbolComma                                   Symbol = "COMMA"
-	SymbolReturn                                  Symbol = "RETURN"
-	SymbolContinue                                Symbol = "CONTINUE"
-	SymbolBreak                                   Symbol = "BREAK"
-	SymbolSemicolon                               Symbol = "SEMICOLON"
-	SymbolFor                                     Symbol = "FOR"
-	SymbolIf                                      Symbol = "IF"
-	SymbolElse                                    Symbol = "ELSE"
-	SymbolWhile                                   Symbol = "WHILE"
-	SymbolAssign                                  Symbol = "ASSIGN"
-	SymbolClass                                   Symbol = "CLASS"
-	SymbolThis                                    Symbol = "THIS"
-	SymbolInterface                               Symbol = "INTERFACE"
-	SymbolAbstract                                Symbol = "ABSTRACT"
-	SymbolImplements                              Symbol = "IMPLEMENTS"
-	SymbolExtends                                 Symbol = "EXTENDS"
-	SymbolVoid                                    Symbol = "VOID"
-	SymbolPublic                                  Symbol = "PUBLIC"
-	SymbolPrivate                                 Symbol = "PRIVATE"
-	SymbolProtected                               Symbol = "PROTECTED"
 	SymbolArgumentList                            Symbol = "argument_list"
 	SymbolMethodCall                              Symbol = "method_call"
 	SymbolNewObjExpression                        Symbol = "new_obj_expression"
@@ -47,11 +14,20 @@ const (
 	SymbolMethodCallExpression                    Symbol = "method_call_expression"
 	SymbolCallExpression                          Symbol = "call_expression"
 	SymbolExpression                              Symbol = "expression"
+	SymbolLogicalOrExpression                     Symbol = "logical_or_expression"
+	SymbolLogicalAndExpression                    Symbol = "logical_and_expression"
+	SymbolEqualityExpression                      Symbol = "equality_expression"
+	SymbolRelationalExpression                    Symbol = "relational_expression"
+	SymbolAdditiveExpression                      Symbol = "additive_expression"
+	SymbolMultiplicativeExpression                Symbol = "multiplicative_expression"
+	SymbolUnaryExpression                         Symbol = "unary_expression"
+	SymbolPrimaryExpression                       Symbol = "primary_expression"
 	SymbolTypeVar                                 Symbol = "type_var"
 	SymbolExpressionStatement                     Symbol = "expression_statement"
 	SymbolVarAssignStatement                      Symbol = "var_assign_statement"
 	SymbolVarDeclarationStatement                 Symbol = "var_declaration_statement"
 	SymbolReturnStatement                         Symbol = "return_statement"
+	SymbolSuperCallStatement                      Symbol = "super_call_statement"
 	SymbolContinueStatement                       Symbol = "continue_statement"
 	SymbolBreakStatement                          Symbol = "break_statement"
 	SymbolForStatement                            Symbol = "for_statement"
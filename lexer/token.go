@@ -1,51 +1,83 @@
 package lexer
 
 import (
-	"mizar/utils"
-	"sort"
-
 	"github.com/Orlion/merak/symbol"
 )
 
-type TokenType string
+// token类型同时是语法分析中终结符的名字
+type TokenType = Symbol
+
+// 由Lexer直接识别的token: 输入结束以及字符串、字符和数字字面量
+var (
+	EoiToken           = TokenType("EOI")
+	TokenStringLiteral = TokenType("STRING_LITERAL")
+	TokenCharLiteral   = TokenType("CHAR_LITERAL")
+	TokenDoubleLiteral = TokenType("DOUBLE_LITERAL")
+	TokenIntLiteral    = TokenType("INT_LITERAL")
+)
 
-const (
-	EoiToken           TokenType = "EOI"
-	TokenAssign                  = "ASSIGN"
-	TokenLc                      = "LC"
-	TokenRc                      = "RC"
-	TokenLp                      = "LP"
-	TokenRp                      = "RP"
-	TokenSemicolon               = "SEMICOLON"
-	TokenComma                   = "COMMA"
-	TokenDot                     = "DOT"
-	TokenContinue                = "CONTINUE"
-	TokenReturn                  = "RETURN"
-	TokenWhile                   = "WHILE"
-	TokenBreak                   = "BREAK"
-	TokenElse                    = "ELSE"
-	TokenVoid                    = "VOID"
-	TokenIf                      = "IF"
-	TokenFor                     = "FOR"
-	TokenClass                   = "CLASS"
-	TokenInterface               = "INTERFACE"
-	TokenAbstract                = "ABSTRACT"
-	TokenPublic                  = "PUBLIC"
-	TokenPrivate                 = "PRIVATE"
-	TokenProtected               = "PROTECTED"
-	TokenImplements              = "IMPLEMENTS"
-	TokenExtends                 = "EXTENDS"
-	TokenNew                     = "NEW"
-	TokenTrue                    = "TRUE"
-	TokenFalse                   = "FALSE"
-	TokenNull                    = "NULL"
-	TokenThis                    = "THIS"
-	TokenIdentifier              = "IDENTIFIER"
-	TokenStringLiteral           = "STRING_LITERAL"
-	TokenDoubleLiteral           = "DOUBLE_LITERAL"
-	TokenIntLiteral              = "INT_LITERAL"
+// 由词法规则识别的token, 每个token在这里声明一次: 声明时即注册其规则, 并作为终结符用于语法分析。
+// 规则按声明的顺序注册
+var (
+	TokenAssign     = register(SpecPunctuator, "=", "ASSIGN")
+	TokenLc         = register(SpecPunctuator, "{", "LC")
+	TokenRc         = register(SpecPunctuator, "}", "RC")
+	TokenLp         = register(SpecPunctuator, "(", "LP")
+	TokenRp         = register(SpecPunctuator, ")", "RP")
+	TokenSemicolon  = register(SpecPunctuator, ";", "SEMICOLON")
+	TokenComma      = register(SpecPunctuator, ",", "COMMA")
+	TokenDot        = register(SpecPunctuator, ".", "DOT")
+	TokenAdd        = register(SpecPunctuator, "+", "ADD")
+	TokenSub        = register(SpecPunctuator, "-", "SUB")
+	TokenMul        = register(SpecPunctuator, "*", "MUL")
+	TokenDiv        = register(SpecPunctuator, "/", "DIV")
+	TokenMod        = register(SpecPunctuator, "%", "MOD")
+	TokenEq         = register(SpecPunctuator, "==", "EQ")
+	TokenNe         = register(SpecPunctuator, "!=", "NE")
+	TokenLt         = register(SpecPunctuator, "<", "LT")
+	TokenLe         = register(SpecPunctuator, "<=", "LE")
+	TokenGt         = register(SpecPunctuator, ">", "GT")
+	TokenGe         = register(SpecPunctuator, ">=", "GE")
+	TokenAnd        = register(SpecPunctuator, "&&", "AND")
+	TokenOr         = register(SpecPunctuator, "||", "OR")
+	TokenNot        = register(SpecPunctuator, "!", "NOT")
+	TokenContinue   = register(SpecKeyword, "continue", "CONTINUE")
+	TokenReturn     = register(SpecKeyword, "return", "RETURN")
+	TokenWhile      = register(SpecKeyword, "while", "WHILE")
+	TokenBreak      = register(SpecKeyword, "break", "BREAK")
+	TokenElse       = register(SpecKeyword, "else", "ELSE")
+	TokenVoid       = register(SpecKeyword, "void", "VOID")
+	TokenIf         = register(SpecKeyword, "if", "IF")
+	TokenFor        = register(SpecKeyword, "for", "FOR")
+	TokenClass      = register(SpecKeyword, "class", "CLASS")
+	TokenInterface  = register(SpecKeyword, "interface", "INTERFACE")
+	TokenAbstract   = register(SpecKeyword, "abstract", "ABSTRACT")
+	TokenPublic     = register(SpecKeyword, "public", "PUBLIC")
+	TokenPrivate    = register(SpecKeyword, "private", "PRIVATE")
+	TokenProtected  = register(SpecKeyword, "protected", "PROTECTED")
+	TokenImplements = register(SpecKeyword, "implements", "IMPLEMENTS")
+	TokenExtends    = register(SpecKeyword, "extends", "EXTENDS")
+	TokenTrue       = register(SpecKeyword, "true", "TRUE")
+	TokenFalse      = register(SpecKeyword, "false", "FALSE")
+	TokenNull       = register(SpecKeyword, "null", "NULL")
+	TokenThis       = register(SpecKeyword, "this", "THIS")
+	TokenNew        = register(SpecKeyword, "new", "NEW")
+	TokenPackage    = register(SpecKeyword, "package", "PACKAGE")
+	TokenImport     = register(SpecKeyword, "import", "IMPORT")
+	TokenSuper      = register(SpecKeyword, "super", "SUPER")
+	TokenNative     = register(SpecKeyword, "native", "NATIVE")
+	TokenIdentifier = register(SpecPattern, `[\p{XID_Start}_]\p{XID_Continue}*`, "IDENTIFIER")
 )
 
+// 注册内置的词法规则并返回其token类型, 内置规则有误时panic
+func register(kind SpecKind, pattern string, t string) TokenType {
+	if err := Register(TokenSpec{Kind: kind, Pattern: pattern, T: TokenType(t)}); err != nil {
+		panic(err)
+	}
+
+	return TokenType(t)
+}
+
 type Token struct {
 	T           TokenType
 	Lexeme      string
@@ -53,7 +85,10 @@ type Token struct {
 	StartColumn int
 	EndLine     int
 	EndColumn   int
+	StartOffset int // 在源码中的字节偏移, 见Input
+	EndOffset   int
 	FileName    string // token所在文件名
+	Doc         string // 紧挨在token之前的文档注释, 包含 /** 和 */, 需要开启Lexer.SetKeepDocComments
 }
 
 func (t *Token) ToSymbol() symbol.Symbol {
@@ -63,45 +98,3 @@ func (t *Token) ToSymbol() symbol.Symbol {
 func (t *Token) ToString() string {
 	return t.Lexeme
 }
-
-var reservedWords = []string{
-	"=", "{", "}", "(", ")", ";", ",", ".",
-	"continue", "return", "while", "break", "else", "void", "if", "for", "class", "interface", "abstract", "public",
-	"private", "protected", "implements", "extends", "true", "false", "null", "this", "new",
-}
-
-var reservedWords2TokenTypeMap = map[string]TokenType{
-	"=":          TokenAssign,
-	"{":          TokenLc,
-	"}":          TokenRc,
-	"(":          TokenLp,
-	")":          TokenRp,
-	";":          TokenSemicolon,
-	",":          TokenComma,
-	".":          TokenDot,
-	"continue":   TokenContinue,
-	"return":     TokenReturn,
-	"while":      TokenWhile,
-	"break":      TokenBreak,
-	"else":       TokenElse,
-	"void":       TokenVoid,
-	"if":         TokenIf,
-	"for":        TokenFor,
-	"class":      TokenClass,
-	"interface":  TokenInterface,
-	"abstract":   TokenAbstract,
-	"public":     TokenPublic,
-	"private":    TokenPrivate,
-	"protected":  TokenProtected,
-	"implements": TokenImplements,
-	"extends":    TokenExtends,
-	"true":       TokenTrue,
-	"false":      TokenFalse,
-	"null":       TokenNull,
-	"this":       TokenThis,
-	"new":        TokenNew,
-}
-
-func init() {
-	sort.Sort(utils.SortByLength(reservedWords))
-}
@@ -0,0 +1,192 @@
+package lexer
+
+import (
+	"sort"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 标识符由UAX #31中的XID_Start字符或下划线开始, 之后是XID_Continue字符。
+// 标准库没有这两个属性的表, 按其定义由通用类别和属性计算:
+//
+//	ID_Start    = L + Nl + Other_ID_Start - Pattern_Syntax - Pattern_White_Space
+//	ID_Continue = ID_Start + Mn + Mc + Nd + Pc + Other_ID_Continue - Pattern_Syntax - Pattern_White_Space
+//
+// XID在此基础上去掉了NFKC规范化后不再是标识符的少数字符。
+// 这两个表在包级变量中初始化, 先于注册词法规则的init
+var xidStart, xidContinue = xidTables()
+
+func xidTables() (xidStart []dfaEdge, xidContinue []dfaEdge) {
+	pattern := union(tableRanges(unicode.Pattern_Syntax), tableRanges(unicode.Pattern_White_Space))
+	idStart := union(tableRanges(unicode.L), tableRanges(unicode.Nl), tableRanges(unicode.Other_ID_Start))
+	idContinue := union(idStart, tableRanges(unicode.Mn), tableRanges(unicode.Mc), tableRanges(unicode.Nd),
+		tableRanges(unicode.Pc), tableRanges(unicode.Other_ID_Continue))
+
+	xidStart = subtract(subtract(idStart, pattern), runeRanges(
+		0x037A, 0x0E33, 0x0EB3, 0x309B, 0x309C, 0xFC5E, 0xFC5F, 0xFC60, 0xFC61, 0xFC62, 0xFC63, 0xFDFA, 0xFDFB,
+		0xFE70, 0xFE72, 0xFE74, 0xFE76, 0xFE78, 0xFE7A, 0xFE7C, 0xFE7E, 0xFF9E, 0xFF9F))
+	xidContinue = subtract(subtract(idContinue, pattern), runeRanges(
+		0x037A, 0x309B, 0x309C, 0xFC5E, 0xFC5F, 0xFC60, 0xFC61, 0xFC62, 0xFC63, 0xFDFA, 0xFDFB,
+		0xFE70, 0xFE72, 0xFE74, 0xFE76, 0xFE78, 0xFE7A, 0xFE7C, 0xFE7E))
+
+	return
+}
+
+func isIdentifierStart(r rune) bool {
+	return r == '_' || inRanges(xidStart, r)
+}
+
+func isIdentifierChar(r rune) bool {
+	return inRanges(xidContinue, r)
+}
+
+// 模式中 \p{Name} 表示的字符类: XID_Start、XID_Continue, 以及标准库unicode包中的类别、文字和属性, 如 \p{Han}
+func namedClass(name string) ([]dfaEdge, bool) {
+	switch name {
+	case "XID_Start":
+		return xidStart, true
+	case "XID_Continue":
+		return xidContinue, true
+	}
+	for _, tables := range []map[string]*unicode.RangeTable{unicode.Categories, unicode.Scripts, unicode.Properties} {
+		if t, ok := tables[name]; ok {
+			return tableRanges(t), true
+		}
+	}
+
+	return nil, false
+}
+
+func tableRanges(t *unicode.RangeTable) []dfaEdge {
+	var ranges []dfaEdge
+	add := func(lo, hi, stride rune) {
+		if stride == 1 {
+			ranges = append(ranges, dfaEdge{lo: lo, hi: hi})
+			return
+		}
+		for r := lo; r <= hi; r += stride {
+			ranges = append(ranges, dfaEdge{lo: r, hi: r})
+		}
+	}
+	for _, r := range t.R16 {
+		add(rune(r.Lo), rune(r.Hi), rune(r.Stride))
+	}
+	for _, r := range t.R32 {
+		add(rune(r.Lo), rune(r.Hi), rune(r.Stride))
+	}
+
+	return union(ranges)
+}
+
+func runeRanges(runes ...rune) []dfaEdge {
+	ranges := make([]dfaEdge, len(runes))
+	for i, r := range runes {
+		ranges[i] = dfaEdge{lo: r, hi: r}
+	}
+
+	return union(ranges)
+}
+
+// 合并为有序且互不相邻的区间
+func union(sets ...[]dfaEdge) []dfaEdge {
+	var ranges []dfaEdge
+	for _, set := range sets {
+		ranges = append(ranges, set...)
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].lo < ranges[j].lo })
+
+	var result []dfaEdge
+	for _, iv := range ranges {
+		if last := len(result) - 1; last >= 0 && iv.lo <= result[last].hi+1 {
+			if iv.hi > result[last].hi {
+				result[last].hi = iv.hi
+			}
+			continue
+		}
+		result = append(result, iv)
+	}
+
+	return result
+}
+
+// a中不在b里的部分, a和b都是union的结果
+func subtract(a []dfaEdge, b []dfaEdge) []dfaEdge {
+	var result []dfaEdge
+	j := 0
+	for _, iv := range a {
+		lo := iv.lo
+		for ; j < len(b) && b[j].hi < lo; j++ {
+		}
+		for k := j; k < len(b) && b[k].lo <= iv.hi; k++ {
+			if b[k].lo > lo {
+				result = append(result, dfaEdge{lo: lo, hi: b[k].lo - 1})
+			}
+			lo = b[k].hi + 1
+		}
+		if lo <= iv.hi {
+			result = append(result, dfaEdge{lo: lo, hi: iv.hi})
+		}
+	}
+
+	return result
+}
+
+func inRanges(ranges []dfaEdge, r rune) bool {
+	i := sort.Search(len(ranges), func(i int) bool { return ranges[i].hi >= r })
+	return i < len(ranges) && ranges[i].lo <= r
+}
+
+// 改变文本显示顺序的双向文本控制字符, 可以使注释或字符串中的代码看起来与实际不同
+func isBidiControl(r rune) bool {
+	switch {
+	case r == 0x061C, r == 0x200E, r == 0x200F:
+		return true
+	case 0x202A <= r && r <= 0x202E, 0x2066 <= r && r <= 0x2069:
+		return true
+	}
+
+	return false
+}
+
+// 外形与ASCII字母或数字相同的字符, 值为相似的ASCII字符; 全角字母和数字由confusable另外处理
+var confusables = map[rune]rune{
+	// 西里尔字母
+	'а': 'a', 'е': 'e', 'о': 'o', 'р': 'p', 'с': 'c', 'у': 'y', 'х': 'x', 'і': 'i', 'ј': 'j', 'ѕ': 's', 'ԁ': 'd',
+	'ԛ': 'q', 'ԝ': 'w', 'һ': 'h', 'А': 'A', 'В': 'B', 'Е': 'E', 'К': 'K', 'М': 'M', 'Н': 'H', 'О': 'O', 'Р': 'P',
+	'С': 'C', 'Т': 'T', 'Х': 'X', 'І': 'I', 'Ј': 'J', 'Ѕ': 'S', 'Ү': 'Y', 'Ԛ': 'Q', 'Ԝ': 'W',
+	// 希腊字母
+	'ο': 'o', 'ν': 'v', 'Α': 'A', 'Β': 'B', 'Ε': 'E', 'Ζ': 'Z', 'Η': 'H', 'Ι': 'I', 'Κ': 'K', 'Μ': 'M', 'Ν': 'N',
+	'Ο': 'O', 'Ρ': 'P', 'Τ': 'T', 'Υ': 'Y', 'Χ': 'X',
+	// 拉丁字母
+	'ı': 'i', 'ɡ': 'g', 'ℓ': 'l',
+}
+
+func confusable(r rune) (rune, bool) {
+	if 0xFF10 <= r && r <= 0xFF19 || 0xFF21 <= r && r <= 0xFF3A || 0xFF41 <= r && r <= 0xFF5A {
+		return r - 0xFEE0, true
+	}
+	a, ok := confusables[r]
+
+	return a, ok
+}
+
+// 标识符中与ASCII字符相似的第一个字符。只有标识符与ASCII字母混用, 或者整体看起来就是ASCII标识符时才报告,
+// 因此普通的俄文或希腊文标识符不受影响
+func findConfusable(ident string) (r rune, ascii rune, found bool) {
+	mixed, looksASCII := false, true
+	for _, c := range ident {
+		if c < utf8.RuneSelf {
+			mixed = mixed || unicode.IsLetter(c)
+			continue
+		}
+		if a, ok := confusable(c); ok {
+			if !found {
+				r, ascii, found = c, a, true
+			}
+			continue
+		}
+		looksASCII = false
+	}
+
+	return r, ascii, found && (mixed || looksASCII)
+}
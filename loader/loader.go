@@ -0,0 +1,361 @@
+package loader
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mizar/ast"
+	"mizar/diag"
+	"mizar/lexer"
+	"mizar/parser"
+	"mizar/prelude"
+	"mizar/utils"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var (
+	ImportNotFoundErr  = errors.New("找不到导入的包")
+	ImportCycleErr     = errors.New("循环导入")
+	PackageMismatchErr = errors.New("包名与所在目录不符")
+	AmbiguousTypeErr   = errors.New("类型名不明确")
+	TypeNotVisibleErr  = errors.New("类型在该包中不可见")
+)
+
+const (
+	CodeImportNotFound  diag.Code = "E0501"
+	CodeImportCycle     diag.Code = "E0502"
+	CodePackageMismatch diag.Code = "E0503"
+	CodeAmbiguousType   diag.Code = "E0504"
+	CodeTypeNotVisible  diag.Code = "E0505"
+)
+
+// 源文件扩展名
+const SourceExt = ".mi"
+
+// 模块加载器, 从入口开始发现并分析全部源文件, 合并为一个程序
+//
+// 包对应源码根目录下的目录, 包名为相对路径以点连接, 例如 root/a/b 中的文件属于包 a.b;
+// 文件可以省略包声明, 省略时属于所在目录对应的包。import a.b; 导入包中的全部类和接口,
+// 文件中可以直接使用本包以及导入包中的类型名, 本包的类型优先, 多个导入包中的同名类型在使用时报告不明确;
+// 标准库属于根包但在所有包中可见, 根包中的其他类型只能在根包中使用
+type Loader struct {
+	root     string
+	parser   *parser.Parser
+	prelude  *ast.TranslationUnit
+	packages map[string]*pkg
+	sources  map[string]string
+	diags    diag.List
+	err      error
+}
+
+type pkg struct {
+	path    string
+	units   []*ast.TranslationUnit
+	loading bool // 正在加载其导入的包, 再次遇到时说明存在循环导入
+}
+
+// root为源码根目录, 为空时由入口推断: 入口所在的目录去掉入口声明的包名对应的部分,
+// 例如 app/main.mi 声明了 package app 时根目录为app所在的目录
+func NewLoader(root string) *Loader {
+	return &Loader{
+		root:     root,
+		parser:   parser.NewParser(),
+		packages: make(map[string]*pkg),
+		sources:  make(map[string]string),
+	}
+}
+
+// 加载入口文件或目录(目录中的全部源文件)以及导入的全部包
+//
+// 返回的TranslationUnit是整个程序: InterfaceMap、ClassMap以全限定名为键, 类和接口的Name也改为全限定名,
+// 源码中的类型名都已替换为全限定名; Package为入口所在的包
+func (l *Loader) Load(entry string) (program *ast.TranslationUnit, err error) {
+	files, entryDir, err := entryFiles(entry)
+	if err != nil {
+		return
+	}
+	var path string
+	if l.root == "" {
+		l.root, path = inferRoot(entryDir, files)
+	} else if path, err = l.packagePath(entryDir); err != nil {
+		return
+	}
+
+	l.load(path, files, nil)
+	l.loadPrelude()
+	if l.err != nil {
+		err = l.err
+		return
+	}
+
+	program, types := l.merge()
+	program.Package = path
+	for _, unit := range program.Units {
+		l.qualify(unit, types)
+	}
+
+	err = l.diags.Err()
+
+	return
+}
+
+// 已读入的源文件, 文件名 -> 源码, 用于渲染诊断
+func (l *Loader) Sources() map[string]string {
+	return l.sources
+}
+
+// 加载过程中产生的全部诊断, 包括语法分析的警告
+func (l *Loader) Diagnostics() diag.List {
+	return l.diags
+}
+
+// 深度优先加载包及其导入的包, stack为正在加载的包
+func (l *Loader) load(path string, files []string, stack []string) {
+	p := &pkg{path: path, loading: true}
+	l.packages[path] = p
+
+	for _, file := range files {
+		if unit := l.parseFile(file, path); unit != nil {
+			p.units = append(p.units, unit)
+		}
+	}
+
+	stack = append(stack, path)
+	for _, unit := range p.units {
+		for _, imp := range unit.Imports {
+			l.importPackage(imp, stack)
+		}
+	}
+
+	p.loading = false
+}
+
+func (l *Loader) importPackage(imp *ast.Import, stack []string) {
+	if dep, exists := l.packages[imp.Path]; exists {
+		if dep.loading {
+			// 导入链中从该包开始的部分构成环
+			i := 0
+			for stack[i] != imp.Path {
+				i++
+			}
+			cycle := append(append([]string{}, stack[i:]...), imp.Path)
+			l.diags.Add(diag.Errorf(CodeImportCycle, imp.Span, ImportCycleErr, "%s", strings.Join(cycle, " -> ")))
+		}
+		return
+	}
+
+	files, _ := sourceFiles(filepath.Join(l.root, filepath.FromSlash(strings.ReplaceAll(imp.Path, ".", "/"))))
+	if len(files) == 0 {
+		l.diags.Add(diag.Errorf(CodeImportNotFound, imp.Span, ImportNotFoundErr, "%s", imp.Path))
+		return
+	}
+
+	l.load(imp.Path, files, stack)
+}
+
+// 标准库属于根包, 其中的类不需要导入, 在所有包中都可以通过简单名使用
+func (l *Loader) loadPrelude() {
+	p, exists := l.packages[""]
+	if !exists {
+		p = &pkg{}
+		l.packages[""] = p
+	}
+
+	l.prelude = l.parse(prelude.File, prelude.Source, "")
+	p.units = append(p.units, l.prelude)
+}
+
+func (l *Loader) parseFile(file string, path string) *ast.TranslationUnit {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		if l.err == nil {
+			l.err = err
+		}
+		return nil
+	}
+
+	return l.parse(file, string(b), path)
+}
+
+func (l *Loader) parse(file string, source string, path string) *ast.TranslationUnit {
+	l.sources[file] = source
+
+	// 语法错误已经记录在诊断中, 仍然使用分析出的部分继续
+	unit, _ := l.parser.Parse(lexer.NewFileLexer(file, source))
+	l.diags.Append(l.parser.Diagnostics())
+
+	if unit.Package == "" {
+		unit.Package = path
+	} else if unit.Package != path {
+		l.diags.Add(diag.Errorf(CodePackageMismatch, unit.Start.Extend("package"), PackageMismatchErr, "%s 位于包 %s 的目录中", unit.Package, displayPackage(path)))
+		unit.Package = path
+	}
+
+	return unit
+}
+
+// 合并所有包中的类和接口, types为 包名 -> 简单名 -> 全限定名
+func (l *Loader) merge() (program *ast.TranslationUnit, types map[string]map[string]string) {
+	program = ast.NewTranslationUnit()
+	types = make(map[string]map[string]string)
+	spans := make(map[string]ast.Span) // 全限定名 -> 定义处, 用于报告重复定义
+
+	for _, path := range utils.SortedKeys(l.packages) {
+		types[path] = make(map[string]string)
+		for _, unit := range l.packages[path].units {
+			program.Units = append(program.Units, unit)
+			for _, name := range utils.SortedKeys(unit.InterfaceMap) {
+				inter := unit.InterfaceMap[name]
+				qualified := ast.QualifiedName(path, name)
+				span := inter.Pos.Extend(name)
+				if l.redefined(qualified, span, spans, parser.CodeInterfaceRedefine, parser.InterfaceRedefineErr) {
+					continue
+				}
+				inter.Name = qualified
+				program.InterfaceMap[qualified] = inter
+				types[path][name] = qualified
+			}
+			for _, name := range utils.SortedKeys(unit.ClassMap) {
+				class := unit.ClassMap[name]
+				qualified := ast.QualifiedName(path, name)
+				span := class.Pos.Extend(name)
+				if l.redefined(qualified, span, spans, parser.CodeClassRedefine, parser.ClassRedefineErr) {
+					continue
+				}
+				class.Name = qualified
+				program.ClassMap[qualified] = class
+				types[path][name] = qualified
+			}
+		}
+	}
+
+	return
+}
+
+func (l *Loader) redefined(qualified string, span ast.Span, spans map[string]ast.Span, code diag.Code, err error) bool {
+	prev, exists := spans[qualified]
+	if !exists {
+		spans[qualified] = span
+		return false
+	}
+
+	l.diags.Add(diag.Errorf(code, span, err, "%s", qualified).WithNote(prev, "%s 已在此处定义", qualified))
+
+	return true
+}
+
+// 入口的源文件以及所在目录
+func entryFiles(entry string) (files []string, dir string, err error) {
+	info, err := os.Stat(entry)
+	if err != nil {
+		return
+	}
+	if !info.IsDir() {
+		return []string{entry}, filepath.Dir(entry), nil
+	}
+
+	if files, err = sourceFiles(entry); err == nil && len(files) == 0 {
+		err = fmt.Errorf("%s 中没有 %s 源文件", entry, SourceExt)
+	}
+	dir = entry
+
+	return
+}
+
+// 目录中的源文件, 按文件名排序
+func sourceFiles(dir string) (files []string, err error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	for _, info := range infos {
+		if !info.IsDir() && filepath.Ext(info.Name()) == SourceExt {
+			files = append(files, filepath.Join(dir, info.Name()))
+		}
+	}
+	sort.Strings(files)
+
+	return
+}
+
+// 入口的第一个文件声明的包名与所在目录的末尾部分相同时, 去掉这部分作为根目录, 返回根目录和入口的包名;
+// 没有声明包名或者不相同时根目录为入口所在的目录, 入口属于根包
+func inferRoot(dir string, files []string) (root string, path string) {
+	if len(files) == 0 {
+		return dir, ""
+	}
+	source, err := ioutil.ReadFile(files[0])
+	if err != nil {
+		return dir, ""
+	}
+	path = declaredPackage(files[0], string(source))
+	if path == "" {
+		return dir, ""
+	}
+
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return dir, ""
+	}
+	root = dir
+	names := strings.Split(path, ".")
+	for i := len(names) - 1; i >= 0; i-- {
+		if filepath.Base(abs) != names[i] {
+			return dir, ""
+		}
+		abs = filepath.Dir(abs)
+		root = filepath.Join(root, "..")
+	}
+
+	return root, path
+}
+
+// 文件开头的包声明中的包名, 没有包声明或者有词法错误时为空
+func declaredPackage(file string, source string) string {
+	lex := lexer.NewFileLexer(file, source)
+	next := func() *lexer.Token {
+		t, err := lex.Next()
+		if err != nil {
+			return nil
+		}
+		return t.(*lexer.Token)
+	}
+
+	if t := next(); t == nil || t.T != lexer.TokenPackage {
+		return ""
+	}
+	var b strings.Builder
+	for t := next(); t != nil && (t.T == lexer.TokenIdentifier || t.T == lexer.TokenDot); t = next() {
+		b.WriteString(t.Lexeme)
+	}
+
+	return b.String()
+}
+
+// 目录对应的包名
+func (l *Loader) packagePath(dir string) (string, error) {
+	rel, err := filepath.Rel(l.root, dir)
+	if err != nil {
+		return "", err
+	}
+	if rel == "." {
+		return "", nil
+	}
+	if strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("%s 不在源码根目录 %s 中", dir, l.root)
+	}
+
+	return strings.ReplaceAll(filepath.ToSlash(rel), "/", "."), nil
+}
+
+func displayPackage(path string) string {
+	if path == "" {
+		return "(根包)"
+	}
+
+	return path
+}
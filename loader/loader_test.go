@@ -0,0 +1,201 @@
+package loader
+
+import (
+	"errors"
+	"io/ioutil"
+	"mizar/check"
+	"mizar/diag"
+	"mizar/log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 在临时目录中创建源文件, files为 相对路径 -> 源码
+func writeFiles(t *testing.T, files map[string]string) string {
+	log.Init(logrus.ErrorLevel)
+	root, err := ioutil.TempDir("", "mizar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, source := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err = ioutil.WriteFile(path, []byte(source), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return root
+}
+
+func TestLoad(t *testing.T) {
+	root := writeFiles(t, map[string]string{
+		"main.mi": `import util;
+class Main {
+    public Int main() {
+        Counter c = new Counter();
+        Point p = new Point();
+        return c.next(null) + p.x;
+    }
+}`,
+		"point.mi": `class Point {
+    public Int x;
+}`,
+		"util/counter.mi": `package util;
+class Counter {
+    public Int count;
+    public Int next(Point p) {
+        return count;
+    }
+}
+class Point {
+}`,
+	})
+	defer os.RemoveAll(root)
+
+	program, err := NewLoader(root).Load(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"Main", "Point", "util.Counter", "util.Point"} {
+		if class, exists := program.ClassMap[name]; !exists || class.Name != name {
+			t.Error(name)
+		}
+	}
+	// Counter中的Point指本包的util.Point, Main中的Point指根包的Point
+	if typ := program.ClassMap["util.Counter"].MethodDefinitionMap["next"]["util.Point"].ParameterList[0].Type; typ != "util.Point" {
+		t.Error(typ)
+	}
+
+	diags := check.Resolve(program)
+	diags.Append(check.TypeCheck(program))
+	if len(diags) > 0 {
+		t.Fatal(diags)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	root := writeFiles(t, map[string]string{
+		"main.mi": `import a;
+import missing;
+import b;
+import c;
+class Main {
+    public Box box;
+}`,
+		"a/a.mi": `import b;
+class A {
+}`,
+		"b/b.mi": `package b;
+import a;
+class Box {
+}`,
+		"c/c.mi": `package wrong;
+class Box {
+}`,
+	})
+	defer os.RemoveAll(root)
+
+	l := NewLoader(root)
+	_, err := l.Load(filepath.Join(root, "main.mi"))
+	var diags diag.List
+	if !errors.As(err, &diags) {
+		t.Fatal(err)
+	}
+
+	expects := []struct {
+		err  error
+		file string
+		msg  string
+	}{
+		{ImportCycleErr, "b/b.mi", "2:1: 循环导入 a -> b -> a"},
+		{ImportNotFoundErr, "main.mi", "2:1: 找不到导入的包 missing"},
+		{PackageMismatchErr, "c/c.mi", "1:1: 包名与所在目录不符 wrong 位于包 c 的目录中"},
+		{AmbiguousTypeErr, "main.mi", "6:5: 类型名不明确 Box 可能是 b.Box, c.Box"},
+	}
+	if len(diags) != len(expects) {
+		t.Fatal(diags)
+	}
+	for i, expect := range expects {
+		msg := filepath.Join(root, filepath.FromSlash(expect.file)) + ":" + expect.msg
+		if !errors.Is(diags[i], expect.err) || diags[i].Error() != msg {
+			t.Error(diags[i])
+		}
+	}
+}
+
+// 入口不在根包中时由其包声明推断根目录
+func TestLoadInferRoot(t *testing.T) {
+	root := writeFiles(t, map[string]string{
+		"app/main.mi": `package app;
+import util;
+class Main {
+    public Int main() {
+        Counter c = new Counter();
+        return c.next();
+    }
+}`,
+		"util/counter.mi": `package util;
+class Counter {
+    public Int next() {
+        return 1;
+    }
+}`,
+	})
+	defer os.RemoveAll(root)
+
+	for _, entry := range []string{filepath.Join(root, "app", "main.mi"), filepath.Join(root, "app")} {
+		program, err := NewLoader("").Load(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if program.Package != "app" {
+			t.Error(program.Package)
+		}
+		for _, name := range []string{"app.Main", "util.Counter"} {
+			if _, exists := program.ClassMap[name]; !exists {
+				t.Error(entry, name)
+			}
+		}
+	}
+}
+
+// 根包中的类型在其他包中不可见, 标准库除外
+func TestLoadRootVisibility(t *testing.T) {
+	root := writeFiles(t, map[string]string{
+		"main.mi": `import util;
+class Main {
+    public Int main() {
+        Counter c = new Counter();
+        return c.next();
+    }
+}
+class Secret {
+}`,
+		"util/counter.mi": `package util;
+class Counter {
+    public Secret secret;
+    public Int next() {
+        Out.printString("");
+        return 1;
+    }
+}`,
+	})
+	defer os.RemoveAll(root)
+
+	_, err := NewLoader("").Load(filepath.Join(root, "main.mi"))
+	var diags diag.List
+	if !errors.As(err, &diags) || len(diags) != 1 {
+		t.Fatal(err)
+	}
+	msg := filepath.Join(root, "util", "counter.mi") + ":3:5: 类型在该包中不可见 Secret 属于根包, 只能在根包中使用"
+	if !errors.Is(diags[0], TypeNotVisibleErr) || diags[0].Error() != msg {
+		t.Error(diags[0])
+	}
+}
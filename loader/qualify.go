@@ -0,0 +1,146 @@
+package loader
+
+import (
+	"mizar/ast"
+	"mizar/diag"
+	"sort"
+	"strings"
+)
+
+// 将文件中的类型名替换为全限定名, 未定义的类型保持原样, 由check报告
+type qualifier struct {
+	ast.BaseVisitor
+	l         *Loader
+	unit      *ast.TranslationUnit
+	ambiguous map[string][]string // 多个导入包中都存在的类型名 -> 候选的全限定名
+	root      map[string]string   // 根包中的类型, 在其他包中不可见
+}
+
+// 计算文件中可见的类型名并替换
+func (l *Loader) qualify(unit *ast.TranslationUnit, types map[string]map[string]string) {
+	q := &qualifier{l: l, unit: unit, ambiguous: make(map[string][]string), root: types[""]}
+
+	// 标准库在所有包中可见, 优先级最低
+	unit.Names = make(map[string]string)
+	for name := range l.prelude.InterfaceMap {
+		unit.Names[name] = name
+	}
+	for name := range l.prelude.ClassMap {
+		unit.Names[name] = name
+	}
+	imported := make(map[string]string)
+	for _, imp := range unit.Imports {
+		for name, qualified := range types[imp.Path] {
+			if prev, exists := imported[name]; exists && prev != qualified {
+				if len(q.ambiguous[name]) == 0 {
+					q.ambiguous[name] = append(q.ambiguous[name], prev)
+				}
+				q.ambiguous[name] = append(q.ambiguous[name], qualified)
+				continue
+			}
+			imported[name] = qualified
+		}
+	}
+	for name, qualified := range imported {
+		if _, exists := q.ambiguous[name]; !exists {
+			unit.Names[name] = qualified
+		}
+	}
+	// 本包的类型优先
+	for name, qualified := range types[unit.Package] {
+		unit.Names[name] = qualified
+		delete(q.ambiguous, name)
+	}
+
+	for _, inter := range unit.InterfaceMap {
+		inter.Accept(q)
+		for name, methods := range inter.MethodMap {
+			inter.MethodMap[name] = make(map[string]*ast.InterfaceMethod)
+			for _, im := range methods {
+				inter.MethodMap[name][ast.ParameterListKey(im.ParameterList)] = im
+			}
+		}
+	}
+	for _, class := range unit.ClassMap {
+		class.Accept(q)
+		rekey(class.MethodDefinitionMap)
+		rekey(class.AbstractMethodDefinitionMap)
+		constructors := class.ConstructorMap
+		class.ConstructorMap = make(map[string]*ast.MethodDefinition)
+		for _, md := range constructors {
+			class.ConstructorMap[ast.ParameterListKey(md.ParameterList)] = md
+		}
+	}
+}
+
+// 形参类型改为全限定名后, 按新的形参列表重新计算方法的键
+func rekey(methodMap map[string]map[string]*ast.MethodDefinition) {
+	for name, methods := range methodMap {
+		methodMap[name] = make(map[string]*ast.MethodDefinition)
+		for _, md := range methods {
+			methodMap[name][ast.ParameterListKey(md.ParameterList)] = md
+		}
+	}
+}
+
+func (q *qualifier) name(typeName string, span ast.Span) string {
+	if qualified, exists := q.unit.Names[typeName]; exists {
+		return qualified
+	}
+
+	if candidates, exists := q.ambiguous[typeName]; exists {
+		sort.Strings(candidates)
+		q.l.diags.Add(diag.Errorf(CodeAmbiguousType, span, AmbiguousTypeErr, "%s 可能是 %s", typeName, strings.Join(candidates, ", ")))
+	} else if _, exists := q.root[typeName]; exists && q.unit.Package != "" {
+		q.l.diags.Add(diag.Errorf(CodeTypeNotVisible, span, TypeNotVisibleErr, "%s 属于根包, 只能在根包中使用", typeName))
+	}
+
+	return typeName
+}
+
+func (q *qualifier) VisitClass(class *ast.Class) bool {
+	for i, name := range class.Extends {
+		class.Extends[i] = q.name(name, class.Span)
+	}
+	for i, name := range class.Implements {
+		class.Implements[i] = q.name(name, class.Span)
+	}
+	return true
+}
+
+func (q *qualifier) VisitInterfaceMethod(im *ast.InterfaceMethod) bool {
+	im.Type = q.name(im.Type, im.Span)
+	return true
+}
+
+func (q *qualifier) VisitPropertyDefinition(pd *ast.PropertyDefinition) bool {
+	pd.Type = q.name(pd.Type, pd.Span)
+	return true
+}
+
+func (q *qualifier) VisitMethodDefinition(md *ast.MethodDefinition) bool {
+	md.Type = q.name(md.Type, md.Span)
+	return true
+}
+
+func (q *qualifier) VisitParameter(param *ast.Parameter) bool {
+	param.Type = q.name(param.Type, param.Span)
+	return true
+}
+
+func (q *qualifier) VisitVarDeclarationStatement(stmt *ast.VarDeclarationStatement) bool {
+	stmt.Type = q.name(stmt.Type, stmt.Span)
+	return true
+}
+
+func (q *qualifier) VisitVarAssignStatement(stmt *ast.VarAssignStatement) bool {
+	if stmt.Type == ast.VarAssignStatementTypeVar {
+		stmt.VarType = q.name(stmt.VarType, stmt.Span)
+	}
+	return true
+}
+
+func (q *qualifier) VisitNewObjectExpression(expr *ast.NewObjectExpression) bool {
+	expr.Name = q.name(expr.Name, expr.Pos.Extend(expr.Name))
+	return true
+}
@@ -12,21 +12,21 @@ func Init(level logrus.Level) (err error) {
 }
 
 func Trace(fields logrus.Fields, args ...interface{}) {
-	logger.WithFields(fields).Trace(args)
+	logger.WithFields(fields).Trace(args...)
 }
 
 func Debug(fields logrus.Fields, args ...interface{}) {
-	logger.WithFields(fields).Debug(args)
+	logger.WithFields(fields).Debug(args...)
 }
 
 func Info(fields logrus.Fields, args ...interface{}) {
-	logger.WithFields(fields).Info(args)
+	logger.WithFields(fields).Info(args...)
 }
 
 func Warn(fields logrus.Fields, args ...interface{}) {
-	logger.WithFields(fields).Warn(args)
+	logger.WithFields(fields).Warn(args...)
 }
 
 func Error(fields logrus.Fields, args ...interface{}) {
-	logger.WithFields(fields).Error(args)
+	logger.WithFields(fields).Error(args...)
 }
@@ -1,55 +1,108 @@
 package main
 
 import (
-	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	"io/ioutil"
-	"mizar/asm"
-	"mizar/lexer"
 	"mizar/log"
-	"mizar/parser"
+	"os"
 
 	"github.com/sirupsen/logrus"
 )
 
+// 进程退出码
+const (
+	exitOK    = 0
+	exitError = 1 // 编译错误或执行失败
+	exitUsage = 2 // 命令行参数错误
+)
+
+// 子命令
+type command struct {
+	name  string
+	args  string // 位置参数说明
+	brief string
+	run   func(cmd *command, args []string) int
+}
+
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{name: "build", args: "<文件或目录>", brief: "编译为汇编、目标文件或可执行文件", run: buildCommand},
+		{name: "check", args: "<文件或目录>", brief: "只做语法和语义检查, 输出诊断信息", run: checkCommand},
+		{name: "run", args: "<文件或目录>", brief: "解释执行, 退出码为程序的退出码", run: runCommand},
+		{name: "tokens", args: "<文件>", brief: "输出词法分析得到的token", run: tokensCommand},
+		{name: "ast", args: "<文件或目录>", brief: "以JSON格式输出抽象语法树", run: astCommand},
+		{name: "fmt", args: "<文件或目录>", brief: "按统一的风格格式化源码", run: fmtCommand},
+	}
+}
+
 func main() {
-	var (
-		logLevel uint
-		dumpAst  bool
-	)
+	os.Exit(mizar(os.Args[1:]))
+}
+
+func mizar(args []string) int {
+	if len(args) == 0 {
+		usage()
+		return exitUsage
+	}
+
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		usage()
+		return exitOK
+	}
+
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			return cmd.run(cmd, args[1:])
+		}
+	}
 
-	// if len(os.Args) < 2 {
-	// 	fmt.Println("请输入文件名")
-	// 	return
-	// }
+	fmt.Fprintf(os.Stderr, "mizar: 未知的子命令 %s\n", args[0])
+	usage()
 
-	flag.UintVar(&logLevel, "log-level", uint(logrus.TraceLevel), "日志级别")
-	flag.BoolVar(&dumpAst, "dumpast", false, "是否打印抽象语法树")
-	flag.Parse()
+	return exitUsage
+}
 
-	log.Init(logrus.Level(logLevel))
+func usage() {
+	fmt.Fprintln(os.Stderr, "用法: mizar <子命令> [选项] <参数>")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "子命令:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", cmd.name, cmd.brief)
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "使用 mizar <子命令> -h 查看子命令的选项")
+}
 
-	// b, err := ioutil.ReadFile(os.Args[1])
-	b, err := ioutil.ReadFile("demo/base.mi")
-	if err != nil {
-		fmt.Println(err)
-		return
+// 每个子命令都支持 -log-level
+func (cmd *command) flagSet() (*flag.FlagSet, *uint) {
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	logLevel := fs.Uint("log-level", uint(logrus.WarnLevel), "日志级别, 0-6 依次为 panic fatal error warn info debug trace")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "用法: mizar %s [选项] %s\n", cmd.name, cmd.args)
+		fs.PrintDefaults()
 	}
 
-	source := string(b)
+	return fs, logLevel
+}
 
-	lexer := lexer.NewLexer(source)
-	parserObj := parser.NewParser()
-	ast, err := parserObj.Parse(lexer)
-	if err != nil {
-		fmt.Println(err)
-	} else {
-		bytes, _ := json.Marshal(ast)
-		fmt.Println(string(bytes))
+// 解析选项并初始化日志, 要求恰好一个位置参数; ok为false时code为应当返回的退出码
+func (cmd *command) parse(fs *flag.FlagSet, logLevel *uint, args []string) (arg string, code int, ok bool) {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return "", exitOK, false
+		}
+		return "", exitUsage, false
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return "", exitUsage, false
 	}
 
-	asm.Generate(ast)
+	log.Init(logrus.Level(*logLevel))
 
-	return
+	return fs.Arg(0), exitOK, true
 }
@@ -0,0 +1,51 @@
+package parser
+
+import (
+	"mizar/cst"
+	"mizar/lexer"
+
+	merak_ast "github.com/Orlion/merak/ast"
+	"github.com/Orlion/merak/symbol"
+)
+
+// 注册产生式。ParseCST时产生式的值是具体语法树节点, 其AST为action构造的ast节点:
+// 调用action前将子节点换回各自的ast节点, 因此action不需要区分两种分析方式。
+// 只有一个子节点且对应同一个ast节点的产生式(如各层表达式之间的传递)不产生新的节点
+func (parser *Parser) production(left symbol.Symbol, rights []symbol.Symbol, action func(args []interface{}) merak_ast.Node) {
+	parser.p.RegisterProduction(left, rights, false, func(args []interface{}) merak_ast.Node {
+		if parser.tree == nil {
+			return action(args)
+		}
+
+		values := make([]interface{}, len(args))
+		children := make([]cst.Element, len(args))
+		for i, arg := range args {
+			switch arg := arg.(type) {
+			case *cst.Node:
+				values[i], children[i] = arg.AST, arg
+			case *lexer.Token:
+				values[i], children[i] = arg, parser.tokens[arg]
+			}
+		}
+
+		value := action(values)
+		if child, ok := children[0].(*cst.Node); ok && len(children) == 1 && child.AST == value {
+			return child
+		}
+
+		return &cst.Node{Kind: left.ToString(), Children: children, AST: value}
+	})
+}
+
+// 在具体语法树中添加由tokens组成的节点, 用于不经过merak分析的部分
+func (parser *Parser) addNode(kind string, tokens []*lexer.Token, x interface{}) {
+	if parser.tree == nil || len(tokens) == 0 {
+		return
+	}
+
+	node := &cst.Node{Kind: kind, AST: x}
+	for _, t := range tokens {
+		node.Children = append(node.Children, parser.tokens[t])
+	}
+	parser.tree.Children = append(parser.tree.Children, node)
+}
@@ -1,3 +1,10 @@
+// 文件开头的包声明和导入声明由Parser.header手写分析, 不在LR(1)文法中:
+//
+// file -> package_declaration? import_declaration* translation_unit
+// package_declaration -> PACKAGE qualified_name SEMICOLON
+// import_declaration -> IMPORT qualified_name SEMICOLON
+// qualified_name -> IDENTIFIER | qualified_name DOT IDENTIFIER
+
 translation_unit -> class_interface_declaration_list
 
 class_interface_declaration_list ->     class_interface_declaration
@@ -22,8 +29,13 @@ class_statement ->    method_definition
 property_definition ->  member_modifier type_var SEMICOLON
                     |   member_modifier type_var ASSIGN expression_statement
 
+// 与类同名的方法是构造函数; 以分号结束的是抽象方法, 修饰符必须是abstract; native方法由运行时实现
 method_definition ->    member_modifier type_var LP RP block
-                        member_modifier type_var LP parameter_list RP block
+                |       member_modifier type_var LP parameter_list RP block
+                |       member_modifier type_var LP RP SEMICOLON
+                |       member_modifier type_var LP parameter_list RP SEMICOLON
+                |       member_modifier NATIVE type_var LP RP SEMICOLON
+                |       member_modifier NATIVE type_var LP parameter_list RP SEMICOLON
 
 
 statement_list  ->  statement
@@ -38,6 +50,7 @@ statement -> expression_statement
         |   break_statement
         |   continue_statement
         |   return_statement
+        |   super_call_statement
 
 
 expression_statement -> expression SEMICOLON
@@ -53,7 +66,40 @@ var_assign_statement -> type_var ASSIGN expression_statement // 变量声明并
 argument_list   ->  expression
                 | argument_list COMMA expression
 
-expression ->           STRING_LITERAL
+// 运算符优先级由低到高, 二元运算都是左结合
+expression -> logical_or_expression
+
+logical_or_expression ->    logical_and_expression
+                        |   logical_or_expression OR logical_and_expression
+
+logical_and_expression ->   equality_expression
+                        |   logical_and_expression AND equality_expression
+
+equality_expression ->      relational_expression
+                        |   equality_expression EQ relational_expression
+                        |   equality_expression NE relational_expression
+
+relational_expression ->    additive_expression
+                        |   relational_expression LT additive_expression
+                        |   relational_expression LE additive_expression
+                        |   relational_expression GT additive_expression
+                        |   relational_expression GE additive_expression
+
+additive_expression ->      multiplicative_expression
+                        |   additive_expression ADD multiplicative_expression
+                        |   additive_expression SUB multiplicative_expression
+
+multiplicative_expression ->    unary_expression
+                            |   multiplicative_expression MUL unary_expression
+                            |   multiplicative_expression DIV unary_expression
+                            |   multiplicative_expression MOD unary_expression
+
+unary_expression ->         primary_expression
+                        |   SUB unary_expression
+                        |   NOT unary_expression
+
+primary_expression ->   STRING_LITERAL
+                    |   CHAR_LITERAL
                     |   INT_LITERAL
                     |   DOUBLE_LITERAL
                     |   NULL
@@ -61,6 +107,7 @@ expression ->           STRING_LITERAL
                     |   FALSE
                     |   new_obj_expression
                     |   call_expression
+                    |   LP expression RP
 
 call_expression -> var_call_expression
                 -> method_call_expression
@@ -93,7 +140,7 @@ class_declaration ->    CLASS IDENTIFIER empty_block
                 |       CLASS IDENTIFIER LC class_statement_list extends_declaration implements_declaration RC
                 |       ABSTRACT CLASS IDENTIFIER extends_declaration implements_declaration LC class_statement_list RC
 
-// extends声明
+// extends声明, 文法允许多个类名以便给出更明确的错误, 由check.CheckHierarchy限制为单继承
 extends_declaration -> EXTENDS IDENTIFIER
                         | extends_declaration COMMA IDENTIFIER
 
@@ -134,6 +181,10 @@ continue_statement -> CONTINUE SEMICOLON
 return_statement -> RETURN SEMICOLON
                 |       RETURN expression_statement
 
+// 调用父类的构造函数, 只能作为构造函数的第一条语句
+super_call_statement -> SUPER LP RP SEMICOLON
+                |       SUPER LP argument_list RP SEMICOLON
+
 method_call ->  IDENTIFIER LP RP
             |   IDENTIFIER LP argument_list RP
 
@@ -1,17 +1,53 @@
 package parser
 
 import (
+	"errors"
+	"fmt"
+	"math"
 	"mizar/ast"
+	"mizar/cst"
+	"mizar/diag"
 	"mizar/lexer"
+	"mizar/utils"
+	"sort"
 	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/Orlion/merak"
 	merak_ast "github.com/Orlion/merak/ast"
+	merak_lexer "github.com/Orlion/merak/lexer"
 	"github.com/Orlion/merak/symbol"
 )
 
+var (
+	SyntaxErr            = errors.New("语法错误")
+	LiteralErr           = errors.New("非法的字面量")
+	ClassRedefineErr     = errors.New("重复定义的类")
+	InterfaceRedefineErr = errors.New("重复定义的接口")
+	LiteralOverflowErr   = errors.New("字面量超出范围")
+	MethodBodyErr        = errors.New("方法体错误")
+	MethodRedefineErr    = errors.New("重复定义的方法")
+)
+
+const (
+	CodeSyntax            diag.Code = "E0101"
+	CodeLiteral           diag.Code = "E0102"
+	CodeClassRedefine     diag.Code = "E0103"
+	CodeInterfaceRedefine diag.Code = "E0104"
+	CodeLiteralOverflow   diag.Code = "E0105"
+	CodeMethodBody        diag.Code = "E0106"
+	CodeMethodRedefine    diag.Code = "E0107"
+)
+
 type Parser struct {
-	p *merak.Parser
+	p      *merak.Parser
+	built  bool
+	diags  diag.List
+	tree   *cst.Node                   // 只在ParseCST时构造, 见production
+	tokens map[*lexer.Token]*cst.Token // 带有trivia的token
+	// 值为2^63的整数字面量, 作为一元负号的操作数时是Int的最小值, 否则在声明分析完成后报告超出范围
+	minInts map[*ast.Expression]*lexer.Token
 }
 
 func NewParser() *Parser {
@@ -21,19 +57,430 @@ func NewParser() *Parser {
 }
 
 // 自底向上分析
+//
+// 出错时不会立即停止: 词法错误跳过不识别的字符, 语法错误跳过出错的类或接口声明,
+// 最终返回由成功解析的声明组成的语法树, 以及所有诊断组成的diag.List
 func (parser *Parser) Parse(l *lexer.Lexer) (tu *ast.TranslationUnit, err error) {
-	astInter, err := parser.p.Build(lexer.SymbolTranslationUnit, lexer.EOISymbol).SetLexer(l).Parse()
-	if err != nil {
-		return
+	parser.prepare()
+	tokens, _ := parser.tokenize(l)
+	tu = parser.parse(l.FileName(), tokens)
+	err = parser.diags.Err()
+
+	return
+}
+
+// 与Parse相同, 同时构造具体语法树, 其中保留了全部空白和注释, 输出即为原来的源码, 见cst.Node。
+// token的原文和trivia按字节偏移从source中截取, 因此需要完整的源码
+func (parser *Parser) ParseCST(fileName string, source string) (tree *cst.Node, tu *ast.TranslationUnit, err error) {
+	parser.prepare()
+	tokens, eoi := parser.tokenize(lexer.NewFileLexer(fileName, source))
+	cstTokens := cst.NewTokens(source, append(tokens[:len(tokens):len(tokens)], eoi))
+	parser.tokens = make(map[*lexer.Token]*cst.Token, len(tokens))
+	for i, t := range tokens {
+		parser.tokens[t] = cstTokens[i]
+	}
+	parser.tree = &cst.Node{Kind: cst.KindFile}
+	defer func() {
+		parser.tree, parser.tokens = nil, nil
+	}()
+
+	tu = parser.parse(fileName, tokens)
+	tree = parser.tree
+	tree.AST = tu
+	// 文件末尾的trivia在输入结束token上
+	tree.Children = append(tree.Children, cstTokens[len(tokens)])
+	err = parser.diags.Err()
+
+	return
+}
+
+func (parser *Parser) prepare() {
+	if !parser.built {
+		parser.p.Build(lexer.SymbolTranslationUnit, lexer.EoiToken)
+		parser.built = true
 	}
+	parser.diags = nil
+	parser.minInts = make(map[*ast.Expression]*lexer.Token)
+}
 
-	tu = astInter.(*ast.TranslationUnit)
+func (parser *Parser) parse(fileName string, tokens []*lexer.Token) *ast.TranslationUnit {
+	tu := ast.NewTranslationUnit()
+	tu.FileName = fileName
+	for _, decl := range parser.declarations(parser.header(tu, tokens)) {
+		parser.parseDeclaration(tu, decl)
+	}
+	if len(tokens) > 0 {
+		tu.Span = tokenSpan(tokens[0]).Merge(tokenSpan(tokens[len(tokens)-1]))
+	}
+
+	return tu
+}
+
+// 读取全部token, 词法错误记录后继续; eoi是输入结束token, 读取源码出错时为nil
+func (parser *Parser) tokenize(l *lexer.Lexer) (tokens []*lexer.Token, eoi *lexer.Token) {
+	defer func() {
+		parser.diags.Append(l.Diagnostics())
+	}()
+
+	for {
+		t, err := l.Next()
+		if err != nil {
+			var d *diag.Diagnostic
+			if errors.As(err, &d) {
+				parser.diags.Add(d)
+				continue
+			}
+			// 其余错误只会在输入结束时出现
+			return
+		}
+
+		token := t.(*lexer.Token)
+		if token.T == lexer.EoiToken {
+			return tokens, token
+		}
+		tokens = append(tokens, token)
+	}
+}
+
+// 文件开头的包声明和导入声明, 形式简单, 直接手写分析, 返回其余的token:
+//
+//	package a.b;
+//	import c.d;
+func (parser *Parser) header(tu *ast.TranslationUnit, tokens []*lexer.Token) []*lexer.Token {
+	i := 0
+	if i < len(tokens) && tokens[i].T == lexer.TokenPackage {
+		tu.Package, i = parser.qualifiedName(tokens, i)
+		parser.addNode(cst.KindPackage, tokens[:i], nil)
+	}
+	for i < len(tokens) && tokens[i].T == lexer.TokenImport {
+		start := i
+		var path string
+		if path, i = parser.qualifiedName(tokens, i); path != "" {
+			imp := &ast.Import{
+				Path: path,
+				Pos:  tokenPos(tokens[start+1]),
+				Span: tokenSpan(tokens[start]).Merge(tokenSpan(tokens[i-1])),
+			}
+			tu.Imports = append(tu.Imports, imp)
+			parser.addNode(cst.KindImport, tokens[start:i], imp)
+		} else {
+			parser.addNode(cst.KindImport, tokens[start:i], nil)
+		}
+	}
+
+	return tokens[i:]
+}
+
+// 分析 关键字 IDENTIFIER (DOT IDENTIFIER)* SEMICOLON, 返回以点连接的名字和下一个token的下标;
+// 出错时报告语法错误, 跳过到分号之后或者下一个声明开始处, 此时名字为空
+func (parser *Parser) qualifiedName(tokens []*lexer.Token, start int) (name string, next int) {
+	var parts []string
+	i := start + 1
+	for {
+		if i >= len(tokens) || tokens[i].T != lexer.TokenIdentifier {
+			break
+		}
+		parts = append(parts, tokens[i].Lexeme)
+		if i++; i < len(tokens) && tokens[i].T == lexer.TokenDot {
+			i++
+			continue
+		}
+		if i < len(tokens) && tokens[i].T == lexer.TokenSemicolon {
+			return strings.Join(parts, "."), i + 1
+		}
+		break
+	}
+
+	if i < len(tokens) {
+		parser.unexpected(tokens[i])
+	} else {
+		parser.unexpected(eoiAfter(tokens[len(tokens)-1]))
+	}
+	for i < len(tokens) && tokens[i].T != lexer.TokenSemicolon && !isDeclarationStart(tokens[i]) {
+		i++
+	}
+	if i < len(tokens) && tokens[i].T == lexer.TokenSemicolon {
+		i++
+	}
+
+	return "", i
+}
+
+// 按大括号将token切分为一个个顶层的类或接口声明, 每个声明单独进行语法分析,
+// 使一个声明中的语法错误不影响其它声明
+func (parser *Parser) declarations(tokens []*lexer.Token) (decls [][]*lexer.Token) {
+	for i := 0; i < len(tokens); {
+		if !isDeclarationStart(tokens[i]) {
+			parser.unexpected(tokens[i])
+			start := i
+			for i < len(tokens) && !isDeclarationStart(tokens[i]) {
+				i++
+			}
+			parser.addNode(cst.KindError, tokens[start:i], nil)
+			continue
+		}
+
+		j, depth := i+1, 0
+		for ; j < len(tokens); j++ {
+			t := tokens[j]
+			// 类体中不会出现class、interface, 视为缺少右大括号, 在此处结束当前声明
+			if depth > 0 && (t.T == lexer.TokenClass || t.T == lexer.TokenInterface) {
+				break
+			}
+			if t.T == lexer.TokenLc {
+				depth++
+			} else if t.T == lexer.TokenRc {
+				depth--
+				if depth <= 0 {
+					j++
+					break
+				}
+			}
+		}
+
+		decls = append(decls, tokens[i:j])
+		i = j
+	}
 
 	return
 }
 
+func isDeclarationStart(t *lexer.Token) bool {
+	return t.T == lexer.TokenClass || t.T == lexer.TokenInterface || t.T == lexer.TokenAbstract
+}
+
+// 出现语法错误时删除出错的语句或成员后重新分析, 使声明中每个出错的语句和成员各自报告一次错误;
+// 缺少右大括号等在结尾处的错误无法通过删除恢复, 此时整个声明作废
+func (parser *Parser) parseDeclaration(tu *ast.TranslationUnit, tokens []*lexer.Token) {
+	var (
+		node      merak_ast.Node
+		err       error
+		recovered bool
+	)
+	for remaining := tokens; ; {
+		mark := len(parser.diags)
+		stream := newTokenStream(remaining)
+		if node, err = parser.p.SetLexer(stream).Parse(); err == nil {
+			break
+		}
+
+		// 出错之前的产生式报告的诊断在重新分析时会再次报告
+		parser.diags = parser.diags[:mark]
+		parser.minInts = make(map[*ast.Expression]*lexer.Token)
+		parser.unexpected(stream.current)
+		if stream.current.T == lexer.EoiToken {
+			parser.addNode(cst.KindError, tokens, nil)
+			return
+		}
+		recovered = true
+		if remaining = skipStatement(remaining, stream.pos-1); len(remaining) == 0 {
+			parser.addNode(cst.KindError, tokens, nil)
+			return
+		}
+	}
+	parser.reportMinInts()
+
+	if root, ok := node.(*cst.Node); ok {
+		// 每次只分析一个声明, 只保留其中的声明节点; 删除过token时具体语法树中不能缺少它们, 整个声明作为错误节点
+		if recovered {
+			parser.addNode(cst.KindError, tokens, nil)
+		} else {
+			parser.tree.Children = append(parser.tree.Children, root.Children...)
+		}
+		node = root.AST
+	}
+	decl := node.(*ast.TranslationUnit)
+	attachDocs(decl, tokens)
+	for _, name := range utils.SortedKeys(decl.InterfaceMap) {
+		inter := decl.InterfaceMap[name]
+		if prev, exists := tu.InterfaceMap[name]; exists {
+			parser.diags.Add(diag.Errorf(CodeInterfaceRedefine, inter.Pos.Extend(name), InterfaceRedefineErr, "%s", name).
+				WithNote(prev.Pos.Extend(name), "%s 已在此处定义", name))
+			continue
+		}
+		inter.Unit = tu
+		tu.InterfaceMap[name] = inter
+	}
+	for _, name := range utils.SortedKeys(decl.ClassMap) {
+		class := decl.ClassMap[name]
+		if prev, exists := tu.ClassMap[name]; exists {
+			parser.diags.Add(diag.Errorf(CodeClassRedefine, class.Pos.Extend(name), ClassRedefineErr, "%s", name).
+				WithNote(prev.Pos.Extend(name), "%s 已在此处定义", name))
+			continue
+		}
+		class.Unit = tu
+		tu.ClassMap[name] = class
+	}
+}
+
+// 删除语法错误所在的tokens[at]所属的语句或成员, 返回其余的token:
+// 从at之前最近的分号或大括号之后开始(for语句括号中的分号除外), 到之后第一个分号为止,
+// 其间的大括号块(例如方法体)一并删除; 遇到外层的右大括号时停在它之前。至少删除tokens[at]
+func skipStatement(tokens []*lexer.Token, at int) []*lexer.Token {
+	start, parens := 0, 0
+	for i := 0; i < at; i++ {
+		switch tokens[i].T {
+		case lexer.TokenLp:
+			parens++
+		case lexer.TokenRp:
+			if parens > 0 {
+				parens--
+			}
+		case lexer.TokenLc, lexer.TokenRc:
+			start, parens = i+1, 0
+		case lexer.TokenSemicolon:
+			if parens == 0 {
+				start = i + 1
+			}
+		}
+	}
+
+	end, depth := at, 0
+loop:
+	for ; end < len(tokens); end++ {
+		switch tokens[end].T {
+		case lexer.TokenLp:
+			parens++
+		case lexer.TokenRp:
+			if parens > 0 {
+				parens--
+			}
+		case lexer.TokenLc:
+			depth++
+		case lexer.TokenRc:
+			if depth == 0 {
+				break loop
+			}
+			if depth--; depth == 0 {
+				end++
+				break loop
+			}
+		case lexer.TokenSemicolon:
+			if depth == 0 && parens == 0 {
+				end++
+				break loop
+			}
+		}
+	}
+	if end == start {
+		end++
+	}
+
+	return append(tokens[:start:start], tokens[end:]...)
+}
+
+// 报告不是一元负号操作数的2^63, 按位置排序
+func (parser *Parser) reportMinInts() {
+	var literals []*lexer.Token
+	for expr, t := range parser.minInts {
+		literals = append(literals, t)
+		delete(parser.minInts, expr)
+	}
+	sort.Slice(literals, func(i, j int) bool {
+		return literals[i].StartLine < literals[j].StartLine ||
+			literals[i].StartLine == literals[j].StartLine && literals[i].StartColumn < literals[j].StartColumn
+	})
+	for _, t := range literals {
+		parser.diags.Add(diag.Errorf(CodeLiteralOverflow, tokenSpan(t), LiteralOverflowErr, "%s", t.Lexeme))
+	}
+}
+
+// 数值转换失败, 区分超出范围和格式错误
+func (parser *Parser) literalError(t *lexer.Token, err error) {
+	if errors.Is(err, strconv.ErrRange) {
+		parser.diags.Add(diag.Errorf(CodeLiteralOverflow, tokenSpan(t), LiteralOverflowErr, "%s", t.Lexeme))
+		return
+	}
+
+	parser.diags.Add(diag.Errorf(CodeLiteral, tokenSpan(t), LiteralErr, "%s", t.Lexeme))
+}
+
+// 将token上的文档注释转移到以该token开始的类、接口和方法上
+func attachDocs(decl *ast.TranslationUnit, tokens []*lexer.Token) {
+	docs := make(map[ast.Pos]string)
+	for _, t := range tokens {
+		if t.Doc != "" {
+			docs[tokenPos(t)] = t.Doc
+		}
+	}
+	if len(docs) == 0 {
+		return
+	}
+
+	for _, inter := range decl.InterfaceMap {
+		inter.Doc = docs[inter.Start]
+		for _, methods := range inter.MethodMap {
+			for _, method := range methods {
+				method.Doc = docs[method.Start]
+			}
+		}
+	}
+	for _, class := range decl.ClassMap {
+		class.Doc = docs[class.Start]
+		for _, methodMap := range []map[string]map[string]*ast.MethodDefinition{class.MethodDefinitionMap, class.AbstractMethodDefinitionMap} {
+			for _, methods := range methodMap {
+				for _, method := range methods {
+					method.Doc = docs[method.Start]
+				}
+			}
+		}
+		for _, constructor := range class.ConstructorMap {
+			constructor.Doc = docs[constructor.Start]
+		}
+	}
+}
+
+// 语法分析过程中产生的全部诊断, 包括不影响Parse返回值的警告
+func (parser *Parser) Diagnostics() diag.List {
+	return parser.diags
+}
+
+func (parser *Parser) unexpected(t *lexer.Token) {
+	detail := fmt.Sprintf("意外的 '%s'", t.Lexeme)
+	if t.T == lexer.EoiToken {
+		detail = "意外的结尾"
+	}
+
+	parser.diags.Add(diag.New(diag.SeverityError, CodeSyntax, tokenSpan(t), SyntaxErr, fmt.Sprintf("%s, %s", SyntaxErr, detail)))
+}
+
+// 以token切片作为merak的输入, 末尾补上EOI
+type tokenStream struct {
+	tokens  []*lexer.Token
+	pos     int
+	current *lexer.Token // 最近一次返回的token, 语法错误发生在该token处
+}
+
+func newTokenStream(tokens []*lexer.Token) *tokenStream {
+	return &tokenStream{tokens: tokens}
+}
+
+func (stream *tokenStream) Next() (merak_lexer.Token, error) {
+	if stream.pos < len(stream.tokens) {
+		stream.current = stream.tokens[stream.pos]
+		stream.pos++
+		return stream.current, nil
+	}
+
+	stream.current = eoiAfter(stream.tokens[len(stream.tokens)-1])
+
+	return stream.current, nil
+}
+
+// 紧跟在last之后的输入结束token
+func eoiAfter(last *lexer.Token) *lexer.Token {
+	return &lexer.Token{
+		T:           lexer.EoiToken,
+		StartLine:   last.EndLine,
+		StartColumn: last.EndColumn,
+		EndLine:     last.EndLine,
+		EndColumn:   last.EndColumn,
+		FileName:    last.FileName,
+	}
+}
+
 func (parser *Parser) initProductions() {
-	parser.p.RegisterProduction(lexer.SymbolArgumentList, []symbol.Symbol{lexer.SymbolExpression}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolArgumentList, []symbol.Symbol{lexer.SymbolExpression}, func(args []interface{}) merak_ast.Node {
 		argumentList := new(ast.ArgumentList)
 		expr := args[0].(*ast.Expression)
 		argumentList.List = append(argumentList.List, expr)
@@ -41,7 +488,7 @@ func (parser *Parser) initProductions() {
 		return argumentList
 	})
 
-	parser.p.RegisterProduction(lexer.SymbolArgumentList, []symbol.Symbol{lexer.SymbolArgumentList, lexer.SymbolComma, lexer.SymbolExpression}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolArgumentList, []symbol.Symbol{lexer.SymbolArgumentList, lexer.TokenComma, lexer.SymbolExpression}, func(args []interface{}) merak_ast.Node {
 		argumentList := args[0].(*ast.ArgumentList)
 
 		expr := args[2].(*ast.Expression)
@@ -51,613 +498,805 @@ func (parser *Parser) initProductions() {
 		return argumentList
 	})
 
-	parser.p.RegisterProduction(lexer.SymbolMethodCall, []symbol.Symbol{lexer.SymbolIdentifier, lexer.SymbolLp, lexer.SymbolRp}, false, func(args []interface{}) merak_ast.Node {
-		return &ast.MethodCall{Name: args[0].(*lexer.Token).Lexeme, ArgumentList: nil}
+	parser.production(lexer.SymbolMethodCall, []symbol.Symbol{lexer.TokenIdentifier, lexer.TokenLp, lexer.TokenRp}, func(args []interface{}) merak_ast.Node {
+		nameT := args[0].(*lexer.Token)
+		return &ast.MethodCall{Name: nameT.Lexeme, ArgumentList: nil, Pos: tokenPos(nameT), Span: argsSpan(args)}
 	})
-	parser.p.RegisterProduction(lexer.SymbolMethodCall, []symbol.Symbol{lexer.SymbolIdentifier, lexer.SymbolLp, lexer.SymbolArgumentList, lexer.SymbolRp}, false, func(args []interface{}) merak_ast.Node {
-		return &ast.MethodCall{Name: args[0].(*lexer.Token).Lexeme, ArgumentList: args[2].(*ast.ArgumentList).List}
+	parser.production(lexer.SymbolMethodCall, []symbol.Symbol{lexer.TokenIdentifier, lexer.TokenLp, lexer.SymbolArgumentList, lexer.TokenRp}, func(args []interface{}) merak_ast.Node {
+		nameT := args[0].(*lexer.Token)
+		return &ast.MethodCall{Name: nameT.Lexeme, ArgumentList: args[2].(*ast.ArgumentList).List, Pos: tokenPos(nameT), Span: argsSpan(args)}
 	})
 
-	parser.p.RegisterProduction(lexer.SymbolNewObjExpression, []symbol.Symbol{lexer.SymbolNew, lexer.SymbolMethodCall}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolNewObjExpression, []symbol.Symbol{lexer.TokenNew, lexer.SymbolMethodCall}, func(args []interface{}) merak_ast.Node {
 		methodCall := args[1].(*ast.MethodCall)
-		return &ast.NewObjectExpression{Name: methodCall.Name, ArgumentList: methodCall.ArgumentList}
+		return &ast.NewObjectExpression{Name: methodCall.Name, ArgumentList: methodCall.ArgumentList, Pos: methodCall.Pos, Span: argsSpan(args)}
 	})
 
-	parser.p.RegisterProduction(lexer.SymbolVarCallExpression, []symbol.Symbol{lexer.SymbolIdentifier}, false, func(args []interface{}) merak_ast.Node {
-		return &ast.VarCallExpression{Var: args[0].(*lexer.Token).Lexeme, Type: ast.VarCallExpressionTypeVar}
+	parser.production(lexer.SymbolVarCallExpression, []symbol.Symbol{lexer.TokenIdentifier}, func(args []interface{}) merak_ast.Node {
+		varT := args[0].(*lexer.Token)
+		return &ast.VarCallExpression{Var: varT.Lexeme, Type: ast.VarCallExpressionTypeVar, Pos: tokenPos(varT), Span: argsSpan(args)}
 	})
-	parser.p.RegisterProduction(lexer.SymbolVarCallExpression, []symbol.Symbol{lexer.SymbolThis}, false, func(args []interface{}) merak_ast.Node {
-		return &ast.VarCallExpression{This: args[0].(*lexer.Token).Lexeme, Type: ast.VarCallExpressionTypeThis}
+	parser.production(lexer.SymbolVarCallExpression, []symbol.Symbol{lexer.TokenThis}, func(args []interface{}) merak_ast.Node {
+		thisT := args[0].(*lexer.Token)
+		return &ast.VarCallExpression{This: thisT.Lexeme, Type: ast.VarCallExpressionTypeThis, Pos: tokenPos(thisT), Span: argsSpan(args)}
 	})
-	parser.p.RegisterProduction(lexer.SymbolVarCallExpression, []symbol.Symbol{lexer.SymbolCallExpression, lexer.SymbolDot, lexer.SymbolIdentifier}, false, func(args []interface{}) merak_ast.Node {
-		return &ast.VarCallExpression{CallExpression: args[0].(*ast.CallExpression), Var: args[2].(*lexer.Token).Lexeme, Type: ast.VarCallExpressionTypeCall}
+	parser.production(lexer.SymbolVarCallExpression, []symbol.Symbol{lexer.SymbolCallExpression, lexer.TokenDot, lexer.TokenIdentifier}, func(args []interface{}) merak_ast.Node {
+		varT := args[2].(*lexer.Token)
+		return &ast.VarCallExpression{CallExpression: args[0].(*ast.CallExpression), Var: varT.Lexeme, Type: ast.VarCallExpressionTypeCall, Pos: tokenPos(varT), Span: argsSpan(args)}
 	})
 
-	parser.p.RegisterProduction(lexer.SymbolMethodCallExpression, []symbol.Symbol{lexer.SymbolCallExpression, lexer.SymbolDot, lexer.SymbolMethodCall}, false, func(args []interface{}) merak_ast.Node {
-		return &ast.MethodCallExpression{CallExpression: args[0].(*ast.CallExpression), Name: args[2].(*ast.MethodCall).Name, ArgumentList: args[2].(*ast.MethodCall).ArgumentList}
+	parser.production(lexer.SymbolMethodCallExpression, []symbol.Symbol{lexer.SymbolCallExpression, lexer.TokenDot, lexer.SymbolMethodCall}, func(args []interface{}) merak_ast.Node {
+		methodCall := args[2].(*ast.MethodCall)
+		return &ast.MethodCallExpression{CallExpression: args[0].(*ast.CallExpression), Name: methodCall.Name, ArgumentList: methodCall.ArgumentList, Pos: methodCall.Pos, Span: argsSpan(args)}
 	})
 
-	parser.p.RegisterProduction(lexer.SymbolCallExpression, []symbol.Symbol{lexer.SymbolMethodCallExpression}, false, func(args []interface{}) merak_ast.Node {
-		return &ast.CallExpression{MethodCallExpression: args[0].(*ast.MethodCallExpression), Type: ast.CallExpressionTypeMethodCall}
+	parser.production(lexer.SymbolCallExpression, []symbol.Symbol{lexer.SymbolMethodCallExpression}, func(args []interface{}) merak_ast.Node {
+		return &ast.CallExpression{MethodCallExpression: args[0].(*ast.MethodCallExpression), Type: ast.CallExpressionTypeMethodCall, Span: argsSpan(args)}
 	})
-	parser.p.RegisterProduction(lexer.SymbolCallExpression, []symbol.Symbol{lexer.SymbolVarCallExpression}, false, func(args []interface{}) merak_ast.Node {
-		return &ast.CallExpression{VarCallExpression: args[0].(*ast.VarCallExpression), Type: ast.CallExpressionTypeValCall}
+	parser.production(lexer.SymbolCallExpression, []symbol.Symbol{lexer.SymbolVarCallExpression}, func(args []interface{}) merak_ast.Node {
+		return &ast.CallExpression{VarCallExpression: args[0].(*ast.VarCallExpression), Type: ast.CallExpressionTypeValCall, Span: argsSpan(args)}
 	})
 
-	parser.p.RegisterProduction(lexer.SymbolExpression, []symbol.Symbol{lexer.SymbolStringLiteral}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolPrimaryExpression, []symbol.Symbol{lexer.TokenStringLiteral}, func(args []interface{}) merak_ast.Node {
 		stringToken := args[0].(*lexer.Token)
-		return &ast.Expression{StringLiteral: stringToken.Lexeme, Type: ast.ExpressionTypeString}
+		return &ast.Expression{StringLiteral: stringToken.Lexeme, Type: ast.ExpressionTypeString, Pos: tokenPos(stringToken), Span: argsSpan(args)}
+	})
+	parser.production(lexer.SymbolPrimaryExpression, []symbol.Symbol{lexer.TokenCharLiteral}, func(args []interface{}) merak_ast.Node {
+		charToken := args[0].(*lexer.Token)
+		// 非法的字符字面量已经由词法分析报告
+		c, _ := utf8.DecodeRuneInString(charToken.Lexeme)
+		return &ast.Expression{CharLiteral: c, Type: ast.ExpressionTypeChar, Pos: tokenPos(charToken), Span: argsSpan(args)}
 	})
-	parser.p.RegisterProduction(lexer.SymbolExpression, []symbol.Symbol{lexer.SymbolIntLiteral}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolPrimaryExpression, []symbol.Symbol{lexer.TokenIntLiteral}, func(args []interface{}) merak_ast.Node {
 		intToken := args[0].(*lexer.Token)
-		intVal, err := strconv.ParseInt(intToken.Lexeme, 10, 64)
+		// 基数为0时按前缀识别进制, 并允许数字之间的下划线
+		intVal, err := strconv.ParseInt(intToken.Lexeme, 0, 64)
+		expr := &ast.Expression{IntLiteral: intVal, Type: ast.ExpressionTypeInt, Pos: tokenPos(intToken), Span: argsSpan(args)}
 		if err != nil {
-			panic(err)
+			// -9223372036854775808 是一元负号和2^63, 2^63按补码取反后仍是Int的最小值
+			if u, uerr := strconv.ParseUint(intToken.Lexeme, 0, 64); uerr == nil && u == 1<<63 {
+				expr.IntLiteral = math.MinInt64
+				parser.minInts[expr] = intToken
+				return expr
+			}
+			parser.literalError(intToken, err)
 		}
-		return &ast.Expression{IntLiteral: intVal, Type: ast.ExpressionTypeInt}
+		return expr
 	})
-	parser.p.RegisterProduction(lexer.SymbolExpression, []symbol.Symbol{lexer.SymbolDoubleLiteral}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolPrimaryExpression, []symbol.Symbol{lexer.TokenDoubleLiteral}, func(args []interface{}) merak_ast.Node {
 		doubleToken := args[0].(*lexer.Token)
-		floatVal, err := strconv.ParseFloat(doubleToken.Lexeme, 10)
+		floatVal, err := strconv.ParseFloat(doubleToken.Lexeme, 64)
 		if err != nil {
-			panic(err)
+			parser.literalError(doubleToken, err)
 		}
-		return &ast.Expression{DoubleLiteral: floatVal, Type: ast.ExpressionTypeDouble}
+		return &ast.Expression{DoubleLiteral: floatVal, Type: ast.ExpressionTypeDouble, Pos: tokenPos(doubleToken), Span: argsSpan(args)}
+	})
+	parser.production(lexer.SymbolPrimaryExpression, []symbol.Symbol{lexer.TokenNull}, func(args []interface{}) merak_ast.Node {
+		return &ast.Expression{NullLiteral: nil, Type: ast.ExpressionTypeNull, Pos: tokenPos(args[0].(*lexer.Token)), Span: argsSpan(args)}
+	})
+	parser.production(lexer.SymbolPrimaryExpression, []symbol.Symbol{lexer.TokenTrue}, func(args []interface{}) merak_ast.Node {
+		return &ast.Expression{BoolLiteral: true, Type: ast.ExpressionTypeBool, Pos: tokenPos(args[0].(*lexer.Token)), Span: argsSpan(args)}
+	})
+	parser.production(lexer.SymbolPrimaryExpression, []symbol.Symbol{lexer.TokenFalse}, func(args []interface{}) merak_ast.Node {
+		return &ast.Expression{BoolLiteral: false, Type: ast.ExpressionTypeBool, Pos: tokenPos(args[0].(*lexer.Token)), Span: argsSpan(args)}
 	})
-	parser.p.RegisterProduction(lexer.SymbolExpression, []symbol.Symbol{lexer.SymbolNull}, false, func(args []interface{}) merak_ast.Node {
-		return &ast.Expression{NullLiteral: nil, Type: ast.ExpressionTypeNull}
+	parser.production(lexer.SymbolPrimaryExpression, []symbol.Symbol{lexer.SymbolNewObjExpression}, func(args []interface{}) merak_ast.Node {
+		newObjExpr := args[0].(*ast.NewObjectExpression)
+		return &ast.Expression{NewObjectExpression: newObjExpr, Type: ast.ExpressionTypeNewObject, Pos: newObjExpr.Pos, Span: argsSpan(args)}
 	})
-	parser.p.RegisterProduction(lexer.SymbolExpression, []symbol.Symbol{lexer.SymbolTrue}, false, func(args []interface{}) merak_ast.Node {
-		return &ast.Expression{BoolLiteral: true, Type: ast.ExpressionTypeBool}
+	parser.production(lexer.SymbolPrimaryExpression, []symbol.Symbol{lexer.SymbolCallExpression}, func(args []interface{}) merak_ast.Node {
+		callExpr := args[0].(*ast.CallExpression)
+		return &ast.Expression{CallExpression: callExpr, Type: ast.ExpressionTypeCall, Pos: callExpr.Start, Span: argsSpan(args)}
 	})
-	parser.p.RegisterProduction(lexer.SymbolExpression, []symbol.Symbol{lexer.SymbolFalse}, false, func(args []interface{}) merak_ast.Node {
-		return &ast.Expression{BoolLiteral: false, Type: ast.ExpressionTypeBool}
+
+	parser.production(lexer.SymbolPrimaryExpression, []symbol.Symbol{lexer.TokenLp, lexer.SymbolExpression, lexer.TokenRp}, func(args []interface{}) merak_ast.Node {
+		expr := args[1].(*ast.Expression)
+		expr.Span = argsSpan(args)
+		return expr
 	})
-	parser.p.RegisterProduction(lexer.SymbolExpression, []symbol.Symbol{lexer.SymbolNewObjExpression}, false, func(args []interface{}) merak_ast.Node {
-		return &ast.Expression{NewObjectExpression: args[0].(*ast.NewObjectExpression), Type: ast.ExpressionTypeNewObject}
+
+	parser.production(lexer.SymbolUnaryExpression, []symbol.Symbol{lexer.SymbolPrimaryExpression}, passThrough)
+	parser.production(lexer.SymbolUnaryExpression, []symbol.Symbol{lexer.TokenSub, lexer.SymbolUnaryExpression}, func(args []interface{}) merak_ast.Node {
+		delete(parser.minInts, args[1].(*ast.Expression))
+		return unaryExpression(ast.OperatorNeg, args)
 	})
-	parser.p.RegisterProduction(lexer.SymbolExpression, []symbol.Symbol{lexer.SymbolCallExpression}, false, func(args []interface{}) merak_ast.Node {
-		return &ast.Expression{CallExpression: args[0].(*ast.CallExpression), Type: ast.ExpressionTypeCall}
+	parser.production(lexer.SymbolUnaryExpression, []symbol.Symbol{lexer.TokenNot, lexer.SymbolUnaryExpression}, func(args []interface{}) merak_ast.Node {
+		return unaryExpression(ast.OperatorNot, args)
 	})
 
-	parser.p.RegisterProduction(lexer.SymbolTypeVar, []symbol.Symbol{lexer.SymbolVoid, lexer.SymbolIdentifier}, false, func(args []interface{}) merak_ast.Node {
-		return &ast.TypeVar{Type: "void", Name: args[1].(*lexer.Token).Lexeme}
+	// 二元运算符按优先级由低到高分层, 每层都是左结合
+	binaryLevels := []struct {
+		left      symbol.Symbol
+		operand   symbol.Symbol
+		operators []symbol.Symbol
+	}{
+		{lexer.SymbolExpression, lexer.SymbolLogicalOrExpression, nil},
+		{lexer.SymbolLogicalOrExpression, lexer.SymbolLogicalAndExpression, []symbol.Symbol{lexer.TokenOr}},
+		{lexer.SymbolLogicalAndExpression, lexer.SymbolEqualityExpression, []symbol.Symbol{lexer.TokenAnd}},
+		{lexer.SymbolEqualityExpression, lexer.SymbolRelationalExpression, []symbol.Symbol{lexer.TokenEq, lexer.TokenNe}},
+		{lexer.SymbolRelationalExpression, lexer.SymbolAdditiveExpression, []symbol.Symbol{lexer.TokenLt, lexer.TokenLe, lexer.TokenGt, lexer.TokenGe}},
+		{lexer.SymbolAdditiveExpression, lexer.SymbolMultiplicativeExpression, []symbol.Symbol{lexer.TokenAdd, lexer.TokenSub}},
+		{lexer.SymbolMultiplicativeExpression, lexer.SymbolUnaryExpression, []symbol.Symbol{lexer.TokenMul, lexer.TokenDiv, lexer.TokenMod}},
+	}
+	for _, level := range binaryLevels {
+		parser.production(level.left, []symbol.Symbol{level.operand}, passThrough)
+		for _, operator := range level.operators {
+			parser.production(level.left, []symbol.Symbol{level.left, operator, level.operand}, binaryExpression)
+		}
+	}
+
+	parser.production(lexer.SymbolTypeVar, []symbol.Symbol{lexer.TokenVoid, lexer.TokenIdentifier}, func(args []interface{}) merak_ast.Node {
+		nameT := args[1].(*lexer.Token)
+		return &ast.TypeVar{Type: "void", Name: nameT.Lexeme, Pos: tokenPos(nameT), Span: argsSpan(args)}
 	})
-	parser.p.RegisterProduction(lexer.SymbolTypeVar, []symbol.Symbol{lexer.SymbolIdentifier, lexer.SymbolIdentifier}, false, func(args []interface{}) merak_ast.Node {
-		return &ast.TypeVar{Type: args[0].(*lexer.Token).Lexeme, Name: args[1].(*lexer.Token).Lexeme}
+	parser.production(lexer.SymbolTypeVar, []symbol.Symbol{lexer.TokenIdentifier, lexer.TokenIdentifier}, func(args []interface{}) merak_ast.Node {
+		nameT := args[1].(*lexer.Token)
+		return &ast.TypeVar{Type: args[0].(*lexer.Token).Lexeme, Name: nameT.Lexeme, Pos: tokenPos(nameT), Span: argsSpan(args)}
 	})
 
-	parser.p.RegisterProduction(lexer.SymbolExpressionStatement, []symbol.Symbol{lexer.SymbolExpression, lexer.SymbolSemicolon}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolExpressionStatement, []symbol.Symbol{lexer.SymbolExpression, lexer.TokenSemicolon}, func(args []interface{}) merak_ast.Node {
 		expr := args[0].(*ast.Expression)
-		return &ast.ExpressionStatement{Expression: expr}
+		return &ast.ExpressionStatement{Expression: expr, Span: argsSpan(args)}
 	})
 
-	parser.p.RegisterProduction(lexer.SymbolVarAssignStatement, []symbol.Symbol{lexer.SymbolTypeVar, lexer.SymbolAssign, lexer.SymbolExpressionStatement}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolVarAssignStatement, []symbol.Symbol{lexer.SymbolTypeVar, lexer.TokenAssign, lexer.SymbolExpressionStatement}, func(args []interface{}) merak_ast.Node {
 		typeVar := args[0].(*ast.TypeVar)
 		exprStmt := args[2].(*ast.ExpressionStatement)
-		return &ast.VarAssignStatement{VarName: typeVar.Name, VarType: typeVar.Type, Expression: exprStmt.Expression, Type: ast.VarAssignStatementTypeVar}
+		return &ast.VarAssignStatement{VarName: typeVar.Name, VarType: typeVar.Type, Expression: exprStmt.Expression, Type: ast.VarAssignStatementTypeVar, Pos: typeVar.Pos, Span: argsSpan(args)}
 	})
-	parser.p.RegisterProduction(lexer.SymbolVarAssignStatement, []symbol.Symbol{lexer.SymbolVarCallExpression, lexer.SymbolAssign, lexer.SymbolExpressionStatement}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolVarAssignStatement, []symbol.Symbol{lexer.SymbolVarCallExpression, lexer.TokenAssign, lexer.SymbolExpressionStatement}, func(args []interface{}) merak_ast.Node {
 		varCallExpr := args[0].(*ast.VarCallExpression)
 		exprStmt := args[2].(*ast.ExpressionStatement)
-		return &ast.VarAssignStatement{VarCallExpression: varCallExpr, Expression: exprStmt.Expression, Type: ast.VarAssignStatementTypeVarCall}
+		return &ast.VarAssignStatement{VarCallExpression: varCallExpr, Expression: exprStmt.Expression, Type: ast.VarAssignStatementTypeVarCall, Pos: varCallExpr.Pos, Span: argsSpan(args)}
 	})
 
-	parser.p.RegisterProduction(lexer.SymbolVarDeclarationStatement, []symbol.Symbol{lexer.SymbolTypeVar, lexer.SymbolSemicolon}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolVarDeclarationStatement, []symbol.Symbol{lexer.SymbolTypeVar, lexer.TokenSemicolon}, func(args []interface{}) merak_ast.Node {
 		typeVar := args[0].(*ast.TypeVar)
-		return &ast.VarDeclarationStatement{Type: typeVar.Type, Name: typeVar.Name}
+		return &ast.VarDeclarationStatement{Type: typeVar.Type, Name: typeVar.Name, Pos: typeVar.Pos, Span: argsSpan(args)}
 	})
 
-	parser.p.RegisterProduction(lexer.SymbolReturnStatement, []symbol.Symbol{lexer.SymbolReturn, lexer.SymbolSemicolon}, false, func(args []interface{}) merak_ast.Node {
-		return &ast.ReturnStatement{}
+	parser.production(lexer.SymbolReturnStatement, []symbol.Symbol{lexer.TokenReturn, lexer.TokenSemicolon}, func(args []interface{}) merak_ast.Node {
+		return &ast.ReturnStatement{Pos: tokenPos(args[0].(*lexer.Token)), Span: argsSpan(args)}
 	})
-	parser.p.RegisterProduction(lexer.SymbolReturnStatement, []symbol.Symbol{lexer.SymbolReturn, lexer.SymbolExpressionStatement}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolReturnStatement, []symbol.Symbol{lexer.TokenReturn, lexer.SymbolExpressionStatement}, func(args []interface{}) merak_ast.Node {
 		exprStmt := args[1].(*ast.ExpressionStatement)
-		return &ast.ReturnStatement{Expression: exprStmt.Expression}
+		return &ast.ReturnStatement{Expression: exprStmt.Expression, Pos: tokenPos(args[0].(*lexer.Token)), Span: argsSpan(args)}
 	})
 
-	parser.p.RegisterProduction(lexer.SymbolContinueStatement, []symbol.Symbol{lexer.SymbolContinue, lexer.SymbolSemicolon}, false, func(args []interface{}) merak_ast.Node {
-		return &ast.ContinueStatement{}
+	parser.production(lexer.SymbolContinueStatement, []symbol.Symbol{lexer.TokenContinue, lexer.TokenSemicolon}, func(args []interface{}) merak_ast.Node {
+		return &ast.ContinueStatement{Span: argsSpan(args)}
+	})
+	parser.production(lexer.SymbolContinueStatement, []symbol.Symbol{lexer.TokenContinue, lexer.SymbolExpressionStatement}, func(args []interface{}) merak_ast.Node {
+		exprStmt := args[1].(*ast.ExpressionStatement)
+		return &ast.ContinueStatement{Expression: exprStmt.Expression, Span: argsSpan(args)}
 	})
-	parser.p.RegisterProduction(lexer.SymbolContinueStatement, []symbol.Symbol{lexer.SymbolContinue, lexer.SymbolExpressionStatement}, false, func(args []interface{}) merak_ast.Node {
-		exprStmt := args[0].(*ast.ExpressionStatement)
-		return &ast.ContinueStatement{Expression: exprStmt.Expression}
+
+	parser.production(lexer.SymbolBreakStatement, []symbol.Symbol{lexer.TokenBreak, lexer.TokenSemicolon}, func(args []interface{}) merak_ast.Node {
+		return &ast.BreakStatement{Span: argsSpan(args)}
+	})
+	parser.production(lexer.SymbolBreakStatement, []symbol.Symbol{lexer.TokenBreak, lexer.SymbolExpressionStatement}, func(args []interface{}) merak_ast.Node {
+		exprStmt := args[1].(*ast.ExpressionStatement)
+		return &ast.BreakStatement{Expression: exprStmt.Expression, Span: argsSpan(args)}
 	})
 
-	parser.p.RegisterProduction(lexer.SymbolBreakStatement, []symbol.Symbol{lexer.SymbolBreak, lexer.SymbolSemicolon}, false, func(args []interface{}) merak_ast.Node {
-		return &ast.BreakStatement{}
+	parser.production(lexer.SymbolSuperCallStatement, []symbol.Symbol{lexer.TokenSuper, lexer.TokenLp, lexer.TokenRp, lexer.TokenSemicolon}, func(args []interface{}) merak_ast.Node {
+		return &ast.SuperCallStatement{Pos: tokenPos(args[0].(*lexer.Token)), Span: argsSpan(args)}
 	})
-	parser.p.RegisterProduction(lexer.SymbolBreakStatement, []symbol.Symbol{lexer.SymbolBreak, lexer.SymbolExpressionStatement}, false, func(args []interface{}) merak_ast.Node {
-		exprStmt := args[0].(*ast.ExpressionStatement)
-		return &ast.BreakStatement{Expression: exprStmt.Expression}
+	parser.production(lexer.SymbolSuperCallStatement, []symbol.Symbol{lexer.TokenSuper, lexer.TokenLp, lexer.SymbolArgumentList, lexer.TokenRp, lexer.TokenSemicolon}, func(args []interface{}) merak_ast.Node {
+		argumentList := args[2].(*ast.ArgumentList)
+		return &ast.SuperCallStatement{ArgumentList: argumentList.List, Pos: tokenPos(args[0].(*lexer.Token)), Span: argsSpan(args)}
 	})
 
-	parser.p.RegisterProduction(lexer.SymbolForStatement, []symbol.Symbol{lexer.SymbolFor, lexer.SymbolLp, lexer.SymbolSemicolon, lexer.SymbolSemicolon, lexer.SymbolRp, lexer.SymbolBlock}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolForStatement, []symbol.Symbol{lexer.TokenFor, lexer.TokenLp, lexer.TokenSemicolon, lexer.TokenSemicolon, lexer.TokenRp, lexer.SymbolBlock}, func(args []interface{}) merak_ast.Node {
 		block := args[5].(*ast.Block)
-		return &ast.ForStatement{Block: block}
+		return &ast.ForStatement{Block: block, Span: argsSpan(args)}
 	})
-	parser.p.RegisterProduction(lexer.SymbolForStatement, []symbol.Symbol{lexer.SymbolFor, lexer.SymbolLp, lexer.SymbolExpression, lexer.SymbolSemicolon, lexer.SymbolSemicolon, lexer.SymbolRp, lexer.SymbolBlock}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolForStatement, []symbol.Symbol{lexer.TokenFor, lexer.TokenLp, lexer.SymbolExpression, lexer.TokenSemicolon, lexer.TokenSemicolon, lexer.TokenRp, lexer.SymbolBlock}, func(args []interface{}) merak_ast.Node {
 		initExpr := args[2].(*ast.Expression)
 		block := args[6].(*ast.Block)
-		return &ast.ForStatement{InitExpression: initExpr, Block: block}
+		return &ast.ForStatement{InitExpression: initExpr, Block: block, Span: argsSpan(args)}
 	})
-	parser.p.RegisterProduction(lexer.SymbolForStatement, []symbol.Symbol{lexer.SymbolFor, lexer.SymbolLp, lexer.SymbolExpression, lexer.SymbolSemicolon, lexer.SymbolExpression, lexer.SymbolSemicolon, lexer.SymbolRp, lexer.SymbolBlock}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolForStatement, []symbol.Symbol{lexer.TokenFor, lexer.TokenLp, lexer.SymbolExpression, lexer.TokenSemicolon, lexer.SymbolExpression, lexer.TokenSemicolon, lexer.TokenRp, lexer.SymbolBlock}, func(args []interface{}) merak_ast.Node {
 		initExpr := args[2].(*ast.Expression)
 		condExpr := args[4].(*ast.Expression)
 		block := args[7].(*ast.Block)
-		return &ast.ForStatement{InitExpression: initExpr, CondExpression: condExpr, Block: block}
+		return &ast.ForStatement{InitExpression: initExpr, CondExpression: condExpr, Block: block, Span: argsSpan(args)}
 	})
-	parser.p.RegisterProduction(lexer.SymbolForStatement, []symbol.Symbol{lexer.SymbolFor, lexer.SymbolLp, lexer.SymbolExpression, lexer.SymbolSemicolon, lexer.SymbolExpression, lexer.SymbolSemicolon, lexer.SymbolExpression, lexer.SymbolRp, lexer.SymbolBlock}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolForStatement, []symbol.Symbol{lexer.TokenFor, lexer.TokenLp, lexer.SymbolExpression, lexer.TokenSemicolon, lexer.SymbolExpression, lexer.TokenSemicolon, lexer.SymbolExpression, lexer.TokenRp, lexer.SymbolBlock}, func(args []interface{}) merak_ast.Node {
 		initExpr := args[2].(*ast.Expression)
 		condExpr := args[4].(*ast.Expression)
 		postExpr := args[6].(*ast.Expression)
 		block := args[8].(*ast.Block)
-		return &ast.ForStatement{InitExpression: initExpr, CondExpression: condExpr, PostExpression: postExpr, Block: block}
+		return &ast.ForStatement{InitExpression: initExpr, CondExpression: condExpr, PostExpression: postExpr, Block: block, Span: argsSpan(args)}
 	})
 
-	parser.p.RegisterProduction(lexer.SymbolForStatement, []symbol.Symbol{lexer.SymbolFor, lexer.SymbolLp, lexer.SymbolExpression, lexer.SymbolSemicolon, lexer.SymbolSemicolon, lexer.SymbolExpression, lexer.SymbolRp, lexer.SymbolBlock}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolForStatement, []symbol.Symbol{lexer.TokenFor, lexer.TokenLp, lexer.SymbolExpression, lexer.TokenSemicolon, lexer.TokenSemicolon, lexer.SymbolExpression, lexer.TokenRp, lexer.SymbolBlock}, func(args []interface{}) merak_ast.Node {
 		initExpr := args[2].(*ast.Expression)
 		postExpr := args[5].(*ast.Expression)
 		block := args[7].(*ast.Block)
-		return &ast.ForStatement{InitExpression: initExpr, PostExpression: postExpr, Block: block}
+		return &ast.ForStatement{InitExpression: initExpr, PostExpression: postExpr, Block: block, Span: argsSpan(args)}
 	})
-	parser.p.RegisterProduction(lexer.SymbolForStatement, []symbol.Symbol{lexer.SymbolFor, lexer.SymbolLp, lexer.SymbolSemicolon, lexer.SymbolSemicolon, lexer.SymbolExpression, lexer.SymbolRp, lexer.SymbolBlock}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolForStatement, []symbol.Symbol{lexer.TokenFor, lexer.TokenLp, lexer.TokenSemicolon, lexer.TokenSemicolon, lexer.SymbolExpression, lexer.TokenRp, lexer.SymbolBlock}, func(args []interface{}) merak_ast.Node {
 		postExpr := args[4].(*ast.Expression)
 		block := args[6].(*ast.Block)
-		return &ast.ForStatement{PostExpression: postExpr, Block: block}
+		return &ast.ForStatement{PostExpression: postExpr, Block: block, Span: argsSpan(args)}
 	})
-	parser.p.RegisterProduction(lexer.SymbolForStatement, []symbol.Symbol{lexer.SymbolFor, lexer.SymbolLp, lexer.SymbolSemicolon, lexer.SymbolExpression, lexer.SymbolSemicolon, lexer.SymbolExpression, lexer.SymbolRp, lexer.SymbolBlock}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolForStatement, []symbol.Symbol{lexer.TokenFor, lexer.TokenLp, lexer.TokenSemicolon, lexer.SymbolExpression, lexer.TokenSemicolon, lexer.SymbolExpression, lexer.TokenRp, lexer.SymbolBlock}, func(args []interface{}) merak_ast.Node {
 		condExpr := args[3].(*ast.Expression)
 		postExpr := args[5].(*ast.Expression)
 		block := args[7].(*ast.Block)
-		return &ast.ForStatement{CondExpression: condExpr, PostExpression: postExpr, Block: block}
+		return &ast.ForStatement{CondExpression: condExpr, PostExpression: postExpr, Block: block, Span: argsSpan(args)}
 	})
-	parser.p.RegisterProduction(lexer.SymbolForStatement, []symbol.Symbol{lexer.SymbolFor, lexer.SymbolLp, lexer.SymbolSemicolon, lexer.SymbolExpression, lexer.SymbolSemicolon, lexer.SymbolRp, lexer.SymbolBlock}, false, func(args []interface{}) merak_ast.Node {
-		condExpr := args[4].(*ast.Expression)
-		block := args[8].(*ast.Block)
-		return &ast.ForStatement{CondExpression: condExpr, Block: block}
+	parser.production(lexer.SymbolForStatement, []symbol.Symbol{lexer.TokenFor, lexer.TokenLp, lexer.TokenSemicolon, lexer.SymbolExpression, lexer.TokenSemicolon, lexer.TokenRp, lexer.SymbolBlock}, func(args []interface{}) merak_ast.Node {
+		condExpr := args[3].(*ast.Expression)
+		block := args[6].(*ast.Block)
+		return &ast.ForStatement{CondExpression: condExpr, Block: block, Span: argsSpan(args)}
 	})
 
-	parser.p.RegisterProduction(lexer.SymbolIfStatement, []symbol.Symbol{lexer.SymbolIf, lexer.SymbolLp, lexer.SymbolExpression, lexer.SymbolRp, lexer.SymbolBlock}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolIfStatement, []symbol.Symbol{lexer.TokenIf, lexer.TokenLp, lexer.SymbolExpression, lexer.TokenRp, lexer.SymbolBlock}, func(args []interface{}) merak_ast.Node {
 		expr := args[2].(*ast.Expression)
 		ifBlock := args[4].(*ast.Block)
-		return &ast.IfStatement{CondExpression: expr, IfBlock: ifBlock}
+		return &ast.IfStatement{CondExpression: expr, IfBlock: ifBlock, Span: argsSpan(args)}
 	})
-	parser.p.RegisterProduction(lexer.SymbolIfStatement, []symbol.Symbol{lexer.SymbolIf, lexer.SymbolLp, lexer.SymbolExpression, lexer.SymbolRp, lexer.SymbolBlock, lexer.SymbolElse, lexer.SymbolBlock}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolIfStatement, []symbol.Symbol{lexer.TokenIf, lexer.TokenLp, lexer.SymbolExpression, lexer.TokenRp, lexer.SymbolBlock, lexer.TokenElse, lexer.SymbolBlock}, func(args []interface{}) merak_ast.Node {
 		expr := args[2].(*ast.Expression)
 		ifBlock := args[4].(*ast.Block)
 		elseBlock := args[6].(*ast.Block)
-		return &ast.IfStatement{CondExpression: expr, IfBlock: ifBlock, ElseBlock: elseBlock}
+		return &ast.IfStatement{CondExpression: expr, IfBlock: ifBlock, ElseBlock: elseBlock, Span: argsSpan(args)}
 	})
 
-	parser.p.RegisterProduction(lexer.SymbolWhileStatement, []symbol.Symbol{lexer.SymbolWhile, lexer.SymbolLp, lexer.SymbolExpression, lexer.SymbolRp, lexer.SymbolBlock}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolWhileStatement, []symbol.Symbol{lexer.TokenWhile, lexer.TokenLp, lexer.SymbolExpression, lexer.TokenRp, lexer.SymbolBlock}, func(args []interface{}) merak_ast.Node {
 		expr := args[2].(*ast.Expression)
 		block := args[4].(*ast.Block)
-		return &ast.WhileStatement{Expression: expr, Block: block}
+		return &ast.WhileStatement{Expression: expr, Block: block, Span: argsSpan(args)}
 	})
 
-	parser.p.RegisterProduction(lexer.SymbolStatement, []symbol.Symbol{lexer.SymbolExpressionStatement}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolStatement, []symbol.Symbol{lexer.SymbolExpressionStatement}, func(args []interface{}) merak_ast.Node {
 		stmt := args[0].(*ast.ExpressionStatement)
-		return &ast.Statement{ExpressionStatement: stmt, Type: ast.StatementTypeExpression}
+		return &ast.Statement{ExpressionStatement: stmt, Type: ast.StatementTypeExpression, Span: argsSpan(args)}
 	})
-	parser.p.RegisterProduction(lexer.SymbolStatement, []symbol.Symbol{lexer.SymbolVarDeclarationStatement}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolStatement, []symbol.Symbol{lexer.SymbolVarDeclarationStatement}, func(args []interface{}) merak_ast.Node {
 		stmt := args[0].(*ast.VarDeclarationStatement)
-		return &ast.Statement{VarDeclarationStatement: stmt, Type: ast.StatementTypeVarDeclaration}
+		return &ast.Statement{VarDeclarationStatement: stmt, Type: ast.StatementTypeVarDeclaration, Span: argsSpan(args)}
 	})
-	parser.p.RegisterProduction(lexer.SymbolStatement, []symbol.Symbol{lexer.SymbolVarAssignStatement}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolStatement, []symbol.Symbol{lexer.SymbolVarAssignStatement}, func(args []interface{}) merak_ast.Node {
 		stmt := args[0].(*ast.VarAssignStatement)
-		return &ast.Statement{VarAssignStatement: stmt, Type: ast.StatementTypeVarAssign}
+		return &ast.Statement{VarAssignStatement: stmt, Type: ast.StatementTypeVarAssign, Span: argsSpan(args)}
 	})
-	parser.p.RegisterProduction(lexer.SymbolStatement, []symbol.Symbol{lexer.SymbolWhileStatement}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolStatement, []symbol.Symbol{lexer.SymbolWhileStatement}, func(args []interface{}) merak_ast.Node {
 		stmt := args[0].(*ast.WhileStatement)
-		return &ast.Statement{WhileStatement: stmt, Type: ast.StatementTypeWhile}
+		return &ast.Statement{WhileStatement: stmt, Type: ast.StatementTypeWhile, Span: argsSpan(args)}
 	})
-	parser.p.RegisterProduction(lexer.SymbolStatement, []symbol.Symbol{lexer.SymbolIfStatement}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolStatement, []symbol.Symbol{lexer.SymbolIfStatement}, func(args []interface{}) merak_ast.Node {
 		stmt := args[0].(*ast.IfStatement)
-		return &ast.Statement{IfStatement: stmt, Type: ast.StatementTypeIf}
+		return &ast.Statement{IfStatement: stmt, Type: ast.StatementTypeIf, Span: argsSpan(args)}
 	})
-	parser.p.RegisterProduction(lexer.SymbolStatement, []symbol.Symbol{lexer.SymbolForStatement}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolStatement, []symbol.Symbol{lexer.SymbolForStatement}, func(args []interface{}) merak_ast.Node {
 		stmt := args[0].(*ast.ForStatement)
-		return &ast.Statement{ForStatement: stmt, Type: ast.StatementTypeFor}
+		return &ast.Statement{ForStatement: stmt, Type: ast.StatementTypeFor, Span: argsSpan(args)}
 	})
-	parser.p.RegisterProduction(lexer.SymbolStatement, []symbol.Symbol{lexer.SymbolBreakStatement}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolStatement, []symbol.Symbol{lexer.SymbolBreakStatement}, func(args []interface{}) merak_ast.Node {
 		stmt := args[0].(*ast.BreakStatement)
-		return &ast.Statement{BreakStatement: stmt, Type: ast.StatementTypeBreak}
+		return &ast.Statement{BreakStatement: stmt, Type: ast.StatementTypeBreak, Span: argsSpan(args)}
 	})
-	parser.p.RegisterProduction(lexer.SymbolStatement, []symbol.Symbol{lexer.SymbolContinueStatement}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolStatement, []symbol.Symbol{lexer.SymbolContinueStatement}, func(args []interface{}) merak_ast.Node {
 		stmt := args[0].(*ast.ContinueStatement)
-		return &ast.Statement{ContinueStatement: stmt, Type: ast.StatementTypeContinue}
+		return &ast.Statement{ContinueStatement: stmt, Type: ast.StatementTypeContinue, Span: argsSpan(args)}
 	})
-	parser.p.RegisterProduction(lexer.SymbolStatement, []symbol.Symbol{lexer.SymbolReturnStatement}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolStatement, []symbol.Symbol{lexer.SymbolReturnStatement}, func(args []interface{}) merak_ast.Node {
 		stmt := args[0].(*ast.ReturnStatement)
-		return &ast.Statement{ReturnStatement: stmt, Type: ast.StatementTypeReturn}
+		return &ast.Statement{ReturnStatement: stmt, Type: ast.StatementTypeReturn, Span: argsSpan(args)}
+	})
+	parser.production(lexer.SymbolStatement, []symbol.Symbol{lexer.SymbolSuperCallStatement}, func(args []interface{}) merak_ast.Node {
+		stmt := args[0].(*ast.SuperCallStatement)
+		return &ast.Statement{SuperCallStatement: stmt, Type: ast.StatementTypeSuperCall, Span: argsSpan(args)}
 	})
 
-	parser.p.RegisterProduction(lexer.SymbolStatementList, []symbol.Symbol{lexer.SymbolStatement}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolStatementList, []symbol.Symbol{lexer.SymbolStatement}, func(args []interface{}) merak_ast.Node {
 		stmt := args[0].(*ast.Statement)
 		stmtList := new(ast.StatementList)
 		stmtList.List = append(stmtList.List, stmt)
 		return stmtList
 	})
-	parser.p.RegisterProduction(lexer.SymbolStatementList, []symbol.Symbol{lexer.SymbolStatementList, lexer.SymbolStatement}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolStatementList, []symbol.Symbol{lexer.SymbolStatementList, lexer.SymbolStatement}, func(args []interface{}) merak_ast.Node {
 		stmtList := args[0].(*ast.StatementList)
 		stmt := args[1].(*ast.Statement)
 		stmtList.List = append(stmtList.List, stmt)
 		return stmtList
 	})
 
-	parser.p.RegisterProduction(lexer.SymbolEmptyBlock, []symbol.Symbol{lexer.SymbolLc, lexer.SymbolRc}, false, func(args []interface{}) merak_ast.Node {
-		return &ast.Block{}
+	parser.production(lexer.SymbolEmptyBlock, []symbol.Symbol{lexer.TokenLc, lexer.TokenRc}, func(args []interface{}) merak_ast.Node {
+		return &ast.Block{Span: argsSpan(args)}
 	})
 
-	parser.p.RegisterProduction(lexer.SymbolBlock, []symbol.Symbol{lexer.SymbolEmptyBlock}, false, func(args []interface{}) merak_ast.Node {
-		return &ast.Block{}
+	parser.production(lexer.SymbolBlock, []symbol.Symbol{lexer.SymbolEmptyBlock}, func(args []interface{}) merak_ast.Node {
+		return &ast.Block{Span: argsSpan(args)}
 	})
-	parser.p.RegisterProduction(lexer.SymbolBlock, []symbol.Symbol{lexer.SymbolLc, lexer.SymbolStatementList, lexer.SymbolRc}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolBlock, []symbol.Symbol{lexer.TokenLc, lexer.SymbolStatementList, lexer.TokenRc}, func(args []interface{}) merak_ast.Node {
 		stmtList := args[1].(*ast.StatementList)
-		return &ast.Block{StatementList: stmtList.List}
+		return &ast.Block{StatementList: stmtList.List, Span: argsSpan(args)}
 	})
 
-	parser.p.RegisterProduction(lexer.SymbolParameterList, []symbol.Symbol{lexer.SymbolTypeVar}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolParameterList, []symbol.Symbol{lexer.SymbolTypeVar}, func(args []interface{}) merak_ast.Node {
 		typeVar := args[0].(*ast.TypeVar)
 		param := new(ast.Parameter)
 		param.Name = typeVar.Name
 		param.Type = typeVar.Type
+		param.Pos = typeVar.Pos
+		param.Span = typeVar.Span
 		paramList := new(ast.ParameterList)
 		paramList.List = append(paramList.List, param)
 		return paramList
 	})
-	parser.p.RegisterProduction(lexer.SymbolParameterList, []symbol.Symbol{lexer.SymbolParameterList, lexer.SymbolComma, lexer.SymbolTypeVar}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolParameterList, []symbol.Symbol{lexer.SymbolParameterList, lexer.TokenComma, lexer.SymbolTypeVar}, func(args []interface{}) merak_ast.Node {
 		paramList := args[0].(*ast.ParameterList)
 		typeVar := args[2].(*ast.TypeVar)
 		param := new(ast.Parameter)
 		param.Name = typeVar.Name
 		param.Type = typeVar.Type
+		param.Pos = typeVar.Pos
+		param.Span = typeVar.Span
 		paramList.List = append(paramList.List, param)
 		return paramList
 	})
 
-	parser.p.RegisterProduction(lexer.SymbolMemberModifier, []symbol.Symbol{lexer.SymbolPublic}, false, func(args []interface{}) merak_ast.Node {
-		return &ast.MemberModifier{Type: ast.ModifierPublic}
+	parser.production(lexer.SymbolMemberModifier, []symbol.Symbol{lexer.TokenPublic}, func(args []interface{}) merak_ast.Node {
+		return &ast.MemberModifier{Type: ast.ModifierPublic, Span: argsSpan(args)}
 	})
-	parser.p.RegisterProduction(lexer.SymbolMemberModifier, []symbol.Symbol{lexer.SymbolProtected}, false, func(args []interface{}) merak_ast.Node {
-		return &ast.MemberModifier{Type: ast.ModifierProtected}
+	parser.production(lexer.SymbolMemberModifier, []symbol.Symbol{lexer.TokenProtected}, func(args []interface{}) merak_ast.Node {
+		return &ast.MemberModifier{Type: ast.ModifierProtected, Span: argsSpan(args)}
 	})
-	parser.p.RegisterProduction(lexer.SymbolMemberModifier, []symbol.Symbol{lexer.SymbolPrivate}, false, func(args []interface{}) merak_ast.Node {
-		return &ast.MemberModifier{Type: ast.ModifierPrivate}
+	parser.production(lexer.SymbolMemberModifier, []symbol.Symbol{lexer.TokenPrivate}, func(args []interface{}) merak_ast.Node {
+		return &ast.MemberModifier{Type: ast.ModifierPrivate, Span: argsSpan(args)}
 	})
-	parser.p.RegisterProduction(lexer.SymbolMemberModifier, []symbol.Symbol{lexer.SymbolAbstract}, false, func(args []interface{}) merak_ast.Node {
-		return &ast.MemberModifier{Type: ast.ModifierAbstract}
+	parser.production(lexer.SymbolMemberModifier, []symbol.Symbol{lexer.TokenAbstract}, func(args []interface{}) merak_ast.Node {
+		return &ast.MemberModifier{Type: ast.ModifierAbstract, Span: argsSpan(args)}
 	})
 
-	parser.p.RegisterProduction(lexer.SymbolMethodDefinition, []symbol.Symbol{lexer.SymbolMemberModifier, lexer.SymbolTypeVar, lexer.SymbolLp, lexer.SymbolRp, lexer.SymbolBlock}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolMethodDefinition, []symbol.Symbol{lexer.SymbolMemberModifier, lexer.SymbolTypeVar, lexer.TokenLp, lexer.TokenRp, lexer.SymbolBlock}, func(args []interface{}) merak_ast.Node {
 		modifier := args[0].(*ast.MemberModifier)
 		typeVar := args[1].(*ast.TypeVar)
 		block := args[4].(*ast.Block)
-		return &ast.MethodDefinition{ModifierType: modifier.Type, Name: typeVar.Name, Type: typeVar.Type, Block: block}
+		return &ast.MethodDefinition{ModifierType: modifier.Type, Name: typeVar.Name, Type: typeVar.Type, Block: block, Pos: typeVar.Pos, Span: argsSpan(args)}
 	})
-	parser.p.RegisterProduction(lexer.SymbolMethodDefinition, []symbol.Symbol{lexer.SymbolMemberModifier, lexer.SymbolTypeVar, lexer.SymbolLp, lexer.SymbolParameterList, lexer.SymbolRp, lexer.SymbolBlock}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolMethodDefinition, []symbol.Symbol{lexer.SymbolMemberModifier, lexer.SymbolTypeVar, lexer.TokenLp, lexer.SymbolParameterList, lexer.TokenRp, lexer.SymbolBlock}, func(args []interface{}) merak_ast.Node {
 		modifier := args[0].(*ast.MemberModifier)
 		typeVar := args[1].(*ast.TypeVar)
 		paramList := args[3].(*ast.ParameterList)
 		block := args[5].(*ast.Block)
-		return &ast.MethodDefinition{ModifierType: modifier.Type, Name: typeVar.Name, Type: typeVar.Type, ParameterList: paramList.List, Block: block}
+		return &ast.MethodDefinition{ModifierType: modifier.Type, Name: typeVar.Name, Type: typeVar.Type, ParameterList: paramList.List, Block: block, Pos: typeVar.Pos, Span: argsSpan(args)}
+	})
+
+	// 没有方法体的方法声明, 只能是抽象方法
+	parser.production(lexer.SymbolMethodDefinition, []symbol.Symbol{lexer.SymbolMemberModifier, lexer.SymbolTypeVar, lexer.TokenLp, lexer.TokenRp, lexer.TokenSemicolon}, func(args []interface{}) merak_ast.Node {
+		modifier := args[0].(*ast.MemberModifier)
+		typeVar := args[1].(*ast.TypeVar)
+		return &ast.MethodDefinition{ModifierType: modifier.Type, Name: typeVar.Name, Type: typeVar.Type, Pos: typeVar.Pos, Span: argsSpan(args)}
+	})
+	parser.production(lexer.SymbolMethodDefinition, []symbol.Symbol{lexer.SymbolMemberModifier, lexer.SymbolTypeVar, lexer.TokenLp, lexer.SymbolParameterList, lexer.TokenRp, lexer.TokenSemicolon}, func(args []interface{}) merak_ast.Node {
+		modifier := args[0].(*ast.MemberModifier)
+		typeVar := args[1].(*ast.TypeVar)
+		paramList := args[3].(*ast.ParameterList)
+		return &ast.MethodDefinition{ModifierType: modifier.Type, Name: typeVar.Name, Type: typeVar.Type, ParameterList: paramList.List, Pos: typeVar.Pos, Span: argsSpan(args)}
+	})
+
+	// native方法由运行时实现, 没有方法体
+	parser.production(lexer.SymbolMethodDefinition, []symbol.Symbol{lexer.SymbolMemberModifier, lexer.TokenNative, lexer.SymbolTypeVar, lexer.TokenLp, lexer.TokenRp, lexer.TokenSemicolon}, func(args []interface{}) merak_ast.Node {
+		modifier := args[0].(*ast.MemberModifier)
+		typeVar := args[2].(*ast.TypeVar)
+		return &ast.MethodDefinition{ModifierType: modifier.Type, IsNative: true, Name: typeVar.Name, Type: typeVar.Type, Pos: typeVar.Pos, Span: argsSpan(args)}
+	})
+	parser.production(lexer.SymbolMethodDefinition, []symbol.Symbol{lexer.SymbolMemberModifier, lexer.TokenNative, lexer.SymbolTypeVar, lexer.TokenLp, lexer.SymbolParameterList, lexer.TokenRp, lexer.TokenSemicolon}, func(args []interface{}) merak_ast.Node {
+		modifier := args[0].(*ast.MemberModifier)
+		typeVar := args[2].(*ast.TypeVar)
+		paramList := args[4].(*ast.ParameterList)
+		return &ast.MethodDefinition{ModifierType: modifier.Type, IsNative: true, Name: typeVar.Name, Type: typeVar.Type, ParameterList: paramList.List, Pos: typeVar.Pos, Span: argsSpan(args)}
 	})
 
-	parser.p.RegisterProduction(lexer.SymbolPropertyDefinition, []symbol.Symbol{lexer.SymbolMemberModifier, lexer.SymbolTypeVar, lexer.SymbolSemicolon}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolPropertyDefinition, []symbol.Symbol{lexer.SymbolMemberModifier, lexer.SymbolTypeVar, lexer.TokenSemicolon}, func(args []interface{}) merak_ast.Node {
 		modifier := args[0].(*ast.MemberModifier)
 		typeVar := args[1].(*ast.TypeVar)
-		return &ast.PropertyDefinition{ModifierType: modifier.Type, Name: typeVar.Name, Type: typeVar.Type}
+		return &ast.PropertyDefinition{ModifierType: modifier.Type, Name: typeVar.Name, Type: typeVar.Type, Pos: typeVar.Pos, Span: argsSpan(args)}
 	})
-	parser.p.RegisterProduction(lexer.SymbolPropertyDefinition, []symbol.Symbol{lexer.SymbolMemberModifier, lexer.SymbolTypeVar, lexer.SymbolAssign, lexer.SymbolExpressionStatement}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolPropertyDefinition, []symbol.Symbol{lexer.SymbolMemberModifier, lexer.SymbolTypeVar, lexer.TokenAssign, lexer.SymbolExpressionStatement}, func(args []interface{}) merak_ast.Node {
 		modifier := args[0].(*ast.MemberModifier)
 		typeVar := args[1].(*ast.TypeVar)
 		exprStmt := args[3].(*ast.ExpressionStatement)
-		return &ast.PropertyDefinition{ModifierType: modifier.Type, Name: typeVar.Name, Type: typeVar.Type, Expr: exprStmt.Expression}
+		return &ast.PropertyDefinition{ModifierType: modifier.Type, Name: typeVar.Name, Type: typeVar.Type, Expr: exprStmt.Expression, Pos: typeVar.Pos, Span: argsSpan(args)}
 	})
 
-	parser.p.RegisterProduction(lexer.SymbolClassStatement, []symbol.Symbol{lexer.SymbolMethodDefinition}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolClassStatement, []symbol.Symbol{lexer.SymbolMethodDefinition}, func(args []interface{}) merak_ast.Node {
 		md := args[0].(*ast.MethodDefinition)
+		if md.IsNative {
+			if md.ModifierType == ast.ModifierAbstract {
+				parser.diags.Add(diag.Errorf(CodeMethodBody, md.Pos.Extend(md.Name), MethodBodyErr, "native方法 %s 不能是抽象方法", md.Name))
+				md.ModifierType = ast.ModifierPublic
+			}
+			return &ast.ClassStatement{MethodDefinition: md, Type: ast.ClassStatementTypeMethod}
+		}
+		if md.ModifierType == ast.ModifierAbstract {
+			if md.Block != nil {
+				parser.diags.Add(diag.Errorf(CodeMethodBody, md.Block.Span, MethodBodyErr, "抽象方法 %s 不能有方法体", md.Name))
+				md.Block = nil
+			}
+			return &ast.ClassStatement{MethodDefinition: md, Type: ast.ClassStatementTypeAbstractMethod}
+		}
+		if md.Block == nil {
+			parser.diags.Add(diag.Errorf(CodeMethodBody, md.Pos.Extend(md.Name), MethodBodyErr, "方法 %s 缺少方法体", md.Name))
+			md.Block = &ast.Block{Span: md.Span}
+		}
 		return &ast.ClassStatement{MethodDefinition: md, Type: ast.ClassStatementTypeMethod}
 	})
-	parser.p.RegisterProduction(lexer.SymbolClassStatement, []symbol.Symbol{lexer.SymbolPropertyDefinition}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolClassStatement, []symbol.Symbol{lexer.SymbolPropertyDefinition}, func(args []interface{}) merak_ast.Node {
 		pd := args[0].(*ast.PropertyDefinition)
 		return &ast.ClassStatement{PropertyDefinition: pd, Type: ast.ClassStatementTypeProperty}
 	})
 
-	parser.p.RegisterProduction(lexer.SymbolClassStatementList, []symbol.Symbol{lexer.SymbolClassStatement}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolClassStatementList, []symbol.Symbol{lexer.SymbolClassStatement}, func(args []interface{}) merak_ast.Node {
 		cs := args[0].(*ast.ClassStatement)
-		csl := new(ast.ClassStatementList)
-		csl.MethodDefinitionMap = make(map[string]map[string]*ast.MethodDefinition)
-		csl.AbstractMethodDefinitionMap = make(map[string]map[string]*ast.MethodDefinition)
-		switch cs.Type {
-		case ast.ClassStatementTypeMethod:
-			csl.MethodDefinitionMap[cs.MethodDefinition.Name] = cs.MethodDefinition
-		case ast.ClassStatementTypeAbstractMethod:
-			csl.AbstractMethodDefinitionList = append(csl.AbstractMethodDefinitionList, cs.MethodDefinition)
-		case ast.ClassStatementTypeProperty:
-			csl.PropertyDefinitionList = append(csl.PropertyDefinitionList, cs.PropertyDefinition)
-		}
+		csl := ast.NewClassStatementList()
+		csl.Add(cs)
 
 		return csl
 	})
-	parser.p.RegisterProduction(lexer.SymbolClassStatementList, []symbol.Symbol{lexer.SymbolClassStatementList, lexer.SymbolClassStatement}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolClassStatementList, []symbol.Symbol{lexer.SymbolClassStatementList, lexer.SymbolClassStatement}, func(args []interface{}) merak_ast.Node {
 		csl := args[0].(*ast.ClassStatementList)
 		cs := args[1].(*ast.ClassStatement)
-		switch cs.Type {
-		case ast.ClassStatementTypeMethod:
-			csl.MethodDefinitionList = append(csl.MethodDefinitionList, cs.MethodDefinition)
-		case ast.ClassStatementTypeAbstractMethod:
-			csl.AbstractMethodDefinitionList = append(csl.AbstractMethodDefinitionList, cs.MethodDefinition)
-		case ast.ClassStatementTypeProperty:
-			csl.PropertyDefinitionList = append(csl.PropertyDefinitionList, cs.PropertyDefinition)
+		if prev := csl.Add(cs); prev != nil {
+			md := cs.MethodDefinition
+			parser.diags.Add(diag.Errorf(CodeMethodRedefine, md.Pos.Extend(md.Name), MethodRedefineErr, "%s", md.Signature()).
+				WithNote(prev.Pos.Extend(prev.Name), "%s 已在此处定义", prev.Signature()))
 		}
 		return csl
 	})
 
-	parser.p.RegisterProduction(lexer.SymbolImplementsDeclaration, []symbol.Symbol{lexer.SymbolImplements, lexer.SymbolIdentifier}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolImplementsDeclaration, []symbol.Symbol{lexer.TokenImplements, lexer.TokenIdentifier}, func(args []interface{}) merak_ast.Node {
 		t := args[1].(*lexer.Token)
 		impl := new(ast.Implements)
 		impl.InterfaceNameList = append(impl.InterfaceNameList, t.Lexeme)
 		return impl
 	})
-	parser.p.RegisterProduction(lexer.SymbolImplementsDeclaration, []symbol.Symbol{lexer.SymbolImplementsDeclaration, lexer.SymbolComma, lexer.SymbolIdentifier}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolImplementsDeclaration, []symbol.Symbol{lexer.SymbolImplementsDeclaration, lexer.TokenComma, lexer.TokenIdentifier}, func(args []interface{}) merak_ast.Node {
 		impl := args[0].(*ast.Implements)
 		t := args[2].(*lexer.Token)
 		impl.InterfaceNameList = append(impl.InterfaceNameList, t.Lexeme)
 		return impl
 	})
 
-	parser.p.RegisterProduction(lexer.SymbolExtendsDelcaration, []symbol.Symbol{lexer.SymbolExtends, lexer.SymbolIdentifier}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolExtendsDelcaration, []symbol.Symbol{lexer.TokenExtends, lexer.TokenIdentifier}, func(args []interface{}) merak_ast.Node {
 		t := args[1].(*lexer.Token)
 		extends := new(ast.Extends)
 		extends.ClassNameList = append(extends.ClassNameList, t.Lexeme)
 		return extends
 	})
-	parser.p.RegisterProduction(lexer.SymbolExtendsDelcaration, []symbol.Symbol{lexer.SymbolExtends, lexer.SymbolComma, lexer.SymbolIdentifier}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolExtendsDelcaration, []symbol.Symbol{lexer.SymbolExtendsDelcaration, lexer.TokenComma, lexer.TokenIdentifier}, func(args []interface{}) merak_ast.Node {
 		extends := args[0].(*ast.Extends)
 		t := args[2].(*lexer.Token)
 		extends.ClassNameList = append(extends.ClassNameList, t.Lexeme)
 		return extends
 	})
 
-	parser.p.RegisterProduction(lexer.SymbolClassDeclaration, []symbol.Symbol{lexer.SymbolClass, lexer.SymbolIdentifier, lexer.SymbolEmptyBlock}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolClassDeclaration, []symbol.Symbol{lexer.TokenClass, lexer.TokenIdentifier, lexer.SymbolEmptyBlock}, func(args []interface{}) merak_ast.Node {
 		nameT := args[1].(*lexer.Token)
-		return &ast.Class{Name: nameT.Lexeme}
+		return &ast.Class{Name: nameT.Lexeme, Pos: tokenPos(nameT), Span: argsSpan(args)}
 	})
-	parser.p.RegisterProduction(lexer.SymbolClassDeclaration, []symbol.Symbol{lexer.SymbolClass, lexer.SymbolIdentifier, lexer.SymbolLc, lexer.SymbolClassStatementList, lexer.SymbolRc}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolClassDeclaration, []symbol.Symbol{lexer.TokenClass, lexer.TokenIdentifier, lexer.TokenLc, lexer.SymbolClassStatementList, lexer.TokenRc}, func(args []interface{}) merak_ast.Node {
 		nameT := args[1].(*lexer.Token)
 		csl := args[3].(*ast.ClassStatementList)
 		class := new(ast.Class)
+		class.Span = argsSpan(args)
 		class.Name = nameT.Lexeme
-		class.MethodDefinitionList = csl.MethodDefinitionList
-		class.AbstractMethodDefinitionList = csl.AbstractMethodDefinitionList
-		class.PropertyDefinitionList = csl.PropertyDefinitionList
+		class.Pos = tokenPos(nameT)
+		class.MethodDefinitionMap = csl.MethodDefinitionMap
+		class.AbstractMethodDefinitionMap = csl.AbstractMethodDefinitionMap
+		class.PropertyDefinitionMap = csl.PropertyDefinitionMap
 		return class
 	})
-	parser.p.RegisterProduction(lexer.SymbolClassDeclaration, []symbol.Symbol{lexer.SymbolAbstract, lexer.SymbolClass, lexer.SymbolIdentifier, lexer.SymbolEmptyBlock}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolClassDeclaration, []symbol.Symbol{lexer.TokenAbstract, lexer.TokenClass, lexer.TokenIdentifier, lexer.SymbolEmptyBlock}, func(args []interface{}) merak_ast.Node {
 		nameT := args[2].(*lexer.Token)
 		class := new(ast.Class)
+		class.Span = argsSpan(args)
 		class.IsAbstract = true
 		class.Name = nameT.Lexeme
+		class.Pos = tokenPos(nameT)
 		return class
 	})
 
-	parser.p.RegisterProduction(lexer.SymbolClassDeclaration, []symbol.Symbol{lexer.SymbolAbstract, lexer.SymbolClass, lexer.SymbolIdentifier, lexer.SymbolLc, lexer.SymbolClassStatementList, lexer.SymbolRc}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolClassDeclaration, []symbol.Symbol{lexer.TokenAbstract, lexer.TokenClass, lexer.TokenIdentifier, lexer.TokenLc, lexer.SymbolClassStatementList, lexer.TokenRc}, func(args []interface{}) merak_ast.Node {
 		nameT := args[2].(*lexer.Token)
 		csl := args[4].(*ast.ClassStatementList)
 		class := new(ast.Class)
+		class.Span = argsSpan(args)
 		class.IsAbstract = true
 		class.Name = nameT.Lexeme
-		class.MethodDefinitionList = csl.MethodDefinitionList
-		class.AbstractMethodDefinitionList = csl.AbstractMethodDefinitionList
-		class.PropertyDefinitionList = csl.PropertyDefinitionList
+		class.Pos = tokenPos(nameT)
+		class.MethodDefinitionMap = csl.MethodDefinitionMap
+		class.AbstractMethodDefinitionMap = csl.AbstractMethodDefinitionMap
+		class.PropertyDefinitionMap = csl.PropertyDefinitionMap
 		return class
 	})
-	parser.p.RegisterProduction(lexer.SymbolClassDeclaration, []symbol.Symbol{lexer.SymbolClass, lexer.SymbolIdentifier, lexer.SymbolExtendsDelcaration, lexer.SymbolEmptyBlock}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolClassDeclaration, []symbol.Symbol{lexer.TokenClass, lexer.TokenIdentifier, lexer.SymbolExtendsDelcaration, lexer.SymbolEmptyBlock}, func(args []interface{}) merak_ast.Node {
 		nameT := args[1].(*lexer.Token)
 		extends := args[2].(*ast.Extends)
 		class := new(ast.Class)
+		class.Span = argsSpan(args)
 		class.Name = nameT.Lexeme
+		class.Pos = tokenPos(nameT)
 		class.Extends = extends.ClassNameList
 		return class
 	})
-	parser.p.RegisterProduction(lexer.SymbolClassDeclaration, []symbol.Symbol{lexer.SymbolClass, lexer.SymbolIdentifier, lexer.SymbolExtendsDelcaration, lexer.SymbolLc, lexer.SymbolClassStatementList, lexer.SymbolRc}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolClassDeclaration, []symbol.Symbol{lexer.TokenClass, lexer.TokenIdentifier, lexer.SymbolExtendsDelcaration, lexer.TokenLc, lexer.SymbolClassStatementList, lexer.TokenRc}, func(args []interface{}) merak_ast.Node {
 		nameT := args[1].(*lexer.Token)
 		extends := args[2].(*ast.Extends)
 		csl := args[4].(*ast.ClassStatementList)
 		class := new(ast.Class)
+		class.Span = argsSpan(args)
 		class.Name = nameT.Lexeme
+		class.Pos = tokenPos(nameT)
 		class.Extends = extends.ClassNameList
-		class.MethodDefinitionList = csl.MethodDefinitionList
-		class.AbstractMethodDefinitionList = csl.AbstractMethodDefinitionList
-		class.PropertyDefinitionList = csl.PropertyDefinitionList
+		class.MethodDefinitionMap = csl.MethodDefinitionMap
+		class.AbstractMethodDefinitionMap = csl.AbstractMethodDefinitionMap
+		class.PropertyDefinitionMap = csl.PropertyDefinitionMap
 		return class
 	})
 
-	parser.p.RegisterProduction(lexer.SymbolClassDeclaration, []symbol.Symbol{lexer.SymbolAbstract, lexer.SymbolClass, lexer.SymbolIdentifier, lexer.SymbolExtendsDelcaration, lexer.SymbolEmptyBlock}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolClassDeclaration, []symbol.Symbol{lexer.TokenAbstract, lexer.TokenClass, lexer.TokenIdentifier, lexer.SymbolExtendsDelcaration, lexer.SymbolEmptyBlock}, func(args []interface{}) merak_ast.Node {
 		nameT := args[2].(*lexer.Token)
 		extends := args[3].(*ast.Extends)
 		class := new(ast.Class)
+		class.Span = argsSpan(args)
 		class.IsAbstract = true
 		class.Name = nameT.Lexeme
+		class.Pos = tokenPos(nameT)
 		class.Extends = extends.ClassNameList
 		return class
 	})
-	parser.p.RegisterProduction(lexer.SymbolClassDeclaration, []symbol.Symbol{lexer.SymbolAbstract, lexer.SymbolClass, lexer.SymbolIdentifier, lexer.SymbolExtendsDelcaration, lexer.SymbolLc, lexer.SymbolClassStatementList, lexer.SymbolRc}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolClassDeclaration, []symbol.Symbol{lexer.TokenAbstract, lexer.TokenClass, lexer.TokenIdentifier, lexer.SymbolExtendsDelcaration, lexer.TokenLc, lexer.SymbolClassStatementList, lexer.TokenRc}, func(args []interface{}) merak_ast.Node {
 		nameT := args[2].(*lexer.Token)
 		extends := args[3].(*ast.Extends)
 		csl := args[5].(*ast.ClassStatementList)
 		class := new(ast.Class)
+		class.Span = argsSpan(args)
 		class.IsAbstract = true
 		class.Name = nameT.Lexeme
+		class.Pos = tokenPos(nameT)
 		class.Extends = extends.ClassNameList
-		class.MethodDefinitionList = csl.MethodDefinitionList
-		class.AbstractMethodDefinitionList = csl.AbstractMethodDefinitionList
-		class.PropertyDefinitionList = csl.PropertyDefinitionList
+		class.MethodDefinitionMap = csl.MethodDefinitionMap
+		class.AbstractMethodDefinitionMap = csl.AbstractMethodDefinitionMap
+		class.PropertyDefinitionMap = csl.PropertyDefinitionMap
 		return class
 	})
-	parser.p.RegisterProduction(lexer.SymbolClassDeclaration, []symbol.Symbol{lexer.SymbolClass, lexer.SymbolIdentifier, lexer.SymbolImplementsDeclaration, lexer.SymbolEmptyBlock}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolClassDeclaration, []symbol.Symbol{lexer.TokenClass, lexer.TokenIdentifier, lexer.SymbolImplementsDeclaration, lexer.SymbolEmptyBlock}, func(args []interface{}) merak_ast.Node {
 		nameT := args[1].(*lexer.Token)
 		implements := args[2].(*ast.Implements)
 		class := new(ast.Class)
+		class.Span = argsSpan(args)
 		class.Name = nameT.Lexeme
+		class.Pos = tokenPos(nameT)
 		class.Implements = implements.InterfaceNameList
 		return class
 	})
 
-	parser.p.RegisterProduction(lexer.SymbolClassDeclaration, []symbol.Symbol{lexer.SymbolClass, lexer.SymbolIdentifier, lexer.SymbolImplementsDeclaration, lexer.SymbolLc, lexer.SymbolClassStatementList, lexer.SymbolRc}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolClassDeclaration, []symbol.Symbol{lexer.TokenClass, lexer.TokenIdentifier, lexer.SymbolImplementsDeclaration, lexer.TokenLc, lexer.SymbolClassStatementList, lexer.TokenRc}, func(args []interface{}) merak_ast.Node {
 		nameT := args[1].(*lexer.Token)
 		implements := args[2].(*ast.Implements)
 		csl := args[4].(*ast.ClassStatementList)
 		class := new(ast.Class)
+		class.Span = argsSpan(args)
 		class.Name = nameT.Lexeme
+		class.Pos = tokenPos(nameT)
 		class.Implements = implements.InterfaceNameList
 		class.MethodDefinitionMap = csl.MethodDefinitionMap
 		class.AbstractMethodDefinitionMap = csl.AbstractMethodDefinitionMap
 		class.PropertyDefinitionMap = csl.PropertyDefinitionMap
 		return class
 	})
-	parser.p.RegisterProduction(lexer.SymbolClassDeclaration, []symbol.Symbol{lexer.SymbolAbstract, lexer.SymbolClass, lexer.SymbolIdentifier, lexer.SymbolImplementsDeclaration, lexer.SymbolEmptyBlock}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolClassDeclaration, []symbol.Symbol{lexer.TokenAbstract, lexer.TokenClass, lexer.TokenIdentifier, lexer.SymbolImplementsDeclaration, lexer.SymbolEmptyBlock}, func(args []interface{}) merak_ast.Node {
 		nameT := args[2].(*lexer.Token)
 		implements := args[3].(*ast.Implements)
 		class := new(ast.Class)
+		class.Span = argsSpan(args)
 		class.IsAbstract = true
 		class.Name = nameT.Lexeme
+		class.Pos = tokenPos(nameT)
 		class.Implements = implements.InterfaceNameList
 		return class
 	})
-	parser.p.RegisterProduction(lexer.SymbolClassDeclaration, []symbol.Symbol{lexer.SymbolAbstract, lexer.SymbolClass, lexer.SymbolIdentifier, lexer.SymbolImplementsDeclaration, lexer.SymbolLc, lexer.SymbolClassStatementList, lexer.SymbolRc}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolClassDeclaration, []symbol.Symbol{lexer.TokenAbstract, lexer.TokenClass, lexer.TokenIdentifier, lexer.SymbolImplementsDeclaration, lexer.TokenLc, lexer.SymbolClassStatementList, lexer.TokenRc}, func(args []interface{}) merak_ast.Node {
 		nameT := args[2].(*lexer.Token)
 		implements := args[3].(*ast.Implements)
 		csl := args[5].(*ast.ClassStatementList)
 		class := new(ast.Class)
+		class.Span = argsSpan(args)
 		class.IsAbstract = true
 		class.Name = nameT.Lexeme
+		class.Pos = tokenPos(nameT)
 		class.Implements = implements.InterfaceNameList
-		class.MethodDefinitionList = csl.MethodDefinitionList
-		class.AbstractMethodDefinitionList = csl.AbstractMethodDefinitionList
-		class.PropertyDefinitionList = csl.PropertyDefinitionList
+		class.MethodDefinitionMap = csl.MethodDefinitionMap
+		class.AbstractMethodDefinitionMap = csl.AbstractMethodDefinitionMap
+		class.PropertyDefinitionMap = csl.PropertyDefinitionMap
 		return class
 	})
 
-	parser.p.RegisterProduction(lexer.SymbolClassDeclaration, []symbol.Symbol{lexer.SymbolClass, lexer.SymbolIdentifier, lexer.SymbolExtendsDelcaration, lexer.SymbolImplementsDeclaration, lexer.SymbolEmptyBlock}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolClassDeclaration, []symbol.Symbol{lexer.TokenClass, lexer.TokenIdentifier, lexer.SymbolExtendsDelcaration, lexer.SymbolImplementsDeclaration, lexer.SymbolEmptyBlock}, func(args []interface{}) merak_ast.Node {
 		nameT := args[1].(*lexer.Token)
 		extends := args[2].(*ast.Extends)
 		implements := args[3].(*ast.Implements)
 		class := new(ast.Class)
+		class.Span = argsSpan(args)
 		class.Name = nameT.Lexeme
+		class.Pos = tokenPos(nameT)
 		class.Extends = extends.ClassNameList
 		class.Implements = implements.InterfaceNameList
 		return class
 	})
-	parser.p.RegisterProduction(lexer.SymbolClassDeclaration, []symbol.Symbol{lexer.SymbolClass, lexer.SymbolIdentifier, lexer.SymbolExtendsDelcaration, lexer.SymbolImplementsDeclaration, lexer.SymbolLc, lexer.SymbolClassStatementList, lexer.SymbolRc}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolClassDeclaration, []symbol.Symbol{lexer.TokenClass, lexer.TokenIdentifier, lexer.SymbolExtendsDelcaration, lexer.SymbolImplementsDeclaration, lexer.TokenLc, lexer.SymbolClassStatementList, lexer.TokenRc}, func(args []interface{}) merak_ast.Node {
 		nameT := args[1].(*lexer.Token)
 		extends := args[2].(*ast.Extends)
 		implements := args[3].(*ast.Implements)
 		csl := args[5].(*ast.ClassStatementList)
 		class := new(ast.Class)
+		class.Span = argsSpan(args)
 		class.Name = nameT.Lexeme
+		class.Pos = tokenPos(nameT)
 		class.Extends = extends.ClassNameList
 		class.Implements = implements.InterfaceNameList
-		class.MethodDefinitionList = csl.MethodDefinitionList
-		class.AbstractMethodDefinitionList = csl.AbstractMethodDefinitionList
-		class.PropertyDefinitionList = csl.PropertyDefinitionList
+		class.MethodDefinitionMap = csl.MethodDefinitionMap
+		class.AbstractMethodDefinitionMap = csl.AbstractMethodDefinitionMap
+		class.PropertyDefinitionMap = csl.PropertyDefinitionMap
 		return class
 	})
-	parser.p.RegisterProduction(lexer.SymbolClassDeclaration, []symbol.Symbol{lexer.SymbolAbstract, lexer.SymbolClass, lexer.SymbolIdentifier, lexer.SymbolExtendsDelcaration, lexer.SymbolImplementsDeclaration, lexer.SymbolEmptyBlock}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolClassDeclaration, []symbol.Symbol{lexer.TokenAbstract, lexer.TokenClass, lexer.TokenIdentifier, lexer.SymbolExtendsDelcaration, lexer.SymbolImplementsDeclaration, lexer.SymbolEmptyBlock}, func(args []interface{}) merak_ast.Node {
 		nameT := args[2].(*lexer.Token)
 		extends := args[3].(*ast.Extends)
 		implements := args[4].(*ast.Implements)
 		class := new(ast.Class)
+		class.Span = argsSpan(args)
 		class.IsAbstract = true
 		class.Name = nameT.Lexeme
+		class.Pos = tokenPos(nameT)
 		class.Extends = extends.ClassNameList
 		class.Implements = implements.InterfaceNameList
 		return class
 	})
 
-	parser.p.RegisterProduction(lexer.SymbolClassDeclaration, []symbol.Symbol{lexer.SymbolAbstract, lexer.SymbolClass, lexer.SymbolIdentifier, lexer.SymbolExtendsDelcaration, lexer.SymbolImplementsDeclaration, lexer.SymbolLc, lexer.SymbolClassStatementList, lexer.SymbolRc}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolClassDeclaration, []symbol.Symbol{lexer.TokenAbstract, lexer.TokenClass, lexer.TokenIdentifier, lexer.SymbolExtendsDelcaration, lexer.SymbolImplementsDeclaration, lexer.TokenLc, lexer.SymbolClassStatementList, lexer.TokenRc}, func(args []interface{}) merak_ast.Node {
 		nameT := args[2].(*lexer.Token)
 		extends := args[3].(*ast.Extends)
 		implements := args[4].(*ast.Implements)
 		csl := args[6].(*ast.ClassStatementList)
 		class := new(ast.Class)
+		class.Span = argsSpan(args)
 		class.IsAbstract = true
 		class.Name = nameT.Lexeme
+		class.Pos = tokenPos(nameT)
 		class.Extends = extends.ClassNameList
 		class.Implements = implements.InterfaceNameList
-		class.MethodDefinitionList = csl.MethodDefinitionList
-		class.AbstractMethodDefinitionList = csl.AbstractMethodDefinitionList
-		class.PropertyDefinitionList = csl.PropertyDefinitionList
+		class.MethodDefinitionMap = csl.MethodDefinitionMap
+		class.AbstractMethodDefinitionMap = csl.AbstractMethodDefinitionMap
+		class.PropertyDefinitionMap = csl.PropertyDefinitionMap
 		return class
 	})
 
-	parser.p.RegisterProduction(lexer.SymbolInterfaceMethodDeclarationStatement, []symbol.Symbol{lexer.SymbolTypeVar, lexer.SymbolLp, lexer.SymbolRp, lexer.SymbolSemicolon}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolInterfaceMethodDeclarationStatement, []symbol.Symbol{lexer.SymbolTypeVar, lexer.TokenLp, lexer.TokenRp, lexer.TokenSemicolon}, func(args []interface{}) merak_ast.Node {
 		typeVar := args[0].(*ast.TypeVar)
-		return &ast.InterfaceMethod{Type: typeVar.Type, Name: typeVar.Name}
+		return &ast.InterfaceMethod{Type: typeVar.Type, Name: typeVar.Name, Pos: typeVar.Pos, Span: argsSpan(args)}
 	})
-	parser.p.RegisterProduction(lexer.SymbolInterfaceMethodDeclarationStatement, []symbol.Symbol{lexer.SymbolTypeVar, lexer.SymbolLp, lexer.SymbolParameterList, lexer.SymbolRp, lexer.SymbolSemicolon}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolInterfaceMethodDeclarationStatement, []symbol.Symbol{lexer.SymbolTypeVar, lexer.TokenLp, lexer.SymbolParameterList, lexer.TokenRp, lexer.TokenSemicolon}, func(args []interface{}) merak_ast.Node {
 		typeVar := args[0].(*ast.TypeVar)
 		paramList := args[2].(*ast.ParameterList)
-		return &ast.InterfaceMethod{Type: typeVar.Type, Name: typeVar.Name, ParameterList: paramList.List}
+		return &ast.InterfaceMethod{Type: typeVar.Type, Name: typeVar.Name, ParameterList: paramList.List, Pos: typeVar.Pos, Span: argsSpan(args)}
 	})
 
-	parser.p.RegisterProduction(lexer.SymbolInterfaceMethodDeclarationStatementList, []symbol.Symbol{lexer.SymbolInterfaceMethodDeclarationStatement}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolInterfaceMethodDeclarationStatementList, []symbol.Symbol{lexer.SymbolInterfaceMethodDeclarationStatement}, func(args []interface{}) merak_ast.Node {
 		im := args[0].(*ast.InterfaceMethod)
 		return &ast.InterfaceMethodList{List: []*ast.InterfaceMethod{im}}
 	})
-	parser.p.RegisterProduction(lexer.SymbolInterfaceMethodDeclarationStatementList, []symbol.Symbol{lexer.SymbolInterfaceMethodDeclarationStatementList, lexer.SymbolInterfaceMethodDeclarationStatement}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolInterfaceMethodDeclarationStatementList, []symbol.Symbol{lexer.SymbolInterfaceMethodDeclarationStatementList, lexer.SymbolInterfaceMethodDeclarationStatement}, func(args []interface{}) merak_ast.Node {
 		iml := args[0].(*ast.InterfaceMethodList)
 		im := args[1].(*ast.InterfaceMethod)
 		iml.List = append(iml.List, im)
 		return iml
 	})
 
-	parser.p.RegisterProduction(lexer.SymbolInterfaceDeclaration, []symbol.Symbol{lexer.SymbolInterface, lexer.SymbolIdentifier, lexer.SymbolEmptyBlock}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolInterfaceDeclaration, []symbol.Symbol{lexer.TokenInterface, lexer.TokenIdentifier, lexer.SymbolEmptyBlock}, func(args []interface{}) merak_ast.Node {
 		nameT := args[1].(*lexer.Token)
-		return &ast.Interface{Name: nameT.Lexeme}
+		return &ast.Interface{Name: nameT.Lexeme, Pos: tokenPos(nameT), Span: argsSpan(args)}
 	})
-	parser.p.RegisterProduction(lexer.SymbolInterfaceDeclaration, []symbol.Symbol{lexer.SymbolInterface, lexer.SymbolIdentifier, lexer.SymbolLc, lexer.SymbolInterfaceMethodDeclarationStatementList, lexer.SymbolRc}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolInterfaceDeclaration, []symbol.Symbol{lexer.TokenInterface, lexer.TokenIdentifier, lexer.TokenLc, lexer.SymbolInterfaceMethodDeclarationStatementList, lexer.TokenRc}, func(args []interface{}) merak_ast.Node {
 		nameT := args[1].(*lexer.Token)
 		iml := args[3].(*ast.InterfaceMethodList)
-		return &ast.Interface{Name: nameT.Lexeme, MethodList: iml.List}
+		inter := &ast.Interface{Name: nameT.Lexeme, MethodMap: make(map[string]map[string]*ast.InterfaceMethod), Pos: tokenPos(nameT), Span: argsSpan(args)}
+		for _, im := range iml.List {
+			if _, exists := inter.MethodMap[im.Name]; !exists {
+				inter.MethodMap[im.Name] = make(map[string]*ast.InterfaceMethod)
+			}
+			key := ast.ParameterListKey(im.ParameterList)
+			if prev, exists := inter.MethodMap[im.Name][key]; exists {
+				parser.diags.Add(diag.Errorf(CodeMethodRedefine, im.Pos.Extend(im.Name), MethodRedefineErr, "%s", im.Signature()).
+					WithNote(prev.Pos.Extend(prev.Name), "%s 已在此处定义", prev.Signature()))
+				continue
+			}
+			inter.MethodMap[im.Name][key] = im
+		}
+		return inter
 	})
 
-	parser.p.RegisterProduction(lexer.SymbolClassInterfaceDeclaration, []symbol.Symbol{lexer.SymbolClassDeclaration}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolClassInterfaceDeclaration, []symbol.Symbol{lexer.SymbolClassDeclaration}, func(args []interface{}) merak_ast.Node {
 		class := args[0].(*ast.Class)
-		return &ast.ClassInterface{Class: class, Type: ast.ClassInterfaceTypeClass}
+		class.ExtractConstructors()
+		return &ast.ClassInterface{Class: class, Type: ast.ClassInterfaceTypeClass, Span: argsSpan(args)}
 	})
-	parser.p.RegisterProduction(lexer.SymbolClassInterfaceDeclaration, []symbol.Symbol{lexer.SymbolInterfaceDeclaration}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolClassInterfaceDeclaration, []symbol.Symbol{lexer.SymbolInterfaceDeclaration}, func(args []interface{}) merak_ast.Node {
 		inter := args[0].(*ast.Interface)
-		return &ast.ClassInterface{Interface: inter, Type: ast.ClassInterfaceTypeInterface}
+		return &ast.ClassInterface{Interface: inter, Type: ast.ClassInterfaceTypeInterface, Span: argsSpan(args)}
 	})
 
-	parser.p.RegisterProduction(lexer.SymbolClassInterfaceDeclarationList, []symbol.Symbol{lexer.SymbolClassInterfaceDeclaration}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolClassInterfaceDeclarationList, []symbol.Symbol{lexer.SymbolClassInterfaceDeclaration}, func(args []interface{}) merak_ast.Node {
 		ci := args[0].(*ast.ClassInterface)
-		tu := new(ast.TranslationUnit)
-		switch ci.Type {
-		case ast.ClassInterfaceTypeClass:
-			tu.ClassList = append(tu.ClassList, ci.Class)
-		case ast.ClassInterfaceTypeInterface:
-			tu.InterfaceList = append(tu.InterfaceList, ci.Interface)
-		}
+		tu := ast.NewTranslationUnit()
+		tu.Span = ci.Span
+		tu.Add(ci)
 		return tu
 	})
-	parser.p.RegisterProduction(lexer.SymbolClassInterfaceDeclarationList, []symbol.Symbol{lexer.SymbolClassInterfaceDeclarationList, lexer.SymbolClassInterfaceDeclaration}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolClassInterfaceDeclarationList, []symbol.Symbol{lexer.SymbolClassInterfaceDeclarationList, lexer.SymbolClassInterfaceDeclaration}, func(args []interface{}) merak_ast.Node {
 		tu := args[0].(*ast.TranslationUnit)
 		ci := args[1].(*ast.ClassInterface)
-		switch ci.Type {
-		case ast.ClassInterfaceTypeClass:
-			tu.ClassList = append(tu.ClassList, ci.Class)
-		case ast.ClassInterfaceTypeInterface:
-			tu.InterfaceList = append(tu.InterfaceList, ci.Interface)
-		}
+		tu.Span = argsSpan(args)
+		tu.Add(ci)
 		return tu
 	})
 
-	parser.p.RegisterProduction(lexer.SymbolTranslationUnit, []symbol.Symbol{lexer.SymbolClassInterfaceDeclarationList}, false, func(args []interface{}) merak_ast.Node {
+	parser.production(lexer.SymbolTranslationUnit, []symbol.Symbol{lexer.SymbolClassInterfaceDeclarationList}, func(args []interface{}) merak_ast.Node {
 		tu := args[0].(*ast.TranslationUnit)
 		return tu
 	})
 }
+
+// 只有一个符号的产生式, 直接使用该符号的值
+func passThrough(args []interface{}) merak_ast.Node {
+	return args[0]
+}
+
+func binaryExpression(args []interface{}) merak_ast.Node {
+	span := argsSpan(args)
+	binaryExpr := &ast.BinaryExpression{
+		Operator: ast.Operator(args[1].(*lexer.Token).Lexeme),
+		Left:     args[0].(*ast.Expression),
+		Right:    args[2].(*ast.Expression),
+		Span:     span,
+	}
+
+	return &ast.Expression{BinaryExpression: binaryExpr, Type: ast.ExpressionTypeBinary, Pos: span.Start, Span: span}
+}
+
+func unaryExpression(operator ast.Operator, args []interface{}) merak_ast.Node {
+	span := argsSpan(args)
+	unaryExpr := &ast.UnaryExpression{Operator: operator, Operand: args[1].(*ast.Expression), Span: span}
+
+	return &ast.Expression{UnaryExpression: unaryExpr, Type: ast.ExpressionTypeUnary, Pos: span.Start, Span: span}
+}
+
+func tokenPos(t *lexer.Token) ast.Pos {
+	return ast.Pos{FileName: t.FileName, Line: t.StartLine, Column: t.StartColumn}
+}
+
+func tokenSpan(t *lexer.Token) ast.Span {
+	return ast.Span{
+		Start: tokenPos(t),
+		End:   ast.Pos{FileName: t.FileName, Line: t.EndLine, Column: t.EndColumn},
+	}
+}
+
+// 产生式右部的范围: 从第一个符号的起点到最后一个符号的终点, 符号是token或者已经构造好的节点
+func argsSpan(args []interface{}) ast.Span {
+	return symbolSpan(args[0]).Merge(symbolSpan(args[len(args)-1]))
+}
+
+func symbolSpan(arg interface{}) ast.Span {
+	if t, ok := arg.(*lexer.Token); ok {
+		return tokenSpan(t)
+	}
+
+	return arg.(interface{ SourceSpan() ast.Span }).SourceSpan()
+}
@@ -0,0 +1,346 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mizar/ast"
+	"mizar/diag"
+	"mizar/lexer"
+	"mizar/log"
+	"mizar/prelude"
+	"strconv"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestParseRecovery(t *testing.T) {
+	log.Init(logrus.ErrorLevel)
+	tu, err := NewParser().Parse(lexer.NewFileLexer("a.mi", `class A {
+    public Int a = 99999999999999999999;
+}
+class B {
+    public void f() {
+        Int b = ;
+    }
+}
+class C # {
+}
+class D {
+    public Int d;
+}
+class D {
+}
+class E {
+    public void f() {
+        Int a = ;
+        Int b = 1;
+        b = * 2;
+        for (b; b < ; b.Increment()) {
+            a = 1;
+        }
+    }
+    public Int g( { return 1; }
+    public Int h() {
+        return 1 +;
+    }
+}`))
+
+	var diags diag.List
+	if !errors.As(err, &diags) {
+		t.Fatal(err)
+	}
+
+	expects := []struct {
+		err error
+		msg string
+	}{
+		{LiteralOverflowErr, "a.mi:2:20: 字面量超出范围 99999999999999999999"},
+		{SyntaxErr, "a.mi:6:17: 语法错误, 意外的 ';'"},
+		{lexer.TokenUnknownErr, "a.mi:9:9: 不识别的字符 '#'"},
+		{ClassRedefineErr, "a.mi:14:7: 重复定义的类 D"},
+		{SyntaxErr, "a.mi:18:17: 语法错误, 意外的 ';'"},
+		{SyntaxErr, "a.mi:20:13: 语法错误, 意外的 '*'"},
+		{SyntaxErr, "a.mi:21:21: 语法错误, 意外的 ';'"},
+		{SyntaxErr, "a.mi:25:19: 语法错误, 意外的 '{'"},
+		{SyntaxErr, "a.mi:27:19: 语法错误, 意外的 ';'"},
+	}
+	if len(diags) != len(expects) {
+		t.Fatal(diags)
+	}
+	diags.Sort()
+	for i, expect := range expects {
+		if !errors.Is(diags[i], expect.err) || diags[i].Error() != expect.msg {
+			t.Error(diags[i])
+		}
+	}
+	if len(diags[3].Notes) != 1 || diags[3].Notes[0].Span.Start.Line != 11 {
+		t.Error(diags[3].Notes)
+	}
+
+	// 出错的语句和成员被删除, 其余部分正常解析
+	for _, name := range []string{"A", "B", "C", "D", "E"} {
+		if _, exists := tu.ClassMap[name]; !exists {
+			t.Fatal(name)
+		}
+	}
+	e := tu.ClassMap["E"]
+	if len(e.MethodDefinitionMap["f"]) != 1 || len(e.MethodDefinitionMap["h"]) != 1 || len(e.MethodDefinitionMap["g"]) != 0 {
+		t.Error(e.MethodDefinitionMap)
+	}
+	if body := e.MethodDefinitionMap["f"][""].Block; len(body.StatementList) != 1 {
+		t.Error(len(body.StatementList))
+	}
+}
+
+func TestParseOperatorPrecedence(t *testing.T) {
+	log.Init(logrus.ErrorLevel)
+	tu, err := NewParser().Parse(lexer.NewLexer(`class A {
+    public Int a = -1 + 2 * 3 - (4 - 5) % 6;
+    public Bool b = !c || d && 1 < 2 == true;
+    public Bool e = -(1.5) >= 2 != 3 <= 4;
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expects := map[string]string{
+		"a": "((-1 + (2 * 3)) - ((4 - 5) % 6))",
+		"b": "(!c || (d && ((1 < 2) == true)))",
+		"e": "((-1.5 >= 2) != (3 <= 4))",
+	}
+	for name, expect := range expects {
+		if s := exprString(tu.ClassMap["A"].PropertyDefinitionMap[name].Expr); s != expect {
+			t.Errorf("%s: %s", name, s)
+		}
+	}
+}
+
+// 按运算的结合方式加上括号
+func exprString(expr *ast.Expression) string {
+	switch expr.Type {
+	case ast.ExpressionTypeInt:
+		return strconv.FormatInt(expr.IntLiteral, 10)
+	case ast.ExpressionTypeDouble:
+		return strconv.FormatFloat(expr.DoubleLiteral, 'f', -1, 64)
+	case ast.ExpressionTypeBool:
+		return strconv.FormatBool(expr.BoolLiteral)
+	case ast.ExpressionTypeCall:
+		return expr.CallExpression.VarCallExpression.Var
+	case ast.ExpressionTypeBinary:
+		binaryExpr := expr.BinaryExpression
+		return fmt.Sprintf("(%s %s %s)", exprString(binaryExpr.Left), binaryExpr.Operator, exprString(binaryExpr.Right))
+	case ast.ExpressionTypeUnary:
+		return string(expr.UnaryExpression.Operator) + exprString(expr.UnaryExpression.Operand)
+	}
+
+	return "?"
+}
+
+func TestParseDocComment(t *testing.T) {
+	log.Init(logrus.ErrorLevel)
+	l := lexer.NewLexer(`/** I */
+interface I {
+    /** f */
+    void f();
+}
+// 普通注释
+/** A */
+abstract class A {
+    /* 普通注释 */
+    public Int a;
+    /** g */
+    public void g() {
+    }
+}`)
+	l.SetKeepDocComments(true)
+	tu, err := NewParser().Parse(l)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inter, class := tu.InterfaceMap["I"], tu.ClassMap["A"]
+	docs := map[string]string{
+		"/** I */": inter.Doc,
+		"/** f */": inter.MethodMap["f"][""].Doc,
+		"/** A */": class.Doc,
+		"/** g */": class.MethodDefinitionMap["g"][""].Doc,
+	}
+	for expect, doc := range docs {
+		if doc != expect {
+			t.Errorf("%q != %q", doc, expect)
+		}
+	}
+}
+
+func TestParseNumber(t *testing.T) {
+	log.Init(logrus.ErrorLevel)
+	tu, err := NewParser().Parse(lexer.NewFileLexer("a.mi", `class A {
+    public Int a = 0x1F + 0b1010 + 0o17 + 017 + 1_000;
+    public Double b = .5 + 1.5e3 + 1e-1;
+    public Int c = 9223372036854775808;
+    public Double d = 1e400;
+    public Int e = 1__0 + 0x1G + 09;
+    public Double f = 1e;
+    public Int g = -9223372036854775808 - -0x8000_0000_0000_0000;
+    public Int h = 1 - 9223372036854775808;
+}`))
+
+	var diags diag.List
+	if !errors.As(err, &diags) {
+		t.Fatal(err)
+	}
+	msgs := []string{
+		"a.mi:4:20: 字面量超出范围 9223372036854775808",
+		"a.mi:5:23: 字面量超出范围 1e400",
+		"a.mi:6:20: 非法的字面量 1__0",
+		"a.mi:6:27: 非法的字面量 0x1G",
+		"a.mi:6:34: 非法的字面量 09",
+		"a.mi:7:23: 非法的字面量 1e",
+		"a.mi:9:24: 字面量超出范围 9223372036854775808",
+	}
+	if len(diags) != len(msgs) {
+		t.Fatal(diags)
+	}
+	diags.Sort()
+	for i, msg := range msgs {
+		if diags[i].Error() != msg {
+			t.Error(diags[i])
+		}
+	}
+
+	class := tu.ClassMap["A"]
+	if s := exprString(class.PropertyDefinitionMap["a"].Expr); s != "((((31 + 10) + 15) + 15) + 1000)" {
+		t.Error(s)
+	}
+	if s := exprString(class.PropertyDefinitionMap["b"].Expr); s != "((0.5 + 1500) + 0.1)" {
+		t.Error(s)
+	}
+	// 2^63作为一元负号的操作数
+	if s := exprString(class.PropertyDefinitionMap["g"].Expr); s != "(--9223372036854775808 - --9223372036854775808)" {
+		t.Error(s)
+	}
+}
+
+func TestParseHeader(t *testing.T) {
+	log.Init(logrus.ErrorLevel)
+	tu, err := NewParser().Parse(lexer.NewFileLexer("a.mi", `package a.b;
+import c;
+import d.e.f;
+import g.;
+class A {
+}`))
+
+	var diags diag.List
+	if !errors.As(err, &diags) || len(diags) != 1 || diags[0].Error() != "a.mi:4:10: 语法错误, 意外的 ';'" {
+		t.Fatal(err)
+	}
+	if tu.FileName != "a.mi" || tu.Package != "a.b" || len(tu.Imports) != 2 || tu.ClassMap["A"] == nil {
+		t.Fatal(tu)
+	}
+	if imp := tu.Imports[1]; imp.Path != "d.e.f" || imp.Pos.String() != "a.mi:3:8" || imp.End.String() != "a.mi:3:14" {
+		t.Error(imp)
+	}
+}
+
+func TestParseMethodRedefine(t *testing.T) {
+	log.Init(logrus.ErrorLevel)
+	tu, err := NewParser().Parse(lexer.NewFileLexer("a.mi", `interface I {
+    void f(Int a);
+    Int f(Int b);
+}
+abstract class A {
+    public void f(Int a) {
+    }
+    public void f(String a) {
+    }
+    abstract Int f(Int b);
+}`))
+
+	var diags diag.List
+	if !errors.As(err, &diags) {
+		t.Fatal(err)
+	}
+
+	expects := []string{
+		"a.mi:3:9: 重复定义的方法 f(Int)",
+		"a.mi:10:18: 重复定义的方法 f(Int)",
+	}
+	if len(diags) != len(expects) {
+		t.Fatal(diags)
+	}
+	diags.Sort()
+	for i, expect := range expects {
+		if !errors.Is(diags[i], MethodRedefineErr) || diags[i].Error() != expect {
+			t.Error(diags[i])
+		}
+	}
+	if len(diags[1].Notes) != 1 || diags[1].Notes[0].Span.Start.Line != 6 {
+		t.Error(diags[1].Notes)
+	}
+
+	// 保留先定义的方法
+	if md := tu.ClassMap["A"].MethodDefinitionMap["f"]["Int"]; md == nil || md.Type != "void" {
+		t.Error(md)
+	}
+	if len(tu.ClassMap["A"].AbstractMethodDefinitionMap) != 0 {
+		t.Error(tu.ClassMap["A"].AbstractMethodDefinitionMap)
+	}
+	if im := tu.InterfaceMap["I"].MethodMap["f"]["Int"]; im == nil || im.Type != "void" {
+		t.Error(im)
+	}
+}
+
+func TestParseCST(t *testing.T) {
+	log.Init(logrus.ErrorLevel)
+	base, err := ioutil.ReadFile("../demo/base.mi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sources := []string{
+		string(base),
+		prelude.Source,
+		"\uFEFFpackage a;\r\nimport b.c; // c\r\n\r\n/** A */\r\nclass A {\r\n\tpublic String s = \"\\u{4E2D}\\n\";\r\n}\r\n",
+		// 有错误的源码也要完整保留
+		"import ;\nclass A { public void f() { Int b = ; } }\n# \xff\nclass B {}\n/* 未结束",
+		"",
+	}
+	for _, source := range sources {
+		tree, _, _ := NewParser().ParseCST("a.mi", source)
+		if text := tree.String(); text != source {
+			t.Errorf("%q", text)
+		}
+	}
+
+	tree, tu, err := NewParser().ParseCST("a.mi", `class A {
+    // 注释
+    public Int f(Int a) {
+        return a + 1; // 加一
+    }
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	method := tu.ClassMap["A"].MethodDefinitionMap["f"]["Int"]
+	node := tree.Find(method)
+	if node == nil || node.Kind != string(lexer.SymbolMethodDefinition) {
+		t.Fatal(node)
+	}
+	tokens := node.Tokens()
+	first, last := tokens[0], tokens[len(tokens)-1]
+	if first.Text != "public" || len(first.Leading) != 4 || first.Leading[1].Text != "// 注释" || last.Text != "}" {
+		t.Errorf("%+v %+v", first, last)
+	}
+	add := tree.Find(method.Block.StatementList[0].ReturnStatement.Expression)
+	if add == nil || add.String() != "a + 1" {
+		t.Fatalf("%+v", add)
+	}
+	// 同一行中之后的注释和换行属于分号
+	for _, token := range tree.Tokens() {
+		if token.T == lexer.TokenSemicolon && fmt.Sprint(token.Trailing) != "[{1  } {3 // 加一} {2 \n}]" {
+			t.Error(token.Trailing)
+		}
+	}
+}
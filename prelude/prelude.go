@@ -0,0 +1,276 @@
+package prelude
+
+// 标准库, 由loader随程序一起加载, 属于根包, 其中的类在所有包中都可以直接使用
+//
+// Int、Double、Bool、Char、String是基本类型对应的类, 值不在堆上分配, this就是值本身, 因此:
+//   - 方法不能修改this, 例如 i.Increment() 返回 i + 1, i本身不变, 需要写成 i = i.Increment()
+//   - 方法不能被覆盖, 调用时不经过虚方法表
+//   - new Int(1) 的结果就是实参的值, new Int() 是零值
+//
+// 没有接收者的方法(例如 Int.Add)通过类名调用, 此时this为0, 方法中只能使用形参。
+// native方法由后端的运行时实现, 见asm.natives
+const Source = `
+class Int {
+    public native void Int();
+    public native void Int(Int value);
+
+    public Int Increment() {
+        return this + 1;
+    }
+
+    public Int Decrement() {
+        return this - 1;
+    }
+
+    public Int Add(Int a, Int b) {
+        return a + b;
+    }
+
+    public Int Sub(Int a, Int b) {
+        return a - b;
+    }
+
+    public Int Mul(Int a, Int b) {
+        return a * b;
+    }
+
+    public Int Div(Int a, Int b) {
+        return a / b;
+    }
+
+    public Int Mod(Int a, Int b) {
+        return a % b;
+    }
+
+    public Bool eq(Int a, Int b) {
+        return a == b;
+    }
+
+    public Bool ne(Int a, Int b) {
+        return a != b;
+    }
+
+    public Bool lt(Int a, Int b) {
+        return a < b;
+    }
+
+    public Bool le(Int a, Int b) {
+        return a <= b;
+    }
+
+    public Bool gt(Int a, Int b) {
+        return a > b;
+    }
+
+    public Bool ge(Int a, Int b) {
+        return a >= b;
+    }
+
+    public Int abs() {
+        if (this < 0) {
+            return -this;
+        }
+        return this;
+    }
+
+    public native Double toDouble();
+
+    // 十进制表示
+    public native String toString();
+
+    // 解析十进制整数, 可以有前导的负号, 遇到非数字字符时停止
+    public native Int parse(String s);
+}
+
+class Double {
+    public native void Double();
+    public native void Double(Double value);
+
+    public Double Add(Double a, Double b) {
+        return a + b;
+    }
+
+    public Double Sub(Double a, Double b) {
+        return a - b;
+    }
+
+    public Double Mul(Double a, Double b) {
+        return a * b;
+    }
+
+    public Double Div(Double a, Double b) {
+        return a / b;
+    }
+
+    public Bool lt(Double a, Double b) {
+        return a < b;
+    }
+
+    public Bool le(Double a, Double b) {
+        return a <= b;
+    }
+
+    public Bool gt(Double a, Double b) {
+        return a > b;
+    }
+
+    public Bool ge(Double a, Double b) {
+        return a >= b;
+    }
+
+    // 向零取整
+    public native Int toInt();
+
+    // 四舍五入保留6位小数, 例如 -0.500000; 绝对值不小于1e12时使用科学计数法, 例如 1.500000e13
+    public String toString() {
+        if (this != this) {
+            return "NaN";
+        }
+        String sign = "";
+        Double d = this;
+        if (d < 0.0) {
+            sign = "-";
+            d = -d;
+        }
+        if (d - d != 0.0) {
+            return sign + "Inf";
+        }
+        Int exp = 0;
+        if (d >= 1000000000000.0) {
+            while (d >= 10.0) {
+                d = d / 10.0;
+                exp = exp + 1;
+            }
+        }
+        Double scaled = d * 1000000.0 + 0.5;
+        Int n = scaled.toInt();
+        if (exp > 0 && n >= 10000000) {
+            n = n / 10;
+            exp = exp + 1;
+        }
+        Int whole = n / 1000000;
+        Int frac = n % 1000000 + 1000000;
+        String s = sign + whole.toString() + "." + frac.toString().substring(1, 7);
+        if (exp > 0) {
+            s = s + "e" + exp.toString();
+        }
+        return s;
+    }
+}
+
+class Bool {
+    public native void Bool();
+    public native void Bool(Bool value);
+
+    public Bool not() {
+        return !this;
+    }
+
+    public Bool and(Bool a, Bool b) {
+        return a && b;
+    }
+
+    public Bool or(Bool a, Bool b) {
+        return a || b;
+    }
+
+    public String toString() {
+        if (this) {
+            return "true";
+        }
+        return "false";
+    }
+}
+
+class Char {
+    public native void Char();
+    public native void Char(Char value);
+
+    // Unicode码点
+    public native Int toInt();
+
+    // UTF-8编码
+    public native String toString();
+}
+
+class String {
+    public native void String();
+    public native void String(String value);
+
+    // 字节数
+    public native Int length();
+
+    // 下标为index的字节, 越界时为0
+    public native Char charAt(Int index);
+
+    // [begin, end)之间的字节, 下标超出范围时截断到字符串的范围内
+    public native String substring(Int begin, Int end);
+
+    public String concat(String s) {
+        return this + s;
+    }
+
+    public Bool equals(String s) {
+        return this == s;
+    }
+
+    public Bool isEmpty() {
+        return this.length() == 0;
+    }
+}
+
+// 标准输出
+class Out {
+    public native void printString(String s);
+
+    public void printInt(Int i) {
+        Out.printString(i.toString());
+    }
+
+    public void printDouble(Double d) {
+        Out.printString(d.toString());
+    }
+
+    public void printChar(Char c) {
+        Out.printString(c.toString());
+    }
+
+    public void printBool(Bool b) {
+        Out.printString(b.toString());
+    }
+
+    public void println(String s) {
+        Out.printString(s);
+        Out.printString("\n");
+    }
+}
+
+// 标准输入
+class In {
+    // 读取一行, 不包括换行符; 已经到达输入末尾时返回null
+    public native String readLine();
+
+    public Int readInt() {
+        String line = In.readLine();
+        if (line == null) {
+            return 0;
+        }
+        return Int.parse(line);
+    }
+}
+
+// 运行时的状态
+class Runtime {
+    // 立即进行一次垃圾回收
+    public native void gc();
+
+    // 已经进行的垃圾回收次数
+    public native Int gcCount();
+
+    // 堆中已使用部分的字节数, 包括其中的空闲块
+    public native Int heapSize();
+}
+`
+
+// 诊断中使用的文件名
+const File = "<prelude>"
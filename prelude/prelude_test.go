@@ -0,0 +1,32 @@
+package prelude
+
+import (
+	"mizar/check"
+	"mizar/lexer"
+	"mizar/log"
+	"mizar/parser"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestPrelude(t *testing.T) {
+	log.Init(logrus.ErrorLevel)
+	tu, err := parser.NewParser().Parse(lexer.NewFileLexer(File, Source))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diags := check.CheckHierarchy(tu)
+	diags.Append(check.Resolve(tu))
+	diags.Append(check.TypeCheck(tu))
+	if len(diags) > 0 {
+		t.Fatal(diags)
+	}
+
+	for _, name := range []string{"Int", "Double", "Bool", "Char", "String", "Out", "In", "Runtime"} {
+		if _, exists := tu.ClassMap[name]; !exists {
+			t.Error(name)
+		}
+	}
+}
@@ -1,13 +1,17 @@
 package utils
 
-type SortByLength []string
+import (
+	"reflect"
+	"sort"
+)
 
-func (s SortByLength) Len() int {
-	return len(s)
-}
-func (s SortByLength) Swap(i, j int) {
-	s[i], s[j] = s[j], s[i]
-}
-func (s SortByLength) Less(i, j int) bool {
-	return len(s[i]) > len(s[j])
+// 返回以string为key的map的有序key列表, 用于稳定的遍历顺序
+func SortedKeys(m interface{}) []string {
+	keys := make([]string, 0)
+	for _, k := range reflect.ValueOf(m).MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+
+	return keys
 }